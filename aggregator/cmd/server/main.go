@@ -3,14 +3,22 @@ package main
 import (
 	"APIGateway/aggregator/config"
 	"APIGateway/aggregator/pkg/api"
-	"APIGateway/aggregator/pkg/logger"
 	"APIGateway/aggregator/pkg/middl"
 	"APIGateway/aggregator/pkg/rss"
 	"APIGateway/aggregator/pkg/storage"
+	"APIGateway/pkg/logging"
+	"APIGateway/pkg/metrics"
+	"APIGateway/pkg/tracing"
+	"context"
+	"fmt"
 	"github.com/joho/godotenv"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func init() {
@@ -21,52 +29,65 @@ func init() {
 
 func main() {
 	// Инициализация логгера
-	logInstance, err := logger.NewLogger("aggregator.log")
+	logs, err := logging.FromEnv("aggregator", "NEWS")
 	if err != nil {
 		log.Fatalf("Ошибка инициализации логгера: %v", err)
 	}
-	logInstance.InfoWithRequestID("Приложение запущено")
+	slog.Info("Приложение запущено")
 
+	err = run()
+	if err != nil {
+		slog.Error("Ошибка работы сервера", "err", err)
+	}
+	logs.Close()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// run запускает сервер новостей и возвращает управление после его остановки.
+func run() error {
 	// Загрузка конфигурации
 	cfg := config.New()
+	tracer := tracing.FromEnv("aggregator")
 
 	// Подключение к базе данных
 	db, err := storage.ConnectByURL(cfg.URLdb)
 	if err != nil {
-		logInstance.ErrorWithRequestID("Ошибка подключения к БД: ", err)
-		os.Exit(1)
+		return fmt.Errorf("подключение к БД: %w", err)
 	}
 	defer db.Close()
+	metrics.Default.RegisterSQLDB("aggregator", db)
 
 	store := storage.NewStorage(db)
 
 	// Загрузка конфигурации RSS
-	rssConfig, err := storage.LoadConfig("aggregator/cmd/server/config.json", logInstance)
+	rssConfig, err := storage.LoadConfig("aggregator/cmd/server/config.json")
 	if err != nil {
-		logInstance.ErrorWithRequestID("Ошибка загрузки конфигурации RSS: ", err)
-		os.Exit(1)
+		return fmt.Errorf("загрузка конфигурации RSS: %w", err)
 	}
 
 	// Инициализация API и маршрутов
-	apiHandler := api.NewAPI(store, logInstance)
+	apiHandler := api.NewAPI(store)
 	router := apiHandler.Router()
 
 	// Подключение middleware
+	router.Use(metrics.Middleware)
 	router.Use(middl.Middle)
-	router.Use(middl.WithRequestID)
+	router.Use(tracing.Middleware)
 
 	// Каналы для обмена сообщениями RSS
 	postCh := make(chan storage.Post)
 	errCh := make(chan error)
 
 	// Запуск RSS-парсера
-	go rss.StartPolling(rssConfig.RSS, rssConfig.RequestPeriod, postCh, errCh, logInstance)
+	go rss.StartPolling(rssConfig.RSS, rssConfig.RequestPeriod, postCh, errCh)
 
 	// Обработка полученных постов
 	go func() {
 		for post := range postCh {
-			if err := store.SavePost(post, logInstance); err != nil {
-				logInstance.ErrorWithRequestID("Ошибка сохранения поста:", err)
+			if err := store.SavePost(post); err != nil {
+				slog.Error("Ошибка сохранения поста", "link", post.Link, "err", err)
 			}
 		}
 	}()
@@ -74,15 +95,42 @@ func main() {
 	// Обработка ошибок RSS
 	go func() {
 		for err := range errCh {
-			logInstance.ErrorWithRequestID("Ошибка получения RSS: ", err)
+			slog.Error("Ошибка получения RSS", "err", err)
 		}
 	}()
 
-	logInstance.InfoWithRequestID("Сервер запущен на порту " + cfg.AdrPort)
+	server := &http.Server{
+		Addr:    cfg.AdrPort,
+		Handler: router,
+	}
+
+	// Graceful shutdown
+	done := make(chan os.Signal, 1)
+	signal.Notify(done, os.Interrupt, syscall.SIGTERM)
+	serveErr := make(chan error, 1)
 
 	// Запуск HTTP-сервера
-	if err := http.ListenAndServe(cfg.AdrPort, router); err != nil {
-		logInstance.ErrorWithRequestID("Ошибка при запуске сервера: ", err)
-		os.Exit(1)
+	go func() {
+		slog.Info("Сервер запущен", "addr", cfg.AdrPort)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case <-done:
+		slog.Info("Сервер останавливается...")
+	case err = <-serveErr:
+		err = fmt.Errorf("запуск сервера: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Ошибка при остановке сервера", "err", err)
+	}
+	if err := tracer.Shutdown(ctx); err != nil {
+		slog.Error("Ошибка отправки трасс", "err", err)
 	}
+	slog.Info("Сервер остановлен")
+	return err
 }
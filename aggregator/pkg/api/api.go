@@ -1,32 +1,34 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/gorilla/mux"
-	"log"
+	"log/slog"
 	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
-	"APIGateway/aggregator/pkg/logger"
 	"APIGateway/aggregator/pkg/storage"
+	"APIGateway/pkg/metrics"
 )
 
 const postsPerPage = 5
 
+// readyTimeout ограничивает время проверки хранилища в readyzHandler.
+const readyTimeout = 2 * time.Second
+
 type API struct {
 	DB     storage.StorageInterface
-	Logger *logger.Logger
 	router *mux.Router
 }
 
 // NewAPI создает экземпляр API.
-func NewAPI(db storage.StorageInterface, log *logger.Logger) *API {
+func NewAPI(db storage.StorageInterface) *API {
 	api := &API{
 		DB:     db,
-		Logger: log,
 		router: mux.NewRouter(),
 	}
 	api.endpoints()
@@ -43,6 +45,9 @@ func (a *API) endpoints() {
 	a.router.HandleFunc("/news", a.postsHandler).Methods(http.MethodGet, http.MethodOptions)
 	a.router.HandleFunc("/news/latest", a.newsLatestHandler).Methods(http.MethodGet, http.MethodOptions)
 	a.router.HandleFunc("/news/search", a.newsDetailedHandler).Methods(http.MethodGet, http.MethodOptions)
+	a.router.HandleFunc("/healthz", a.healthzHandler).Methods(http.MethodGet)
+	a.router.HandleFunc("/readyz", a.readyzHandler).Methods(http.MethodGet)
+	a.router.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
 
 }
 
@@ -74,7 +79,7 @@ func (a *API) postsHandler(w http.ResponseWriter, r *http.Request) {
 
 	posts, pagination, err := a.DB.GetPostsByTitle(sParam, limit, (page-1)*limit)
 	if err != nil {
-		log.Printf("DB error in postsHandler: %v", err)
+		slog.ErrorContext(r.Context(), "Ошибка получения новостей", "err", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
@@ -87,7 +92,8 @@ func (a *API) postsHandler(w http.ResponseWriter, r *http.Request) {
 		Pagination: pagination,
 	}
 
-	err = json.NewEncoder(w).Encode(response)
+	// Даты публикаций не отражают изменений списка, поэтому версия списка — только ETag.
+	err = writeJSON(w, r, response, time.Time{})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -123,7 +129,7 @@ func (api *API) newsLatestHandler(w http.ResponseWriter, r *http.Request) {
 		Pagination: pagination,
 	}
 
-	if err := json.NewEncoder(w).Encode(response); err != nil {
+	if err := writeJSON(w, r, response, time.Time{}); err != nil {
 		http.Error(w, "Ошибка сериализации ответа", http.StatusInternalServerError)
 	}
 }
@@ -173,12 +179,35 @@ func (api *API) newsDetailedHandler(w http.ResponseWriter, r *http.Request) {
 		"post": renderPost,
 	}
 
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		api.Logger.ErrorWithRequestID("Ошибка отправки ответа:", err)
+	if err := writeJSON(w, r, response, post.PubTime); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка отправки ответа", "err", err)
 		http.Error(w, "Ошибка рендеринга JSON", http.StatusInternalServerError)
 	}
 }
 
+// healthzHandler сообщает, что процесс жив.
+func (a *API) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// readyzHandler сообщает, готов ли сервис обслуживать запросы: доступно ли хранилище.
+func (a *API) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	if err := a.DB.Ping(ctx); err != nil {
+		slog.ErrorContext(r.Context(), "Хранилище недоступно", "err", err)
+
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"status": "unavailable", "storage": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok", "storage": "ok"})
+}
+
 // stripHTML удаляет HTML-теги из строки.
 func stripHTML(input string) string {
 	re := regexp.MustCompile("<.*?>")
@@ -1,8 +1,8 @@
 package api
 
 import (
-	"APIGateway/aggregator/pkg/logger"
 	"APIGateway/aggregator/pkg/storage"
+	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -17,7 +17,7 @@ type MockStorage struct {
 	err   error
 }
 
-func (m *MockStorage) SavePost(post storage.Post, logInstance *logger.Logger) error {
+func (m *MockStorage) SavePost(post storage.Post) error {
 	return nil
 }
 
@@ -70,13 +70,12 @@ func (m *MockStorage) GetPostsByTitle(search string, limit, offset int) ([]stora
 	}, nil
 }
 
-func newTestLogger() *logger.Logger {
-	logInstance, _ := logger.NewLogger("test.log")
-	return logInstance
+func (m *MockStorage) Ping(ctx context.Context) error {
+	return m.err
 }
 
 func newTestAPI(db storage.StorageInterface) *API {
-	return &API{DB: db, Logger: newTestLogger()}
+	return &API{DB: db}
 }
 
 func setupTestTemplate() {
@@ -198,3 +197,59 @@ func TestAPI_NewsDetailedHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestAPI_ReadyzHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		mockDB     *MockStorage
+		wantStatus int
+	}{
+		{"Storage available", &MockStorage{}, http.StatusOK},
+		{"Storage unavailable", &MockStorage{err: errors.New("connection refused")}, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(tt.mockDB)
+			w := httptest.NewRecorder()
+
+			api.readyzHandler(w, httptest.NewRequest("GET", "/readyz", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Ожидался статус %d, получено: %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestAPI_ConditionalGet(t *testing.T) {
+	api := newTestAPI(&MockStorage{posts: generateMockPosts(5)})
+
+	w := httptest.NewRecorder()
+	api.newsDetailedHandler(w, httptest.NewRequest("GET", "/news/search?id=2", nil))
+	etag, modified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	if w.Code != http.StatusOK || etag == "" || modified == "" {
+		t.Fatalf("Ожидались ETag и Last-Modified, получено: %d %q %q", w.Code, etag, modified)
+	}
+
+	for name, header := range map[string][2]string{
+		"If-None-Match":     {"If-None-Match", etag},
+		"If-Modified-Since": {"If-Modified-Since", modified},
+	} {
+		req := httptest.NewRequest("GET", "/news/search?id=2", nil)
+		req.Header.Set(header[0], header[1])
+		w := httptest.NewRecorder()
+		api.newsDetailedHandler(w, req)
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("%s: ожидался 304 без тела, получено: %d, %d байт", name, w.Code, w.Body.Len())
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/news?page=1", nil)
+	req.Header.Set("If-None-Match", `"stale"`)
+	w = httptest.NewRecorder()
+	api.postsHandler(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == "" || w.Header().Get("Last-Modified") != "" {
+		t.Errorf("Список: ожидался 200 только с ETag, получено: %d %v", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// writeJSON отправляет v с ETag, вычисленным по телу ответа, и Last-Modified, если modified задан.
+// На условный запрос с совпавшим ETag (или, без If-None-Match, с неизменившейся датой) отвечает 304.
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}, modified time.Time) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if notModified(r, etag, modified) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// notModified сравнивает условные заголовки запроса с текущей версией ответа.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if modified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	// Last-Modified передаётся с точностью до секунды.
+	return err == nil && !modified.Truncate(time.Second).After(since)
+}
@@ -1,10 +1,10 @@
 package middl
 
 import (
+	"APIGateway/pkg/logging"
 	"context"
-	"github.com/gofrs/uuid"
-	"log"
 	"net/http"
+	"time"
 )
 
 type LoggingResponseWriter struct {
@@ -28,20 +28,16 @@ func (lrw *LoggingResponseWriter) WriteHeader(code int) {
 
 func Middle(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		reqID := req.URL.Query().Get("request_id")
-		if reqID == "" {
-			rID, _ := uuid.NewV4()
-			reqID = rID.String()
-		}
+		start := time.Now()
+		reqID, _ := logging.RequestID(req)
 		req.Header.Set("X-Request-ID", reqID)
 		w.Header().Set("X-Request-ID", reqID)
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Access-Control-Allow-Origin", "*")
+		req = req.WithContext(context.WithValue(logging.WithRequest(req, reqID), RequestIDKey, reqID))
 		lrw := NewLoggingResponseWriter(w)
 		next.ServeHTTP(lrw, req)
-		statusCode := lrw.statusCode
-		log.Printf("<-- client ip: %s, method: %s, url: %s, status code: %d %s, trace id: %s",
-			req.RemoteAddr, req.Method, req.URL.Path, statusCode, http.StatusText(statusCode), reqID)
+		logging.LogRequest(req, lrw.statusCode, start)
 	})
 }
 
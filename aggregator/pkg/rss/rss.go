@@ -1,10 +1,14 @@
 package rss
 
 import (
-	"APIGateway/aggregator/pkg/logger"
 	"APIGateway/aggregator/pkg/storage"
+	"APIGateway/pkg/logging"
+	"APIGateway/pkg/metrics"
+	"APIGateway/pkg/tracing"
+	"context"
 	"encoding/xml"
 	"io"
+	"log/slog"
 	"net/http"
 	"time"
 )
@@ -22,6 +26,15 @@ type Item struct {
 	Link    string `xml:"link"`
 }
 
+var (
+	pollsTotal = metrics.NewCounter("aggregator_rss_polls_total",
+		"RSS feed polls by feed and outcome (ok or error).", "feed", "outcome")
+	pollDuration = metrics.NewHistogram("aggregator_rss_poll_duration_seconds",
+		"Time to fetch and parse an RSS feed.", nil, "feed")
+	itemsTotal = metrics.NewCounter("aggregator_rss_items_total",
+		"Items received from RSS feeds.", "feed")
+)
+
 var timeFormats = []string{
 	"Mon, 2 Jan 2006 15:04:05 -0700",
 	"Mon 2 Jan 2006 15:04:05 GMT",
@@ -30,31 +43,31 @@ var timeFormats = []string{
 }
 
 // parseRSS загружает и парсит RSS-ленту
-func parseRSS(url string, logInstance *logger.Logger) ([]storage.Post, error) {
-	logInstance.InfoWithRequestID("Запрос к RSS-ленте:", url)
+func parseRSS(ctx context.Context, url string) ([]storage.Post, error) {
+	slog.DebugContext(ctx, "Запрос к RSS-ленте")
 
 	resp, err := http.Get(url)
 	if err != nil {
-		logInstance.ErrorWithRequestID("Ошибка HTTP-запроса:", err)
+		slog.ErrorContext(ctx, "Ошибка HTTP-запроса", "err", err)
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		logInstance.ErrorWithRequestID("Ошибка чтения тела ответа:", err)
+		slog.ErrorContext(ctx, "Ошибка чтения тела ответа", "err", err)
 		return nil, err
 	}
 
 	var rss RSS
 	if err := xml.Unmarshal(data, &rss); err != nil {
-		logInstance.ErrorWithRequestID("Ошибка парсинга XML:", err)
+		slog.ErrorContext(ctx, "Ошибка парсинга XML", "err", err)
 		return nil, err
 	}
 
 	var posts []storage.Post
 	for _, item := range rss.Channel.Items {
-		pubTime := parsePubDate(item.PubTime, logInstance)
+		pubTime := parsePubDate(ctx, item.PubTime)
 
 		posts = append(posts, storage.Post{
 			Title:   item.Title,
@@ -68,43 +81,54 @@ func parseRSS(url string, logInstance *logger.Logger) ([]storage.Post, error) {
 }
 
 // parsePubDate пытается разобрать дату в разных форматах
-func parsePubDate(dateStr string, logInstance *logger.Logger) time.Time {
+func parsePubDate(ctx context.Context, dateStr string) time.Time {
 	for _, format := range timeFormats {
 		t, err := time.Parse(format, dateStr)
 		if err == nil {
 			return t
 		}
 	}
-	logInstance.ErrorWithRequestID("Ошибка парсинга даты:", dateStr)
+	slog.WarnContext(ctx, "Ошибка парсинга даты", "date", dateStr)
 	return time.Now()
 }
 
 // StartPolling запускает горутины для чтения RSS с использованием каналов
-func StartPolling(urls []string, period int, postChan chan<- storage.Post, errChan chan<- error, logInstance *logger.Logger) {
+func StartPolling(urls []string, period int, postChan chan<- storage.Post, errChan chan<- error) {
 	for _, url := range urls {
 		go func(feed string) {
-			pollingRSS(feed, postChan, errChan, logInstance)
+			pollingRSS(feed, postChan, errChan)
 			ticker := time.NewTicker(time.Duration(period) * time.Minute)
 			defer ticker.Stop()
 
 			for range ticker.C {
-				pollingRSS(feed, postChan, errChan, logInstance)
+				pollingRSS(feed, postChan, errChan)
 			}
 		}(url)
 	}
 }
 
 // pollingRSS выполняет запрос к RSS-ленте и отправляет результаты в каналы
-func pollingRSS(feed string, postChan chan<- storage.Post, errChan chan<- error, logInstance *logger.Logger) {
-	logInstance.InfoWithRequestID("Читаем RSS:", feed)
-	posts, err := parseRSS(feed, logInstance)
+func pollingRSS(feed string, postChan chan<- storage.Post, errChan chan<- error) {
+	ctx, span := tracing.Start(context.Background(), "rss poll", tracing.KindInternal)
+	defer span.End()
+	ctx = logging.With(ctx, slog.String("feed", feed))
+	slog.InfoContext(ctx, "Читаем RSS")
+	span.SetAttr("feed", feed)
+	start := time.Now()
+	posts, err := parseRSS(ctx, feed)
+	pollDuration.Since(start, feed)
+	span.SetAttr("items", len(posts))
 	if err != nil {
+		span.SetError(err.Error())
+		pollsTotal.Inc(feed, "error")
 		errChan <- err
 		return
 	}
+	pollsTotal.Inc(feed, "ok")
+	itemsTotal.Add(float64(len(posts)), feed)
 
 	if len(posts) == 0 {
-		logInstance.InfoWithRequestID("Нет новых статей из:", feed)
+		slog.InfoContext(ctx, "Нет новых статей")
 	}
 
 	for _, post := range posts {
@@ -1,8 +1,8 @@
 package rss
 
 import (
-	"APIGateway/aggregator/pkg/logger"
 	"APIGateway/aggregator/pkg/storage"
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -17,11 +17,6 @@ func mockHTTPClient(response string, statusCode int) *httptest.Server {
 }
 
 func TestParseRSS(t *testing.T) {
-	logInstance, err := logger.NewLogger("test.log")
-	if err != nil {
-		t.Errorf("ошибка создания логгера: %v", err)
-	}
-
 	tests := []struct {
 		name          string
 		serverHandler http.HandlerFunc
@@ -73,7 +68,7 @@ func TestParseRSS(t *testing.T) {
 			server := httptest.NewServer(test.serverHandler)
 			defer server.Close()
 
-			posts, err := parseRSS(server.URL, logInstance)
+			posts, err := parseRSS(context.Background(), server.URL)
 
 			if test.expectError {
 				if err == nil {
@@ -92,11 +87,6 @@ func TestParseRSS(t *testing.T) {
 }
 
 func TestParsePubDate(t *testing.T) {
-	logInstance, err := logger.NewLogger("test.log")
-	if err != nil {
-		t.Errorf("ошибка создания логгера: %v", err)
-	}
-
 	tests := []struct {
 		dateStr      string
 		shouldBeNow  bool
@@ -119,7 +109,7 @@ func TestParsePubDate(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.description, func(t *testing.T) {
-			parsedTime := parsePubDate(test.dateStr, logInstance)
+			parsedTime := parsePubDate(context.Background(), test.dateStr)
 
 			if test.shouldBeNow {
 
@@ -152,12 +142,7 @@ func TestStartPolling(t *testing.T) {
 
 	postChan := make(chan storage.Post, 1)
 	errChan := make(chan error, 1)
-	logInstance, err := logger.NewLogger("test.log")
-	if err != nil {
-		t.Errorf("ошибка создания логгера: %v", err)
-	}
-
-	go StartPolling([]string{mockServer.URL}, 1, postChan, errChan, logInstance)
+	go StartPolling([]string{mockServer.URL}, 1, postChan, errChan)
 
 	select {
 	case post := <-postChan:
@@ -1,7 +1,7 @@
 package storage
 
 import (
-	"APIGateway/aggregator/pkg/logger"
+	"context"
 	"sync"
 	"time"
 )
@@ -16,7 +16,8 @@ func NewMemDB() *MemDB {
 }
 
 // SavePost сохраняет пост в память
-func (m *MemDB) SavePost(post Post, logInstance *logger.Logger) error {
+func (m *MemDB) SavePost(post Post) error {
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -49,3 +50,8 @@ func (m *MemDB) GetLastPosts(limit int, fromDate time.Time) ([]Post, error) {
 
 	return result, nil
 }
+
+// Ping всегда успешен: хранилище в памяти доступно, пока жив процесс
+func (m *MemDB) Ping(ctx context.Context) error {
+	return nil
+}
@@ -1,10 +1,11 @@
 package storage
 
 import (
-	"APIGateway/aggregator/pkg/logger"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"time"
 
@@ -36,10 +37,11 @@ type Config struct {
 
 type StorageInterface interface {
 	GetLastPosts(limit, page int) ([]Post, Pagination, error)
-	SavePost(post Post, logInstance *logger.Logger) error
+	SavePost(post Post) error
 	GetPostsByTitle(search string, limit, offset int) ([]Post, Pagination, error)
 	GetPostByID(id int) (Post, error)
 	CountPostsByTitle(search string) (int, error)
+	Ping(ctx context.Context) error
 }
 
 // NewDatabase создает подключение к БД
@@ -109,16 +111,12 @@ func (s *Storage) GetLastPosts(limit, page int) ([]Post, Pagination, error) {
 }
 
 // SavePost сохраняет новость в БД
-func (s *Storage) SavePost(post Post, logInstance *logger.Logger) error {
+func (s *Storage) SavePost(post Post) error {
 	_, err := s.DB.Exec(
 		"INSERT INTO posts (title, content, pub_time, link) VALUES ($1, $2, $3, $4) ON CONFLICT (link) DO NOTHING",
 		post.Title, post.Content, post.PubTime, post.Link,
 	)
-	if err != nil {
-		logInstance.ErrorWithRequestID("Ошибка сохранения новости:", err)
-		return err
-	}
-	return nil
+	return err
 }
 
 // CountPostsByTitle получения количества новостей по фильтру
@@ -204,9 +202,14 @@ func (s *Storage) GetPostByID(id int) (Post, error) {
 	return post, nil
 }
 
+// Ping проверяет доступность БД
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
+}
+
 // LoadConfig загружает конфигурацию из JSON-файла
-func LoadConfig(filename string, logInstance *logger.Logger) (*Config, error) {
-	logInstance.InfoWithRequestID("Загрузка конфигурации из файла:", filename)
+func LoadConfig(filename string) (*Config, error) {
+	slog.Info("Загрузка конфигурации из файла", "file", filename)
 
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -218,6 +221,7 @@ func LoadConfig(filename string, logInstance *logger.Logger) (*Config, error) {
 		return nil, fmt.Errorf("ошибка парсинга конфигурации: %w", err)
 	}
 
-	logInstance.InfoWithRequestID("Загружена конфигурация:", config)
+	slog.Info("Загружена конфигурация", "feeds", len(config.RSS), "request_period", config.RequestPeriod)
+
 	return &config, nil
 }
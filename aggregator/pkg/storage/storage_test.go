@@ -1,7 +1,6 @@
 package storage
 
 import (
-	"APIGateway/aggregator/pkg/logger"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -11,11 +10,6 @@ import (
 
 func initMemDB(t *testing.T) *MemDB {
 	db := NewMemDB()
-	logInstance, err := logger.NewLogger("test.log")
-	if err != nil {
-		t.Fatalf("Ошибка создания логгера: %v", err)
-	}
-	_ = logInstance
 	return db
 }
 
@@ -30,7 +24,7 @@ func TestMemDB_SavePost(t *testing.T) {
 		Link:    "http://example.com",
 	}
 
-	err := db.SavePost(post, nil)
+	err := db.SavePost(post)
 	if err != nil {
 		t.Errorf("Ошибка при сохранении поста: %v", err)
 	}
@@ -73,7 +67,7 @@ func TestMemDB_GetLastPosts_Limit(t *testing.T) {
 			PubTime: now.Add(time.Duration(-i) * time.Hour),
 			Link:    fmt.Sprintf("http://example.com/%d", i),
 		}
-		err := db.SavePost(post, nil)
+		err := db.SavePost(post)
 		if err != nil {
 			t.Fatalf("Ошибка при сохранении поста: %v", err)
 		}
@@ -90,8 +84,6 @@ func TestMemDB_GetLastPosts_Limit(t *testing.T) {
 }
 
 func TestLoadConfig(t *testing.T) {
-	logInstance, _ := logger.NewLogger("test.log")
-
 	tempFile, err := os.CreateTemp("", "config_*.json")
 	if err != nil {
 		t.Fatalf("Ошибка создания временного файла: %v", err)
@@ -107,7 +99,7 @@ func TestLoadConfig(t *testing.T) {
 	tempFile.Write(data)
 	tempFile.Close()
 
-	loadedConfig, err := LoadConfig(tempFile.Name(), logInstance)
+	loadedConfig, err := LoadConfig(tempFile.Name())
 	if err != nil {
 		t.Fatalf("Ошибка загрузки конфигурации: %v", err)
 	}
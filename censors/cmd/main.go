@@ -4,11 +4,19 @@ package main
 import (
 	"APIGateway/censors/config"
 	"APIGateway/censors/pkg/api"
+	"APIGateway/censors/pkg/engine"
 	"APIGateway/censors/pkg/middl"
+	"APIGateway/censors/pkg/storage"
+	"APIGateway/censors/supply"
+	"APIGateway/pkg/logging"
+	"APIGateway/pkg/metrics"
+	"APIGateway/pkg/tracing"
 	"context"
 	"flag"
+	"fmt"
 	"github.com/joho/godotenv"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -23,15 +31,60 @@ func init() {
 }
 
 func main() {
+	logs, err := logging.FromEnv("censors", "CENSOR")
+	if err != nil {
+		log.Fatalf("Ошибка инициализации логгера: %v", err)
+	}
+	err = run()
+	if err != nil {
+		slog.Error("Ошибка работы сервиса цензуры", "err", err)
+	}
+	logs.Close()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// run запускает сервис цензуры и возвращает управление после его остановки.
+func run() error {
 	cfg := config.New()
+	tracer := tracing.FromEnv("censors")
 	port := flag.String("censor-port", cfg.Censor.AdrPort, "Порт для censor сервиса")
 	flag.Parse()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	api := api.New()
-	api.Router().Use(middl.Middle)
+	sources := []engine.Source{{
+		Name: "file",
+		Load: func() ([]storage.Stop, error) { return supply.Load(cfg.Censor.StopList) },
+	}}
+	var db storage.Interface
+	if cfg.Censor.URLdb != "" {
+		store, err := storage.New(ctx, cfg.Censor.URLdb)
+		if err != nil {
+			return fmt.Errorf("подключение к БД: %w", err)
+		}
+		db = store
+		metrics.Default.RegisterPgxPool("censors", store)
+		sources = append(sources, engine.Source{Name: "db", Load: store.AllList})
+	}
+
+	censor := engine.New(engine.Options{
+		Stemming: cfg.Censor.Stemming,
+		Policy:   engine.Policy{FlagScore: cfg.Censor.FlagScore, RejectScore: cfg.Censor.RejectScore},
+	}, sources...)
+	if _, err := censor.Reload(); err != nil {
+		slog.Warn("Стоп-лист загружен не полностью", "err", err)
+	}
+	slog.Info("Стоп-лист загружен", "words", censor.Matcher().Len())
+
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	defer stopReload()
+	go censor.Run(reloadCtx, cfg.Censor.ReloadInterval)
+
+	api := api.New(censor, db)
+	api.Router().Use(metrics.Middleware, middl.Middle, tracing.Middleware)
 
 	server := &http.Server{
 		Addr:    *port,
@@ -42,21 +95,30 @@ func main() {
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
+	serveErr := make(chan error, 1)
+
 	go func() {
-		log.Printf("Сервис цензуры запущен на %s", *port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Ошибка сервера: %v", err)
-		}
+		slog.Info("Сервис цензуры запущен", "addr", *port)
+		serveErr <- server.ListenAndServe()
 	}()
 
-	<-done
-	log.Print("Сервис цензуры останавливается...")
+	var err error
+	select {
+	case <-done:
+		slog.Info("Сервис цензуры останавливается...")
+	case err = <-serveErr:
+		err = fmt.Errorf("запуск сервера: %w", err)
+	}
 
 	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Ошибка при остановке сервера: %v", err)
+	if shutdownErr := server.Shutdown(ctx); shutdownErr != nil && err == nil {
+		err = fmt.Errorf("остановка сервера: %w", shutdownErr)
+	}
+	if err := tracer.Shutdown(ctx); err != nil {
+		slog.Error("Ошибка отправки трасс", "err", err)
 	}
-	log.Print("Сервис цензуры остановлен")
+	slog.Info("Сервис цензуры остановлен")
+	return err
 }
@@ -1,7 +1,12 @@
 // config/config.go
 package config
 
-import "os"
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
 
 type Config struct {
 	Censor Censor
@@ -9,12 +14,28 @@ type Config struct {
 
 type Censor struct {
 	AdrPort string
+	URLdb   string
+	// StopList — файл стоп-листа, дополняющий таблицу stop.
+	StopList string
+	// ReloadInterval — как часто перечитывать стоп-лист.
+	ReloadInterval time.Duration
+	// Stemming включает русский стемминг при поиске стоп-слов.
+	Stemming bool
+	// FlagScore и RejectScore — пороги балла, при которых комментарий уходит на модерацию или отклоняется.
+	FlagScore   int
+	RejectScore int
 }
 
 func New() *Config {
 	return &Config{
 		Censor: Censor{
-			AdrPort: getEnv("CENSOR_PORT", ":8083"),
+			AdrPort:        getEnv("CENSOR_PORT", ":8083"),
+			URLdb:          getEnv("CENSOR_DB", ""),
+			StopList:       getEnv("CENSOR_STOP_LIST", "censors/supply/words.txt"),
+			ReloadInterval: getEnvDuration("CENSOR_RELOAD_INTERVAL", 30*time.Second),
+			Stemming:       getEnvBool("CENSOR_STEMMING", false),
+			FlagScore:      getEnvInt("CENSOR_FLAG_SCORE", 3),
+			RejectScore:    getEnvInt("CENSOR_REJECT_SCORE", 5),
 		},
 	}
 }
@@ -25,3 +46,43 @@ func getEnv(key string, defaultVal string) string {
 	}
 	return defaultVal
 }
+
+func getEnvDuration(key string, defaultVal time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultVal
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		slog.Warn("invalid configuration value, using default", "key", key, "value", value, "default", defaultVal)
+		return defaultVal
+	}
+	return d
+}
+
+func getEnvBool(key string, defaultVal bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultVal
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("invalid configuration value, using default", "key", key, "value", value, "default", defaultVal)
+		return defaultVal
+	}
+	return b
+}
+
+func getEnvInt(key string, defaultVal int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultVal
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		slog.Warn("invalid configuration value, using default", "key", key, "value", value, "default", defaultVal)
+
+		return defaultVal
+	}
+	return n
+}
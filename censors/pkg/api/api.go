@@ -2,20 +2,32 @@
 package api
 
 import (
+	"APIGateway/censors/pkg/engine"
+	"APIGateway/censors/pkg/storage"
+	"APIGateway/pkg/metrics"
 	"encoding/json"
 	"github.com/gorilla/mux"
-	"log"
+	"log/slog"
 	"net/http"
-	"strings"
+)
+
+var (
+	verdictsTotal = metrics.NewCounter("censor_verdicts_total", "Checked texts by verdict action.", "action")
+	matchesTotal  = metrics.NewCounter("censor_matches_total", "Stop words found in checked texts by category.", "category")
 )
 
 type API struct {
 	router *mux.Router
+	engine *engine.Engine
+	db     storage.Interface
 }
 
-func New() *API {
+// New создаёт API цензора. db может быть nil — тогда управление стоп-листом недоступно.
+func New(e *engine.Engine, db storage.Interface) *API {
 	api := API{
 		router: mux.NewRouter(),
+		engine: e,
+		db:     db,
 	}
 	api.endpoints()
 	return &api
@@ -27,8 +39,33 @@ func (api *API) Router() *mux.Router {
 
 func (api *API) endpoints() {
 	api.router.HandleFunc("/censor", api.handleCensor).Methods(http.MethodPost, http.MethodOptions)
+	api.router.HandleFunc("/healthz", api.handleHealth).Methods(http.MethodGet)
+	api.router.HandleFunc("/readyz", api.handleReady).Methods(http.MethodGet)
+	api.router.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
+	if api.db != nil {
+		api.stopEndpoints()
+	}
+}
+
+// handleHealth отвечает на проверку живости.
+func (api *API) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// handleReady сообщает о готовности, когда стоп-лист загружен хотя бы из одного источника.
+func (api *API) handleReady(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if !api.engine.Ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"status": "unavailable", "error": "stop list is not loaded"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
+// handleCensor проверяет текст и возвращает вердикт: найденные стоп-слова с позициями,
+// категории, балл, действие и текст с замаскированными стоп-словами.
 func (api *API) handleCensor(w http.ResponseWriter, r *http.Request) {
 	var request struct {
 		Text string `json:"text"`
@@ -39,21 +76,12 @@ func (api *API) handleCensor(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Filtering text: %s", request.Text)
-
-	stopWords := []string{"qwerty", "йцукен", "zxvbnm"}
-	filtered := request.Text
-
-	for _, word := range stopWords {
-		filtered = strings.ReplaceAll(filtered, word, "***")
+	verdict := api.engine.Check(request.Text)
+	slog.InfoContext(r.Context(), "censor verdict", "action", verdict.Action, "score", verdict.Score, "categories", verdict.Categories)
+	verdictsTotal.Inc(string(verdict.Action))
+	for _, m := range verdict.Matches {
+		matchesTotal.Inc(m.Category)
 	}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8") // <- добавь charset=utf-8
-	resp := map[string]string{
-		"text": filtered,
-	}
-
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		log.Printf("Error encoding response: %v", err)
-	}
+	writeJSON(w, http.StatusOK, verdict)
 }
@@ -1,39 +1,162 @@
 package api
 
 import (
+	"APIGateway/censors/pkg/engine"
+	"APIGateway/censors/pkg/storage"
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 )
 
+// memStore — стоп-лист в памяти для тестов.
+type memStore struct {
+	list   []storage.Stop
+	nextID int
+}
+
+func newMemStore(words ...string) *memStore {
+	m := &memStore{}
+	for _, w := range words {
+		m.AddList(storage.Stop{StopList: w})
+	}
+	return m
+}
+
+func (m *memStore) AllList() ([]storage.Stop, error) {
+	return append([]storage.Stop(nil), m.list...), nil
+}
+
+func (m *memStore) AddList(c storage.Stop) error {
+	_, err := m.AddMany([]storage.Stop{c})
+	return err
+}
+
+func (m *memStore) Page(q storage.Query) ([]storage.Stop, int, error) {
+	var filtered []storage.Stop
+	for _, s := range m.list {
+		if q.Category == "" || s.Category == q.Category {
+			filtered = append(filtered, s)
+		}
+	}
+	total := len(filtered)
+	if q.Offset > total {
+		q.Offset = total
+	}
+	end := min(total, q.Offset+q.Limit)
+	return filtered[q.Offset:end], total, nil
+}
+
+func (m *memStore) AddMany(list []storage.Stop) ([]storage.Stop, error) {
+	added := []storage.Stop{}
+	for _, c := range list {
+		if err := c.Normalize(); err != nil {
+			return nil, err
+		}
+		if m.index(c.StopList) >= 0 {
+			continue
+		}
+		m.nextID++
+		c.ID = m.nextID
+		m.list = append(m.list, c)
+		added = append(added, c)
+	}
+	return added, nil
+}
+
+func (m *memStore) GetStop(id int) (storage.Stop, error) {
+	for _, s := range m.list {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return storage.Stop{}, storage.ErrNotFound
+}
+
+func (m *memStore) UpdateStop(s storage.Stop) error {
+	if i := m.index(s.StopList); i >= 0 && m.list[i].ID != s.ID {
+		return storage.ErrDuplicate
+	}
+	for i := range m.list {
+		if m.list[i].ID == s.ID {
+			m.list[i] = s
+			return nil
+		}
+	}
+	return storage.ErrNotFound
+}
+
+func (m *memStore) DeleteStop(id int) error {
+	for i := range m.list {
+		if m.list[i].ID == id {
+			m.list = append(m.list[:i], m.list[i+1:]...)
+			return nil
+		}
+	}
+	return storage.ErrNotFound
+}
+
+func (m *memStore) index(word string) int {
+	for i, s := range m.list {
+		if s.StopList == word {
+			return i
+		}
+	}
+	return -1
+}
+
+func newTestAPI(t *testing.T, words ...string) *API {
+	t.Helper()
+	return newTestAPIWithStore(t, newMemStore(words...))
+}
+
+func newTestAPIWithStore(t *testing.T, db *memStore) *API {
+	t.Helper()
+	e := engine.New(engine.Options{}, engine.Source{Name: "db", Load: db.AllList})
+	if _, err := e.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	return New(e, db)
+}
+
 func TestCheckHandler(t *testing.T) {
-	api := New()
+	db := newMemStore()
+	db.AddList(storage.Stop{StopList: "qwerty", Category: "spam", Severity: 1})
+	db.AddList(storage.Stop{StopList: "йцукен", Category: "insult", Severity: 3})
+	db.AddList(storage.Stop{StopList: "zxvbnm", Category: "threat", Severity: 5})
+	api := newTestAPIWithStore(t, db)
 
 	tests := []struct {
 		name       string
 		body       []byte
 		wantStatus int
+		wantAction engine.Action
 	}{
 		{
 			name:       "clean comment",
-			body:       []byte(`{"content": "Это нормальный комментарий"}`),
+			body:       []byte(`{"text": "Это нормальный комментарий"}`),
 			wantStatus: http.StatusOK,
+			wantAction: engine.ActionAllow,
 		},
 		{
 			name:       "contains forbidden word qwerty",
-			body:       []byte(`{"content": "это qwerty сообщение"}`),
-			wantStatus: http.StatusBadRequest,
+			body:       []byte(`{"text": "это qwerty сообщение"}`),
+			wantStatus: http.StatusOK,
+			wantAction: engine.ActionMask,
 		},
 		{
 			name:       "contains forbidden word йцукен",
-			body:       []byte(`{"content": "это йцукен слово"}`),
-			wantStatus: http.StatusBadRequest,
+			body:       []byte(`{"text": "это йцукен слово"}`),
+			wantStatus: http.StatusOK,
+			wantAction: engine.ActionFlag,
 		},
 		{
 			name:       "contains forbidden word zxvbnm",
-			body:       []byte(`{"content": "плохое zxvbnm слово"}`),
-			wantStatus: http.StatusBadRequest,
+			body:       []byte(`{"text": "плохое zxvbnm слово"}`),
+			wantStatus: http.StatusOK,
+			wantAction: engine.ActionReject,
 		},
 		{
 			name:       "invalid json",
@@ -43,12 +166,163 @@ func TestCheckHandler(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		req := httptest.NewRequest(http.MethodPost, "/check", bytes.NewBuffer(tt.body))
+		req := httptest.NewRequest(http.MethodPost, "/censor", bytes.NewBuffer(tt.body))
 		rr := httptest.NewRecorder()
 		api.Router().ServeHTTP(rr, req)
 
 		if rr.Code != tt.wantStatus {
 			t.Errorf("[%s] expected status %d, got %d", tt.name, tt.wantStatus, rr.Code)
+			continue
+		}
+		if tt.wantAction == "" {
+			continue
+		}
+		var v engine.Verdict
+		if err := json.NewDecoder(rr.Body).Decode(&v); err != nil {
+			t.Fatalf("[%s] decode: %v", tt.name, err)
+		}
+		if v.Action != tt.wantAction {
+			t.Errorf("[%s] expected action %s, got %s", tt.name, tt.wantAction, v.Action)
 		}
+		if tt.wantAction != engine.ActionAllow && (len(v.Matches) != 1 || v.Matches[0].End-v.Matches[0].Start != 6) {
+			t.Errorf("[%s] unexpected matches: %+v", tt.name, v.Matches)
+		}
+	}
+}
+
+func TestCensorHandler(t *testing.T) {
+	api := newTestAPI(t, "qwerty", "йцукен")
+
+	req := httptest.NewRequest(http.MethodPost, "/censor", bytes.NewBufferString(`{"text": "это QWERTY и йцукен"}`))
+	rr := httptest.NewRecorder()
+	api.Router().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	var resp struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if want := "это *** и ***"; resp.Text != want {
+		t.Errorf("expected %q, got %q", want, resp.Text)
+	}
+}
+
+func TestReadyzHandler(t *testing.T) {
+	api := New(engine.New(engine.Options{}), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	rr := httptest.NewRecorder()
+	api.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 before the stop list is loaded, got %d", rr.Code)
+	}
+
+	api = newTestAPI(t, "qwerty")
+	rr = httptest.NewRecorder()
+	api.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rr.Code)
+	}
+}
+
+func TestStopListCRUD(t *testing.T) {
+	api := newTestAPI(t, "qwerty")
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		api.Router().ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve(http.MethodPost, "/stop", `{"stopList": "йцукен", "category": "Spam", "severity": 3}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("add: expected status 201, got %d: %s", rr.Code, rr.Body)
+	}
+	var added storage.Stop
+	json.NewDecoder(rr.Body).Decode(&added)
+	if added.ID == 0 || added.Category != "spam" || added.Severity != 3 {
+		t.Errorf("unexpected entry: %+v", added)
+	}
+	if got := api.engine.Mask("йцукен"); got != "***" {
+		t.Errorf("added word is not applied by the censor: %q", got)
+	}
+
+	if rr := serve(http.MethodPost, "/stop", `{"stopList": "йцукен"}`); rr.Code != http.StatusConflict {
+		t.Errorf("duplicate: expected status 409, got %d", rr.Code)
+	}
+	if rr := serve(http.MethodPost, "/stop", `{"stopList": "x", "severity": 9}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("bad severity: expected status 400, got %d", rr.Code)
+	}
+
+	rr = serve(http.MethodPost, "/stop", `[{"stopList": "zxvbnm"}, {"stopList": "qwerty"}, {"stopList": "asdfgh"}]`)
+	var bulk []storage.Stop
+	json.NewDecoder(rr.Body).Decode(&bulk)
+	if rr.Code != http.StatusCreated || len(bulk) != 2 {
+		t.Fatalf("bulk add: expected 201 with 2 new entries, got %d: %+v", rr.Code, bulk)
+	}
+
+	rr = serve(http.MethodGet, "/stop?limit=2&offset=1", "")
+	var page []storage.Stop
+	json.NewDecoder(rr.Body).Decode(&page)
+	if rr.Code != http.StatusOK || len(page) != 2 || page[0].StopList != "йцукен" {
+		t.Errorf("page: unexpected response %d %+v", rr.Code, page)
+	}
+	if got := rr.Header().Get("X-Total-Count"); got != "4" {
+		t.Errorf("X-Total-Count = %q, want 4", got)
+	}
+	rr = serve(http.MethodGet, "/stop?category=%20Spam", "")
+	if got := rr.Header().Get("X-Total-Count"); got != "1" {
+		t.Errorf("category filter: X-Total-Count = %q, want 1", got)
+	}
+	if rr := serve(http.MethodGet, "/stop?limit=0", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("bad limit: expected status 400, got %d", rr.Code)
+	}
+
+	target := fmt.Sprintf("/stop/%d", added.ID)
+	rr = serve(http.MethodPut, target, `{"stopList": "фыва", "category": "insult", "severity": 5}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("update: expected status 200, got %d: %s", rr.Code, rr.Body)
+	}
+	if got := api.engine.Mask("йцукен фыва"); got != "йцукен ***" {
+		t.Errorf("update is not applied by the censor: %q", got)
+	}
+	if rr := serve(http.MethodPut, target, `{"stopList": "qwerty"}`); rr.Code != http.StatusConflict {
+		t.Errorf("update to duplicate: expected status 409, got %d", rr.Code)
+	}
+
+	if rr := serve(http.MethodDelete, target, ""); rr.Code != http.StatusNoContent {
+		t.Errorf("delete: expected status 204, got %d", rr.Code)
+	}
+	if rr := serve(http.MethodGet, target, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("get deleted: expected status 404, got %d", rr.Code)
+	}
+	if rr := serve(http.MethodDelete, target, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("delete twice: expected status 404, got %d", rr.Code)
+	}
+}
+
+func TestStopListImportExport(t *testing.T) {
+	api := newTestAPI(t, "qwerty")
+
+	req := httptest.NewRequest(http.MethodPost, "/stop/import?category=spam&severity=2",
+		bytes.NewBufferString("qwerty\n  йцукен \n\nzxvbnm\n"))
+	rr := httptest.NewRecorder()
+	api.Router().ServeHTTP(rr, req)
+	var res map[string]int
+	json.NewDecoder(rr.Body).Decode(&res)
+	if rr.Code != http.StatusOK || res["added"] != 2 || res["skipped"] != 1 {
+		t.Fatalf("import: unexpected response %d %v", rr.Code, res)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/stop/export?category=SPAM", nil)
+	rr = httptest.NewRecorder()
+	api.Router().ServeHTTP(rr, req)
+	if want := "йцукен\nzxvbnm\n"; rr.Body.String() != want {
+		t.Errorf("export = %q, want %q", rr.Body.String(), want)
 	}
 }
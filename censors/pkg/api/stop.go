@@ -0,0 +1,258 @@
+package api
+
+import (
+	"APIGateway/censors/pkg/storage"
+	"APIGateway/censors/supply"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultStopLimit = 50
+	maxStopLimit     = 500
+	// maxImportSize ограничивает размер импортируемого файла стоп-листа.
+	maxImportSize = 10 << 20
+)
+
+func (api *API) stopEndpoints() {
+	api.router.HandleFunc("/stop", api.handleStopList).Methods(http.MethodGet)
+	api.router.HandleFunc("/stop", api.handleStopAdd).Methods(http.MethodPost)
+	api.router.HandleFunc("/stop/export", api.handleStopExport).Methods(http.MethodGet)
+	api.router.HandleFunc("/stop/import", api.handleStopImport).Methods(http.MethodPost)
+	api.router.HandleFunc("/stop/{id:[0-9]+}", api.handleStopGet).Methods(http.MethodGet)
+	api.router.HandleFunc("/stop/{id:[0-9]+}", api.handleStopUpdate).Methods(http.MethodPut)
+	api.router.HandleFunc("/stop/{id:[0-9]+}", api.handleStopDelete).Methods(http.MethodDelete)
+}
+
+// handleStopList возвращает страницу стоп-листа; общее число записей — в заголовке X-Total-Count.
+func (api *API) handleStopList(w http.ResponseWriter, r *http.Request) {
+	q, err := stopQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	list, total, err := api.db.Page(q)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to list stop words", "err", err)
+		http.Error(w, "storage error", http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		list = []storage.Stop{}
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleStopAdd добавляет одно слово (JSON-объект) или несколько (JSON-массив).
+func (api *API) handleStopAdd(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "read body", http.StatusBadRequest)
+		return
+	}
+
+	var list []storage.Stop
+	bulk := len(bytes.TrimSpace(body)) > 0 && bytes.TrimSpace(body)[0] == '['
+	if bulk {
+		err = json.Unmarshal(body, &list)
+	} else {
+		var s storage.Stop
+		err = json.Unmarshal(body, &s)
+		list = []storage.Stop{s}
+	}
+	if err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	for i := range list {
+		if err := list[i].Normalize(); err != nil {
+			http.Error(w, fmt.Sprintf("entry #%d: %v", i, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	added, err := api.db.AddMany(list)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to add stop words", "err", err)
+		http.Error(w, "storage error", http.StatusInternalServerError)
+		return
+	}
+	api.reload(r.Context())
+
+	if !bulk {
+		if len(added) == 0 {
+			http.Error(w, storage.ErrDuplicate.Error(), http.StatusConflict)
+			return
+		}
+		writeJSON(w, http.StatusCreated, added[0])
+		return
+	}
+	writeJSON(w, http.StatusCreated, added)
+}
+
+func (api *API) handleStopGet(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	s, err := api.db.GetStop(id)
+	if err != nil {
+		stopError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// handleStopUpdate заменяет слово, категорию и серьёзность записи.
+func (api *API) handleStopUpdate(w http.ResponseWriter, r *http.Request) {
+	var s storage.Stop
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	s.ID, _ = strconv.Atoi(mux.Vars(r)["id"])
+	if err := s.Normalize(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := api.db.UpdateStop(s); err != nil {
+		stopError(w, r, err)
+		return
+	}
+	api.reload(r.Context())
+	writeJSON(w, http.StatusOK, s)
+}
+
+func (api *API) handleStopDelete(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := api.db.DeleteStop(id); err != nil {
+		stopError(w, r, err)
+		return
+	}
+	api.reload(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleStopExport выгружает стоп-лист в формате words.txt.
+func (api *API) handleStopExport(w http.ResponseWriter, r *http.Request) {
+	list, err := api.db.AllList()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to export stop words", "err", err)
+		http.Error(w, "storage error", http.StatusInternalServerError)
+		return
+	}
+	if category := queryCategory(r); category != "" {
+		filtered := list[:0]
+		for _, s := range list {
+			if s.Category == category {
+				filtered = append(filtered, s)
+			}
+		}
+		list = filtered
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="words.txt"`)
+	if err := supply.Write(w, list); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write export", "err", err)
+	}
+}
+
+// handleStopImport загружает слова в формате words.txt. Категория и серьёзность
+// задаются параметрами category и severity и применяются ко всем словам файла.
+func (api *API) handleStopImport(w http.ResponseWriter, r *http.Request) {
+	severity := 0
+	if v := r.URL.Query().Get("severity"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid severity", http.StatusBadRequest)
+			return
+		}
+		severity = n
+	}
+
+	list, err := supply.Parse(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		http.Error(w, "read body", http.StatusBadRequest)
+		return
+	}
+	for i := range list {
+		list[i].Category = r.URL.Query().Get("category")
+		list[i].Severity = severity
+		if err := list[i].Normalize(); err != nil {
+			http.Error(w, fmt.Sprintf("line %q: %v", list[i].StopList, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	added, err := api.db.AddMany(list)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to import stop words", "err", err)
+		http.Error(w, "storage error", http.StatusInternalServerError)
+		return
+	}
+	api.reload(r.Context())
+	writeJSON(w, http.StatusOK, map[string]int{
+		"added":   len(added),
+		"skipped": len(list) - len(added),
+	})
+}
+
+// reload применяет изменения стоп-листа сразу, не дожидаясь периодической перезагрузки.
+func (api *API) reload(ctx context.Context) {
+	if _, err := api.engine.Reload(); err != nil {
+		slog.ErrorContext(ctx, "failed to reload stop list", "err", err)
+	}
+}
+
+func stopQuery(r *http.Request) (storage.Query, error) {
+	q := storage.Query{Limit: defaultStopLimit, Category: queryCategory(r)}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxStopLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxStopLimit)
+		}
+		q.Limit = n
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return q, errors.New("offset must be a non-negative integer")
+		}
+		q.Offset = n
+	}
+	return q, nil
+}
+
+// queryCategory приводит фильтр ?category= к виду, в котором категории хранятся (см. Stop.Normalize).
+func queryCategory(r *http.Request) string {
+	return strings.ToLower(strings.TrimSpace(r.URL.Query().Get("category")))
+}
+
+func stopError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, storage.ErrDuplicate):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		slog.ErrorContext(r.Context(), "stop list storage error", "err", err)
+		http.Error(w, "storage error", http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("failed to encode response", "err", err)
+	}
+}
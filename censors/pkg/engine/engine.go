@@ -0,0 +1,150 @@
+// Package engine хранит скомпилированный стоп-лист цензора и перезагружает его при изменении источников.
+package engine
+
+import (
+	"APIGateway/censors/pkg/storage"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Source — источник стоп-слов, например storage.Interface.AllList или supply.StopList.
+type Source struct {
+	Name string
+	Load func() ([]storage.Stop, error)
+}
+
+// Engine — цензор с подменяемым без блокировок автоматом.
+// Если источник недоступен, используются его последние успешно загруженные слова.
+type Engine struct {
+	opts    Options
+	sources []Source
+
+	matcher atomic.Pointer[Matcher]
+
+	mu   sync.Mutex
+	last map[string][]storage.Stop
+	sum  [sha256.Size]byte
+}
+
+// New создаёт движок с пустым стоп-листом; слова загружаются вызовом Reload.
+func New(opts Options, sources ...Source) *Engine {
+	e := &Engine{opts: opts, sources: sources, last: make(map[string][]storage.Stop)}
+	e.matcher.Store(Compile(nil, opts))
+	return e
+}
+
+// Matcher возвращает текущий автомат.
+func (e *Engine) Matcher() *Matcher {
+	return e.matcher.Load()
+}
+
+// Mask заменяет стоп-слова в тексте на "***".
+func (e *Engine) Mask(text string) string {
+	return e.Matcher().Mask(text)
+}
+
+// Check выносит вердикт по тексту согласно политике движка.
+func (e *Engine) Check(text string) Verdict {
+	return e.opts.Policy.Verdict(e.Matcher(), text)
+}
+
+// Ready сообщает, загружен ли стоп-лист хотя бы из одного источника.
+func (e *Engine) Ready() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.last) > 0
+}
+
+// Reload перечитывает все источники и, если набор слов изменился, компилирует и подменяет автомат.
+// Ошибки источников возвращаются вместе, но не мешают обновить слова из остальных.
+func (e *Engine) Reload() (changed bool, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var errs []error
+	for _, src := range e.sources {
+		list, err := src.Load()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", src.Name, err))
+			continue
+		}
+		e.last[src.Name] = list
+	}
+
+	terms := e.terms()
+	h := sha256.New()
+	for _, t := range terms {
+		fmt.Fprintf(h, "%s\t%s\t%d\n", t.Word, t.Category, t.Severity)
+	}
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	if sum != e.sum {
+		e.sum = sum
+		e.matcher.Store(Compile(terms, e.opts))
+		changed = true
+	}
+	return changed, errors.Join(errs...)
+}
+
+// terms объединяет слова всех источников: без пробелов по краям, в нижнем регистре, без повторов.
+// Если слово есть в нескольких источниках, берётся запись с наибольшей серьёзностью.
+func (e *Engine) terms() []Term {
+	byWord := make(map[string]Term)
+	for _, list := range e.last {
+		for _, s := range list {
+			t := Term{
+				Word:     strings.ToLower(strings.TrimSpace(s.StopList)),
+				Category: s.Category,
+				Severity: s.Severity,
+			}
+			if t.Word == "" {
+				continue
+			}
+			if t.Category == "" {
+				t.Category = storage.DefaultCategory
+			}
+			if t.Severity == 0 {
+				t.Severity = storage.SeverityMin
+			}
+			prev, ok := byWord[t.Word]
+			if !ok || t.Severity > prev.Severity || (t.Severity == prev.Severity && t.Category < prev.Category) {
+				byWord[t.Word] = t
+			}
+		}
+	}
+
+	terms := make([]Term, 0, len(byWord))
+	for _, t := range byWord {
+		terms = append(terms, t)
+	}
+	slices.SortFunc(terms, func(a, b Term) int { return strings.Compare(a.Word, b.Word) })
+	return terms
+}
+
+// Run перезагружает стоп-лист каждые interval до отмены ctx.
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := e.Reload()
+			if err != nil {
+				slog.ErrorContext(ctx, "stop list reload failed", "err", err)
+			}
+			if changed {
+				slog.InfoContext(ctx, "stop list reloaded", "words", e.Matcher().Len())
+			}
+		}
+	}
+}
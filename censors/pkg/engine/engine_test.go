@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"APIGateway/censors/pkg/storage"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func words(ws ...string) []Term {
+	terms := make([]Term, 0, len(ws))
+	for _, w := range ws {
+		terms = append(terms, Term{Word: w})
+	}
+	return terms
+}
+
+func TestMatcherFindAll(t *testing.T) {
+	m := Compile(words("qwerty", "йцукен"), Options{})
+	got := m.FindAll("Ну й.ц.у.к.е.н, QWEERTY!")
+	want := []Match{
+		{Start: 3, End: 14, Word: "йцукен"},
+		{Start: 16, End: 23, Word: "qwerty"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindAll() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMatcherMask(t *testing.T) {
+	m := Compile(words("qwerty", "йцукен", "ёжик"), Options{})
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"clean", "чистый текст", "чистый текст"},
+		{"plain", "это qwerty сообщение", "это *** сообщение"},
+		{"case", "ЙЦУКЕН и Qwerty", "*** и ***"},
+		{"separators", "это q.w.e.r.t.y и й-ц-у-к-е-н", "это *** и ***"},
+		{"homoglyph", "qw\u0435rty", "***"},
+		{"cyrillic lookalikes", "\u0443\u0430", "\u0443\u0430"},
+		{"leetspeak", "qw3rty", "***"},
+		{"repeated letters", "qwwweeeerty!!!", "***!!!"},
+		{"fullwidth", "ｑｗｅｒｔｙ", "***"},
+		{"combining marks", "q\u0336w\u0336e\u0336r\u0336t\u0336y\u0336", "***"},
+		{"yo", "ежик", "***"},
+		{"punctuation around", "(qwerty), «йцукен»", "(***), «***»"},
+		{"inside a word", "йцукенщина и aqwertyb", "йцукенщина и aqwertyb"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		if got := m.Mask(tt.text); got != tt.want {
+			t.Errorf("[%s] Mask(%q) = %q, want %q", tt.name, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMatcherStemming(t *testing.T) {
+	terms := words("дурак", "скотина")
+	plain := Compile(terms, Options{})
+	stemmed := Compile(terms, Options{Stemming: true})
+
+	tests := []struct {
+		text        string
+		plain, stem string
+	}{
+		{"сам дурак", "сам ***", "сам ***"},
+		{"эти дураки", "эти дураки", "эти ***"},
+		{"дураками", "дураками", "***"},
+		{"со скотиной", "со скотиной", "со ***"},
+		{"дуракаваляние", "дуракаваляние", "дуракаваляние"},
+	}
+	for _, tt := range tests {
+		if got := plain.Mask(tt.text); got != tt.plain {
+			t.Errorf("plain Mask(%q) = %q, want %q", tt.text, got, tt.plain)
+		}
+		if got := stemmed.Mask(tt.text); got != tt.stem {
+			t.Errorf("stemmed Mask(%q) = %q, want %q", tt.text, got, tt.stem)
+		}
+	}
+}
+
+func TestEngineReload(t *testing.T) {
+	db := []storage.Stop{{StopList: "qwerty"}}
+	var dbErr error
+	e := New(Options{},
+		Source{Name: "db", Load: func() ([]storage.Stop, error) { return db, dbErr }},
+		Source{Name: "file", Load: func() ([]storage.Stop, error) {
+			return []storage.Stop{{StopList: " Zxvbnm "}, {StopList: ""}}, nil
+		}},
+	)
+	if e.Ready() {
+		t.Fatal("engine must not be ready before the first reload")
+	}
+
+	changed, err := e.Reload()
+	if err != nil || !changed {
+		t.Fatalf("Reload() = %v, %v; want true, nil", changed, err)
+	}
+	if got := e.Mask("qwerty zxvbnm йцукен"); got != "*** *** йцукен" {
+		t.Errorf("unexpected mask: %q", got)
+	}
+
+	if changed, _ := e.Reload(); changed {
+		t.Error("reload without changes must not swap the matcher")
+	}
+
+	db = append(db, storage.Stop{StopList: "йцукен"})
+	if changed, _ := e.Reload(); !changed {
+		t.Error("new word in the db must trigger a reload")
+	}
+	if got := e.Mask("йцукен"); got != "***" {
+		t.Errorf("new word is not masked: %q", got)
+	}
+
+	// Недоступная БД не должна стирать ранее загруженные из неё слова.
+	dbErr = errors.New("connection refused")
+	changed, err = e.Reload()
+	if err == nil || changed {
+		t.Fatalf("Reload() = %v, %v; want false and an error", changed, err)
+	}
+	if got := e.Mask("qwerty"); got != "***" {
+		t.Errorf("words from the failed source were dropped: %q", got)
+	}
+}
+
+func TestPolicyVerdict(t *testing.T) {
+	m := Compile([]Term{
+		{Word: "qwerty", Category: "spam", Severity: 1},
+		{Word: "йцукен", Category: "insult", Severity: 3},
+		{Word: "zxvbnm", Category: "threat", Severity: 5},
+	}, Options{})
+	p := DefaultPolicy()
+
+	tests := []struct {
+		text       string
+		action     Action
+		score      int
+		categories []string
+		masked     string
+	}{
+		{"чистый текст", ActionAllow, 0, []string{}, "чистый текст"},
+		{"это qwerty", ActionMask, 1, []string{"spam"}, "это ***"},
+		{"qwerty и qwerty", ActionMask, 2, []string{"spam"}, "*** и ***"},
+		{"ты йцукен", ActionFlag, 3, []string{"insult"}, "ты ***"},
+		{"qwerty йцукен", ActionFlag, 4, []string{"insult", "spam"}, "*** ***"},
+		{"zxvbnm", ActionReject, 5, []string{"threat"}, "***"},
+		{"йцукен и ещё раз йцукен", ActionReject, 6, []string{"insult"}, "*** и ещё раз ***"},
+	}
+	for _, tt := range tests {
+		v := p.Verdict(m, tt.text)
+		if v.Action != tt.action || v.Score != tt.score || v.Text != tt.masked || !reflect.DeepEqual(v.Categories, tt.categories) {
+			t.Errorf("Verdict(%q) = %s/%d/%v/%q, want %s/%d/%v/%q", tt.text,
+				v.Action, v.Score, v.Categories, v.Text, tt.action, tt.score, tt.categories, tt.masked)
+		}
+		if v.Action != ActionAllow && v.Reason == "" {
+			t.Errorf("Verdict(%q): empty reason", tt.text)
+		}
+	}
+
+	if v := (Policy{}).Verdict(m, "ты йцукен"); v.Action != ActionFlag {
+		t.Errorf("zero policy must fall back to defaults, got %s", v.Action)
+	}
+	if v := (Policy{FlagScore: 10, RejectScore: 20}).Verdict(m, "zxvbnm"); v.Action != ActionMask {
+		t.Errorf("custom thresholds are ignored, got %s", v.Action)
+	}
+}
@@ -0,0 +1,197 @@
+package engine
+
+// Term — стоп-слово с категорией и серьёзностью.
+type Term struct {
+	Word     string
+	Category string
+	Severity int
+}
+
+// Match — вхождение стоп-слова в текст. Start и End — индексы рун исходного текста, End не включается.
+type Match struct {
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	Word     string `json:"word"`
+	Category string `json:"category,omitempty"`
+	Severity int    `json:"severity,omitempty"`
+}
+
+// Options — параметры движка.
+type Options struct {
+	// Stemming включает русский стемминг: стоп-слово совпадает и с другими словоформами.
+	Stemming bool
+	// Policy — пороги вердикта; нулевые пороги заменяются значениями DefaultPolicy.
+	Policy Policy
+}
+
+// Matcher ищет все стоп-слова за один проход по нормализованному тексту (автомат Ахо — Корасик).
+// Совпадением считается только целое слово. Matcher неизменяем и безопасен для конкурентного использования.
+type Matcher struct {
+	opts  Options
+	nodes []node
+	// terms и lens — стоп-слово и длина в рунах для каждого варианта в боре.
+	terms []Term
+	lens  []int
+	// count — число стоп-слов.
+	count int
+}
+
+type node struct {
+	next map[rune]int
+	fail int
+	// out — индексы слов, оканчивающихся в этом узле, включая достижимые по fail-ссылкам.
+	out []int
+}
+
+// Compile строит автомат по списку стоп-слов. Слова, пустые после нормализации, пропускаются.
+func Compile(terms []Term, opts Options) *Matcher {
+	m := &Matcher{opts: opts, nodes: []node{{next: map[rune]int{}}}}
+	for _, t := range terms {
+		runes := normalizeWord(t.Word)
+		if len(runes) == 0 {
+			continue
+		}
+		variants := [][]rune{runes}
+		if opts.Stemming {
+			variants = stems(runes)
+		}
+		m.count++
+		for _, v := range variants {
+			m.insert(v, t)
+		}
+	}
+	m.link()
+	return m
+}
+
+// insert добавляет в бор нормализованный вариант runes стоп-слова t.
+func (m *Matcher) insert(runes []rune, t Term) {
+	cur := 0
+	for _, r := range runes {
+		nxt, ok := m.nodes[cur].next[r]
+		if !ok {
+			nxt = len(m.nodes)
+			m.nodes = append(m.nodes, node{next: map[rune]int{}})
+			m.nodes[cur].next[r] = nxt
+		}
+		cur = nxt
+	}
+	m.nodes[cur].out = append(m.nodes[cur].out, len(m.terms))
+	m.terms = append(m.terms, t)
+	m.lens = append(m.lens, len(runes))
+}
+
+// link проставляет fail-ссылки обходом в ширину.
+func (m *Matcher) link() {
+	queue := make([]int, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[cur].next {
+			f := m.nodes[cur].fail
+			for f != 0 {
+				if _, ok := m.nodes[f].next[r]; ok {
+					break
+				}
+				f = m.nodes[f].fail
+			}
+			if nxt, ok := m.nodes[f].next[r]; ok && nxt != child {
+				m.nodes[child].fail = nxt
+			}
+			fail := m.nodes[child].fail
+			m.nodes[child].out = append(m.nodes[child].out, m.nodes[fail].out...)
+			queue = append(queue, child)
+		}
+	}
+}
+
+// Len возвращает число слов в автомате.
+func (m *Matcher) Len() int {
+	return m.count
+}
+
+// FindAll возвращает вхождения стоп-слов в порядке их окончания.
+// Каждый участок исходного текста возвращается не более одного раза.
+func (m *Matcher) FindAll(text string) []Match {
+	n := normalize(text)
+	var matches []Match
+	seen := map[[2]int]bool{}
+	cur := 0
+	for i, r := range n.runes {
+		for {
+			if nxt, ok := m.nodes[cur].next[r]; ok {
+				cur = nxt
+				break
+			}
+			if cur == 0 {
+				break
+			}
+			cur = m.nodes[cur].fail
+		}
+		for _, idx := range m.nodes[cur].out {
+			mt, ok := m.match(n, i+1-m.lens[idx], i+1)
+			if !ok || seen[[2]int{mt.Start, mt.End}] {
+				continue
+			}
+			seen[[2]int{mt.Start, mt.End}] = true
+			t := m.terms[idx]
+			mt.Word, mt.Category, mt.Severity = t.Word, t.Category, t.Severity
+			matches = append(matches, mt)
+		}
+	}
+	return matches
+}
+
+// match проверяет границы слова для совпадения нормализованных рун [start, end)
+// и переводит его в позиции исходного текста.
+func (m *Matcher) match(n normalized, start, end int) (Match, bool) {
+	from := n.spans[start].from
+	if !n.boundaryBefore(from) {
+		return Match{}, false
+	}
+	if m.opts.Stemming {
+		wordEnd := n.wordEnd(end)
+		if !isEnding(n.runes[end:wordEnd]) {
+			return Match{}, false
+		}
+		end = wordEnd
+	}
+	to := n.spans[end-1].to
+	if !n.boundaryAfter(to) {
+		return Match{}, false
+	}
+	return Match{Start: from, End: to}, true
+}
+
+// Mask заменяет каждый участок текста, покрытый стоп-словами, на "***".
+func (m *Matcher) Mask(text string) string {
+	return mask(text, m.FindAll(text))
+}
+
+// mask заменяет участки текста, покрытые найденными вхождениями, на "***".
+func mask(text string, matches []Match) string {
+	if len(matches) == 0 {
+		return text
+	}
+	runes := []rune(text)
+	covered := make([]bool, len(runes))
+	for _, mt := range matches {
+		for i := mt.Start; i < mt.End; i++ {
+			covered[i] = true
+		}
+	}
+
+	out := make([]rune, 0, len(runes))
+	for i, r := range runes {
+		switch {
+		case !covered[i]:
+			out = append(out, r)
+		case i == 0 || !covered[i-1]:
+			out = append(out, '*', '*', '*')
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Нормализация приводит текст и стоп-слова к общему «скелету», устойчивому к обфускации:
+//
+//  1. Unicode NFKC — полноширинные и стилизованные символы становятся обычными;
+//     отдельные диакритические знаки (q̶w̶e̶) отбрасываются;
+//  2. нижний регистр;
+//  3. leetspeak: 0→o, 1→i, 3→e, 4→a, 5→s, 7→t, @→a, $→s;
+//  4. латинские буквы, похожие на кириллические, заменяются кириллицей, ё — на е;
+//  5. знаки препинания и символы внутри слов (q.w.e.r.t.y) удаляются;
+//  6. повторы одной буквы (qwwwerty) и пробелов схлопываются.
+//
+// Каждая руна результата помнит диапазон рун исходного текста, из которого она получена,
+// поэтому найденные вхождения переводятся обратно в позиции исходного текста.
+
+var leet = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't',
+	'@': 'a', '$': 's',
+}
+
+var homoglyphs = map[rune]rune{
+	'a': 'а', 'b': 'в', 'c': 'с', 'e': 'е', 'h': 'н', 'k': 'к', 'm': 'м',
+	'o': 'о', 'p': 'р', 't': 'т', 'x': 'х', 'y': 'у', 'ё': 'е',
+}
+
+// span — диапазон рун исходного текста [from, to).
+type span struct {
+	from, to int
+}
+
+// normalized — нормализованный текст с привязкой к исходному.
+type normalized struct {
+	runes []rune
+	spans []span
+	// orig — руны исходного текста.
+	orig []rune
+}
+
+// normalize нормализует текст.
+func normalize(text string) normalized {
+	n := normalized{orig: []rune(text)}
+	for i, r := range n.orig {
+		folded := fold(r)
+		// Отброшенный диакритический знак относится к предыдущей букве.
+		if len(folded) == 0 && unicode.Is(unicode.Mn, r) && len(n.spans) > 0 {
+			n.spans[len(n.spans)-1].to = i + 1
+		}
+		for _, c := range folded {
+			n.push(c, span{i, i + 1})
+		}
+	}
+	return n
+}
+
+// normalizeWord нормализует стоп-слово или окончание и обрезает пробелы по краям.
+func normalizeWord(w string) []rune {
+	return []rune(strings.TrimSpace(string(normalize(w).runes)))
+}
+
+// fold переводит одну исходную руну в нормализованные; пустой результат — руна отбрасывается.
+func fold(r rune) []rune {
+	var out []rune
+	for _, c := range norm.NFKC.String(string(r)) {
+		switch {
+		case unicode.Is(unicode.Mn, c):
+			continue
+		case unicode.IsSpace(c):
+			out = append(out, ' ')
+			continue
+		}
+		c = unicode.ToLower(c)
+		if l, ok := leet[c]; ok {
+			c = l
+		}
+		if h, ok := homoglyphs[c]; ok {
+			c = h
+		}
+		if unicode.IsLetter(c) || unicode.IsDigit(c) || c == ' ' {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// push добавляет руну, схлопывая её с предыдущей, если они совпадают.
+func (n *normalized) push(c rune, s span) {
+	if last := len(n.runes) - 1; last >= 0 && n.runes[last] == c {
+		n.spans[last].to = s.to
+		return
+	}
+	if c == ' ' && len(n.runes) == 0 {
+		return
+	}
+	n.runes = append(n.runes, c)
+	n.spans = append(n.spans, s)
+}
+
+// isWordRune сообщает, может ли руна исходного текста быть частью слова.
+func isWordRune(r rune) bool {
+	if _, ok := leet[r]; ok {
+		return true
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+// boundaryBefore сообщает, начинается ли слово в позиции i исходного текста.
+func (n normalized) boundaryBefore(i int) bool {
+	return i == 0 || !isWordRune(n.orig[i-1])
+}
+
+// boundaryAfter сообщает, заканчивается ли слово перед позицией i исходного текста.
+func (n normalized) boundaryAfter(i int) bool {
+	return i >= len(n.orig) || !isWordRune(n.orig[i])
+}
+
+// wordEnd возвращает индекс нормализованной руны, на которой кончается слово, продолжающееся с позиции j:
+// слово прерывается пробелом или знаком препинания между соседними рунами.
+func (n normalized) wordEnd(j int) int {
+	for ; j < len(n.runes) && n.runes[j] != ' '; j++ {
+		if j == 0 {
+			continue
+		}
+		for _, r := range n.orig[n.spans[j-1].to:n.spans[j].from] {
+			if !isWordRune(r) {
+				return j
+			}
+		}
+	}
+	return j
+}
@@ -0,0 +1,84 @@
+package engine
+
+import "sort"
+
+// Упрощённый стеммер для русского языка: основами стоп-слова считаются все варианты
+// с отрезанным окончанием существительных, прилагательных, причастий или глаголов, не короче minStem рун.
+// Стоп-слово при этом совпадает с любой словоформой вида «основа + окончание».
+
+const minStem = 3
+
+var russianEndings = []string{
+	// возвратные
+	"ся", "сь",
+	// прилагательные и причастия
+	"ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом",
+	"его", "ого", "ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею",
+	"ивш", "ывш", "ующ", "ем", "нн", "вш", "ющ", "щ",
+	// глаголы
+	"ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл", "им", "ым",
+	"ен", "ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены", "ить", "ыть", "ишь", "ую", "ю",
+	"ла", "на", "ете", "йте", "ли", "й", "л", "н", "ло", "но", "ет", "ют", "ны", "ть", "ешь", "нно",
+	// существительные
+	"а", "ев", "ов", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией", "ой", "ий",
+	"иям", "ям", "ием", "ам", "о", "у", "ах", "иях", "ях", "ы", "ь", "ию", "ью", "ия", "ья", "я",
+}
+
+// endings — нормализованные окончания, от длинных к коротким.
+var endings = func() [][]rune {
+	seen := map[string]bool{}
+	var out [][]rune
+	for _, e := range russianEndings {
+		n := normalizeWord(e)
+		if len(n) == 0 || seen[string(n)] {
+			continue
+		}
+		seen[string(n)] = true
+		out = append(out, n)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return len(out[i]) > len(out[j]) })
+	return out
+}()
+
+// maxEnding — длина самого длинного окончания в рунах.
+var maxEnding = len(endings[0])
+
+// stems возвращает возможные основы нормализованного слова, начиная с самого слова.
+func stems(word []rune) [][]rune {
+	out := [][]rune{word}
+	seen := map[string]bool{string(word): true}
+	for _, e := range endings {
+		if len(word)-len(e) < minStem || !hasSuffix(word, e) {
+			continue
+		}
+		s := word[:len(word)-len(e)]
+		if !seen[string(s)] {
+			seen[string(s)] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// isEnding сообщает, является ли хвост слова допустимым окончанием.
+func isEnding(tail []rune) bool {
+	if len(tail) == 0 {
+		return true
+	}
+	if len(tail) > maxEnding {
+		return false
+	}
+	for _, e := range endings {
+		if string(e) == string(tail) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasSuffix(word, suffix []rune) bool {
+	if len(suffix) > len(word) {
+		return false
+	}
+	return string(word[len(word)-len(suffix):]) == string(suffix)
+}
@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Action — решение цензора по тексту.
+type Action string
+
+const (
+	// ActionAllow — стоп-слов нет, текст публикуется как есть.
+	ActionAllow Action = "allow"
+	// ActionMask — стоп-слова заменяются на "***", текст публикуется.
+	ActionMask Action = "mask"
+	// ActionFlag — текст с замаскированными стоп-словами уходит на модерацию.
+	ActionFlag Action = "flag"
+	// ActionReject — текст отклоняется.
+	ActionReject Action = "reject"
+)
+
+// Policy — пороги балла, при которых текст уходит на модерацию или отклоняется.
+// Балл — сумма серьёзностей всех найденных стоп-слов.
+type Policy struct {
+	FlagScore   int
+	RejectScore int
+}
+
+// DefaultPolicy: одно слово серьёзности 1–2 маскируется, 3–4 — отправляет текст
+// на модерацию, 5 и выше — отклоняет его.
+func DefaultPolicy() Policy {
+	return Policy{FlagScore: 3, RejectScore: 5}
+}
+
+// Verdict — результат проверки текста.
+type Verdict struct {
+	Action     Action   `json:"action"`
+	Score      int      `json:"score"`
+	Categories []string `json:"categories"`
+	Matches    []Match  `json:"matches"`
+	// Text — текст с замаскированными стоп-словами.
+	Text   string `json:"text"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Verdict проверяет текст автоматом m.
+func (p Policy) Verdict(m *Matcher, text string) Verdict {
+	def := DefaultPolicy()
+	if p.FlagScore <= 0 {
+		p.FlagScore = def.FlagScore
+	}
+	if p.RejectScore <= 0 {
+		p.RejectScore = def.RejectScore
+	}
+
+	matches := m.FindAll(text)
+	v := Verdict{
+		Action:     ActionAllow,
+		Categories: []string{},
+		Matches:    []Match{},
+		Text:       text,
+	}
+	if len(matches) == 0 {
+		return v
+	}
+
+	v.Matches = matches
+	for _, mt := range matches {
+		v.Score += mt.Severity
+		if !slices.Contains(v.Categories, mt.Category) {
+			v.Categories = append(v.Categories, mt.Category)
+		}
+	}
+	slices.Sort(v.Categories)
+	v.Text = mask(text, matches)
+
+	switch {
+	case v.Score >= p.RejectScore:
+		v.Action = ActionReject
+	case v.Score >= p.FlagScore:
+		v.Action = ActionFlag
+	default:
+		v.Action = ActionMask
+	}
+	v.Reason = fmt.Sprintf("%d prohibited term(s) found, categories: %s, score %d",
+		len(matches), strings.Join(v.Categories, ", "), v.Score)
+	return v
+}
@@ -2,9 +2,9 @@
 package middl
 
 import (
+	"APIGateway/pkg/logging"
 	"context"
-	"github.com/gofrs/uuid"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 )
@@ -27,15 +27,11 @@ func Middle(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		start := time.Now()
 
-		reqID := req.URL.Query().Get("request_id")
-		if reqID == "" {
-			rID, err := uuid.NewV4()
-			if err != nil {
-				log.Printf("Ошибка генерации UUID: %v", err)
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-				return
-			}
-			reqID = rID.String()
+		reqID, err := logging.RequestID(req)
+		if err != nil {
+			slog.ErrorContext(req.Context(), "Ошибка генерации UUID", "err", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
 		}
 
 		req.Header.Set("X-Request-ID", reqID)
@@ -45,20 +41,13 @@ func Middle(next http.Handler) http.Handler {
 		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Request-ID")
 
-		log.Printf("--> %s %s %s trace_id: %s", req.Method, req.URL.Path, req.RemoteAddr, reqID)
+		req = req.WithContext(context.WithValue(logging.WithRequest(req, reqID), RequestIDKey, reqID))
+		slog.DebugContext(req.Context(), "request started", "method", req.Method, "path", req.URL.Path)
 
 		lrw := NewLoggingResponseWriter(w)
 		next.ServeHTTP(lrw, req)
 
-		duration := time.Since(start)
-		log.Printf("<-- %s %s %d %s (duration: %v) trace_id: %s",
-			req.Method,
-			req.URL.Path,
-			lrw.statusCode,
-			http.StatusText(lrw.statusCode),
-			duration,
-			reqID,
-		)
+		logging.LogRequest(req, lrw.statusCode, start)
 	})
 }
 
@@ -1,9 +1,13 @@
 package storage
 
 import (
+	"APIGateway/pkg/tracing"
 	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"log"
+	"log/slog"
 )
 
 // Store Хранилище данных
@@ -13,41 +17,152 @@ type Store struct {
 
 // New Конструктор объекта хранилища
 func New(ctx context.Context, constr string) (*Store, error) {
-	db, err := pgxpool.New(ctx, constr)
+	db, err := tracing.NewPgxPool(ctx, constr)
 	if err != nil {
 		return nil, err
 	}
 	return &Store{db: db}, nil
 }
 
-// AllList Выводит все комментарии.
-func (p *Store) AllList() ([]Stop, error) {
-	rows, err := p.db.Query(context.Background(), "SELECT * FROM stop")
-	if err != nil {
-		return nil, err
-	}
+// Stat возвращает статистику пула соединений.
+func (p *Store) Stat() *pgxpool.Stat {
+	return p.db.Stat()
+}
+
+const stopColumns = "id, stop_list, category, severity"
+
+func scanStops(rows pgx.Rows) ([]Stop, error) {
 	defer rows.Close()
 	var list []Stop
 	for rows.Next() {
 		var c Stop
-		err = rows.Scan(&c.ID, &c.StopList)
+		err := rows.Scan(&c.ID, &c.StopList, &c.Category, &c.Severity)
 		if err != nil {
 			return nil, err
 		}
 		list = append(list, c)
 	}
-
 	return list, rows.Err()
 }
 
-// AddList Добавляет комментарии в стоп лист.
+// AllList Выводит весь стоп-лист.
+func (p *Store) AllList() ([]Stop, error) {
+	rows, err := p.db.Query(context.Background(), "SELECT "+stopColumns+" FROM stop ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	return scanStops(rows)
+}
+
+// AddList Добавляет слово в стоп лист; существующее слово не дублируется.
 func (p Store) AddList(c Stop) error {
+	if err := c.Normalize(); err != nil {
+		return err
+	}
 	_, err := p.db.Exec(context.Background(),
-		"INSERT INTO stop (stop_list) VALUES ($1);", c.StopList)
+		"INSERT INTO stop (stop_list, category, severity) VALUES ($1, $2, $3) ON CONFLICT (stop_list) DO NOTHING;",
+		c.StopList, c.Category, c.Severity)
+	if err != nil {
+		slog.Error("failed to add stop word", "err", err)
+		return err
+	}
+
+	return nil
+}
+
+// Page Выводит страницу стоп-листа, упорядоченную по ID.
+func (p *Store) Page(q Query) ([]Stop, int, error) {
+	ctx := context.Background()
+	var total int
+	err := p.db.QueryRow(ctx,
+		"SELECT count(*) FROM stop WHERE ($1 = '' OR category = $1)", q.Category).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := p.db.Query(ctx,
+		"SELECT "+stopColumns+" FROM stop WHERE ($1 = '' OR category = $1) ORDER BY id LIMIT $2 OFFSET $3",
+		q.Category, q.Limit, q.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	list, err := scanStops(rows)
+	return list, total, err
+}
+
+// AddMany Добавляет слова одной транзакцией.
+func (p *Store) AddMany(list []Stop) ([]Stop, error) {
+	ctx := context.Background()
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	added := []Stop{}
+	for _, c := range list {
+		if err := c.Normalize(); err != nil {
+			return nil, err
+		}
+		err := tx.QueryRow(ctx,
+			"INSERT INTO stop (stop_list, category, severity) VALUES ($1, $2, $3) ON CONFLICT (stop_list) DO NOTHING RETURNING id;",
+			c.StopList, c.Category, c.Severity).Scan(&c.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		added = append(added, c)
+	}
+	return added, tx.Commit(ctx)
+}
+
+// GetStop Возвращает стоп-слово по ID.
+func (p *Store) GetStop(id int) (Stop, error) {
+	rows, err := p.db.Query(context.Background(), "SELECT "+stopColumns+" FROM stop WHERE id = $1", id)
+	if err != nil {
+		return Stop{}, err
+	}
+	list, err := scanStops(rows)
+	if err != nil {
+		return Stop{}, err
+	}
+	if len(list) == 0 {
+		return Stop{}, ErrNotFound
+	}
+	return list[0], nil
+}
+
+// UpdateStop Изменяет слово, категорию и серьёзность записи.
+func (p *Store) UpdateStop(s Stop) error {
+	if err := s.Normalize(); err != nil {
+		return err
+	}
+	tag, err := p.db.Exec(context.Background(),
+		"UPDATE stop SET stop_list = $2, category = $3, severity = $4 WHERE id = $1",
+		s.ID, s.StopList, s.Category, s.Severity)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrDuplicate
+	}
 	if err != nil {
-		log.Println(err)
 		return err
 	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
 
+// DeleteStop Удаляет стоп-слово.
+func (p *Store) DeleteStop(id int) error {
+	tag, err := p.db.Exec(context.Background(), "DELETE FROM stop WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
 	return nil
 }
@@ -1,11 +1,74 @@
 package storage
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultCategory — категория стоп-слова, если она не указана.
+const DefaultCategory = "general"
+
+// Допустимые значения серьёзности стоп-слова.
+const (
+	SeverityMin = 1
+	SeverityMax = 5
+)
+
+var (
+	// ErrNotFound — стоп-слово с таким ID не существует.
+	ErrNotFound = errors.New("stop word not found")
+	// ErrDuplicate — такое стоп-слово уже есть в списке.
+	ErrDuplicate = errors.New("stop word already exists")
+)
+
 type Stop struct {
 	ID       int    `json:"ID,omitempty"`
 	StopList string `json:"stopList,omitempty"`
+	// Category — группа слова, например insult или spam.
+	Category string `json:"category,omitempty"`
+	// Severity — серьёзность от SeverityMin до SeverityMax.
+	Severity int `json:"severity,omitempty"`
+}
+
+// Normalize обрезает пробелы, подставляет значения по умолчанию и проверяет запись.
+func (s *Stop) Normalize() error {
+	s.StopList = strings.TrimSpace(s.StopList)
+	s.Category = strings.ToLower(strings.TrimSpace(s.Category))
+	if s.StopList == "" {
+		return errors.New("stopList must not be empty")
+	}
+	if strings.ContainsAny(s.StopList, "\r\n") {
+		return errors.New("stopList must be a single line")
+	}
+	if s.Category == "" {
+		s.Category = DefaultCategory
+	}
+	if s.Severity == 0 {
+		s.Severity = SeverityMin
+	}
+	if s.Severity < SeverityMin || s.Severity > SeverityMax {
+		return fmt.Errorf("severity must be between %d and %d", SeverityMin, SeverityMax)
+	}
+	return nil
+}
+
+// Query — параметры постраничной выборки стоп-листа.
+type Query struct {
+	Limit  int
+	Offset int
+	// Category — фильтр по категории; пустая строка — все категории.
+	Category string
 }
 
 type Interface interface {
 	AllList() ([]Stop, error)
 	AddList(c Stop) error
+	// Page возвращает страницу стоп-листа и общее число записей под фильтром.
+	Page(q Query) ([]Stop, int, error)
+	// AddMany добавляет слова, пропуская уже существующие, и возвращает добавленные записи.
+	AddMany(list []Stop) ([]Stop, error)
+	GetStop(id int) (Stop, error)
+	UpdateStop(s Stop) error
+	DeleteStop(id int) error
 }
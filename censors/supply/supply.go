@@ -2,13 +2,22 @@ package supply
 
 import (
 	"APIGateway/censors/pkg/storage"
+	"bufio"
 	"io"
 	"os"
 	"strings"
 )
 
+// DefaultPath — файл стоп-листа по умолчанию относительно корня репозитория.
+const DefaultPath = "censors/supply/words.txt"
+
 func StopList() ([]storage.Stop, error) {
-	f, err := os.Open("censors/supply/words.txt")
+	return Load(DefaultPath)
+}
+
+// Load читает стоп-лист из файла.
+func Load(path string) ([]storage.Stop, error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
@@ -17,7 +26,12 @@ func StopList() ([]storage.Stop, error) {
 		if err != nil {
 		}
 	}(f)
-	content, err := io.ReadAll(f)
+	return Parse(f)
+}
+
+// Parse читает стоп-лист в формате words.txt: одно слово на строку, пустые строки пропускаются.
+func Parse(r io.Reader) ([]storage.Stop, error) {
+	content, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
@@ -25,6 +39,9 @@ func StopList() ([]storage.Stop, error) {
 	var sl []storage.Stop
 	for _, line := range lines {
 		trimmedLine := strings.TrimSpace(line)
+		if trimmedLine == "" {
+			continue
+		}
 		str := storage.Stop{
 			StopList: trimmedLine,
 		}
@@ -33,3 +50,14 @@ func StopList() ([]storage.Stop, error) {
 
 	return sl, nil
 }
+
+// Write записывает стоп-лист в формате words.txt.
+func Write(w io.Writer, list []storage.Stop) error {
+	bw := bufio.NewWriter(w)
+	for _, s := range list {
+		if _, err := bw.WriteString(s.StopList + "\n"); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -2,15 +2,20 @@
 package main
 
 import (
-	"APIGateway/aggregator/pkg/logger"
 	"APIGateway/comments/config"
 	"APIGateway/comments/pkg/api"
 	"APIGateway/comments/pkg/middl"
 	"APIGateway/comments/pkg/storage"
+	"APIGateway/pkg/logging"
+	"APIGateway/pkg/metrics"
+	"APIGateway/pkg/tracing"
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"github.com/joho/godotenv"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -25,13 +30,29 @@ func init() {
 }
 
 func main() {
-	logg, err := logger.NewLogger("comments.log")
+	logs, err := logging.FromEnv("comments", "COMMENTS")
 	if err != nil {
 		log.Fatalf("Ошибка инициализации логгера: %v", err) // Логируем через стандартный, т.к. логгер ещё не инициализирован
 	}
-	logg.InfoWithRequestID("Comments сервис запущен")
+	slog.Info("Comments сервис запущен")
 
+	err = run()
+	if err != nil {
+		slog.Error("Ошибка работы сервера комментариев", "err", err)
+	}
+	logs.Close()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// run запускает сервер комментариев и возвращает управление после его остановки.
+func run() error {
 	cfg := config.New()
+	if cfg.IdentitySecret == "" {
+		return errors.New("COMMENTS_IDENTITY_SECRET не задан: без него нельзя проверить заголовки личности от шлюза")
+	}
+	tracer := tracing.FromEnv("comments")
 	port := flag.String("comments-port", cfg.AdrPort, "Порт для comments сервиса")
 	flag.Parse()
 
@@ -40,12 +61,16 @@ func main() {
 
 	db, err := storage.New(ctx, cfg.URLdb)
 	if err != nil {
-		logg.ErrorWithRequestID("Ошибка подключения к БД:", err)
-		os.Exit(1)
+		return fmt.Errorf("подключение к БД: %w", err)
 	}
+	metrics.Default.RegisterPgxPool("comments", db)
 
-	api := api.New(db, logg)
-	api.Router().Use(middl.Middle)
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	defer stopPurge()
+	go purgeDeleted(purgeCtx, db, cfg.PurgeRetention, cfg.PurgeInterval)
+
+	api := api.New(db)
+	api.Router().Use(metrics.Middleware, middl.Middle, tracing.Middleware, middl.VerifyIdentity([]byte(cfg.IdentitySecret), 5*time.Minute))
 
 	server := &http.Server{
 		Addr:    *port,
@@ -56,21 +81,50 @@ func main() {
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
+	serveErr := make(chan error, 1)
+
 	go func() {
-		logg.InfoWithRequestID("Сервер комментариев запущен на " + *port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logg.ErrorWithRequestID("Ошибка сервера:", err)
-		}
+		slog.Info("Сервер комментариев запущен", "addr", *port)
+		serveErr <- server.ListenAndServe()
 	}()
 
-	<-done
-	logg.InfoWithRequestID("Сервер комментариев останавливается...")
+	select {
+	case <-done:
+		slog.Info("Сервер комментариев останавливается...")
+	case err = <-serveErr:
+		err = fmt.Errorf("запуск сервера: %w", err)
+	}
 
 	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		logg.ErrorWithRequestID("Ошибка при остановке сервера:", err)
+		slog.Error("Ошибка при остановке сервера", "err", err)
+	}
+	if err := tracer.Shutdown(ctx); err != nil {
+		slog.Error("Ошибка отправки трасс", "err", err)
+	}
+	slog.Info("Сервер комментариев остановлен")
+	return err
+}
+
+// purgeDeleted периодически удаляет из БД комментарии, удалённые дольше retention назад и оставшиеся без ответов.
+func purgeDeleted(ctx context.Context, db storage.Interface, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := db.PurgeDeleted(retention)
+			if err != nil {
+				slog.ErrorContext(ctx, "Ошибка очистки удалённых комментариев", "err", err)
+				continue
+			}
+			if n > 0 {
+				slog.InfoContext(ctx, "Удалены комментарии", "count", n)
+			}
+		}
 	}
-	logg.InfoWithRequestID("Сервер комментариев остановлен")
 }
@@ -1,16 +1,29 @@
 package config
 
-import "os"
+import (
+	"log/slog"
+	"os"
+	"time"
+)
 
 type Config struct {
 	AdrPort string
 	URLdb   string
+	// PurgeRetention — сколько хранить удалённые комментарии без ответов до окончательного удаления.
+	PurgeRetention time.Duration
+	// PurgeInterval — как часто запускать очистку удалённых комментариев.
+	PurgeInterval time.Duration
+	// IdentitySecret — общий со шлюзом секрет, которым подписаны заголовки личности.
+	IdentitySecret string
 }
 
 func New() *Config {
 	return &Config{
-		AdrPort: getEnv("COMMENTS_PORT", ""),
-		URLdb:   getEnv("COMMENTS_DB", ""),
+		AdrPort:        getEnv("COMMENTS_PORT", ""),
+		URLdb:          getEnv("COMMENTS_DB", ""),
+		PurgeRetention: getEnvDuration("COMMENTS_PURGE_RETENTION", 30*24*time.Hour),
+		PurgeInterval:  getEnvDuration("COMMENTS_PURGE_INTERVAL", time.Hour),
+		IdentitySecret: getEnv("COMMENTS_IDENTITY_SECRET", ""),
 	}
 }
 
@@ -22,3 +35,17 @@ func getEnv(key string, defaultVal string) string {
 
 	return defaultVal
 }
+
+func getEnvDuration(key string, defaultVal time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultVal
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		slog.Warn("invalid configuration value, using default", "key", key, "value", value, "default", defaultVal)
+
+		return defaultVal
+	}
+	return d
+}
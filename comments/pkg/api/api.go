@@ -2,25 +2,36 @@
 package api
 
 import (
-	"APIGateway/aggregator/pkg/logger"
+	"APIGateway/comments/pkg/middl"
 	"APIGateway/comments/pkg/storage"
+	"APIGateway/pkg/metrics"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gorilla/mux"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 )
 
+// NewsIDHeader — заголовок ответа на изменение комментария с ID его новости:
+// по нему шлюз сбрасывает закешированную страницу новости.
+const NewsIDHeader = "X-News-ID"
+
+// IdempotencyKeyHeader — ключ, с которым шлюз может повторить создание комментария.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
 type API struct {
 	router *mux.Router
 	db     storage.Interface
-	log    *logger.Logger
 }
 
-func New(db storage.Interface, log *logger.Logger) *API {
+func New(db storage.Interface) *API {
 	api := API{
 		router: mux.NewRouter(),
 		db:     db,
-		log:    log,
 	}
 	api.endpoints()
 	return &api
@@ -34,92 +45,365 @@ func (api *API) endpoints() {
 	api.router.HandleFunc("/comments", api.commentsHandler).Methods(http.MethodGet, http.MethodOptions)
 	api.router.HandleFunc("/comments", api.addCommentHandler).Methods(http.MethodPost, http.MethodOptions)
 	api.router.HandleFunc("/comments", api.deleteCommentHandler).Methods(http.MethodDelete, http.MethodOptions)
-
+	api.router.HandleFunc("/comments/{id:[0-9]+}", api.editCommentHandler).Methods(http.MethodPatch)
+	api.router.HandleFunc("/comments/{id:[0-9]+}/revisions", api.revisionsHandler).Methods(http.MethodGet)
+	api.router.HandleFunc("/authors/{id}/comments", api.authorCommentsHandler).Methods(http.MethodGet)
+	api.router.HandleFunc("/healthz", api.healthzHandler).Methods(http.MethodGet)
+	api.router.HandleFunc("/readyz", api.readyzHandler).Methods(http.MethodGet)
+	api.router.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
+	api.moderationEndpoints()
 }
 
 func (api *API) commentsHandler(w http.ResponseWriter, r *http.Request) {
-	requestID := r.Context().Value("request_id")
-	if requestID == nil {
-		requestID = "unknown"
-	}
-
 	newsIDStr := r.URL.Query().Get("news_id")
 	newsID, err := strconv.Atoi(newsIDStr)
 	if err != nil {
-		api.log.ErrorWithRequestID(requestID.(string), "[commentsHandler] invalid news_id parameter:", newsIDStr, err)
+		slog.WarnContext(r.Context(), "invalid news_id parameter", "news_id", newsIDStr, "err", err)
 		http.Error(w, "Invalid news_id parameter", http.StatusBadRequest)
 		return
 	}
 
-	comments, err := api.db.AllComments(newsID)
+	view := r.URL.Query().Get("view")
+	if view != "" && view != "flat" && view != "tree" {
+		http.Error(w, "view must be flat or tree", http.StatusBadRequest)
+		return
+	}
+	q, opts, err := listOptions(r)
+	if err != nil {
+		slog.WarnContext(r.Context(), "invalid list options", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// В дереве страница состоит из комментариев верхнего уровня вместе со всеми ответами.
+	q.RootsOnly = view == "tree"
+
+	comments, page, err := api.db.CommentsPage(newsID, q)
+	if errors.Is(err, storage.ErrInvalidCursor) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		api.log.ErrorWithRequestID(requestID.(string), "[commentsHandler] failed to get comments for newsID=", newsID, "error:", err)
+		slog.ErrorContext(r.Context(), "failed to get comments", "news_id", newsID, "err", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(comments); err != nil {
-		api.log.ErrorWithRequestID(requestID.(string), "[commentsHandler] failed to encode comments:", err)
+	var resp interface{} = comments
+	if view == "tree" {
+		all, err := api.db.AllComments(newsID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get replies", "news_id", newsID, "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp = pageTree(comments, storage.BuildTree(all, opts))
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+	if page.NextOffset > 0 {
+		w.Header().Set("X-Next-Offset", strconv.Itoa(page.NextOffset))
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.ErrorContext(r.Context(), "failed to encode comments", "err", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
-	api.log.InfoWithRequestID(requestID.(string), "[commentsHandler] served comments for newsID=", newsID)
+	slog.DebugContext(r.Context(), "served comments", "news_id", newsID)
 }
 
-func (api *API) addCommentHandler(w http.ResponseWriter, r *http.Request) {
-	requestID := r.Context().Value("request_id")
-	if requestID == nil {
-		requestID = "unknown"
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// listOptions разбирает параметры выборки: limit, cursor, offset, sort и depth.
+// Курсор листает сортировки по времени, offset — только sort=replies.
+func listOptions(r *http.Request) (storage.PageQuery, storage.TreeOptions, error) {
+	query := r.URL.Query()
+	q := storage.PageQuery{Limit: defaultPageLimit, Sort: storage.SortNewest, Cursor: query.Get("cursor")}
+	opts := storage.TreeOptions{Sort: storage.SortNewest}
+
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 || limit > maxPageLimit {
+			return q, opts, fmt.Errorf("invalid limit parameter: %q (1..%d)", l, maxPageLimit)
+		}
+		q.Limit = limit
+	}
+	if d := query.Get("depth"); d != "" {
+		depth, err := strconv.Atoi(d)
+		if err != nil || depth < 0 {
+			return q, opts, fmt.Errorf("invalid depth parameter: %q", d)
+		}
+		opts.MaxDepth = depth
+	}
+	switch s := query.Get("sort"); s {
+	case "":
+	case storage.SortNewest, storage.SortOldest, storage.SortReplies:
+		q.Sort, opts.Sort = s, s
+	default:
+		return q, opts, fmt.Errorf("invalid sort parameter: %q", s)
+	}
+	if o := query.Get("offset"); o != "" {
+		offset, err := strconv.Atoi(o)
+		if err != nil || offset < 0 || q.Sort != storage.SortReplies {
+			return q, opts, fmt.Errorf("invalid offset parameter: %q (only with sort=replies)", o)
+		}
+		q.Offset = offset
+	}
+	if q.Cursor != "" && q.Sort == storage.SortReplies {
+		return q, opts, fmt.Errorf("cursor is not supported with sort=replies, use offset")
+	}
+	return q, opts, nil
+}
+
+// pageTree оставляет из дерева только корни, попавшие на страницу, в порядке страницы.
+func pageTree(page []storage.Comment, roots []*storage.Node) []*storage.Node {
+	byID := make(map[int]*storage.Node, len(roots))
+	for _, n := range roots {
+		byID[n.ID] = n
+	}
+	nodes := make([]*storage.Node, 0, len(page))
+	for _, c := range page {
+		if n, ok := byID[c.ID]; ok {
+			nodes = append(nodes, n)
+		}
 	}
+	return nodes
+}
 
+func (api *API) addCommentHandler(w http.ResponseWriter, r *http.Request) {
 	var c storage.Comment
 	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
-		api.log.ErrorWithRequestID(requestID.(string), "[addCommentHandler] decode error:", err)
+		slog.WarnContext(r.Context(), "failed to decode comment", "err", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	if c.Content == "" {
-		api.log.InfoWithRequestID(requestID.(string), "[addCommentHandler] empty content")
+		slog.WarnContext(r.Context(), "empty content")
 		http.Error(w, "Comment content cannot be empty", http.StatusBadRequest)
 		return
 	}
 
 	if c.NewsID == 0 && c.ParentID == nil {
-		api.log.InfoWithRequestID(requestID.(string), "[addCommentHandler] missing news_id and parent_id")
+		slog.WarnContext(r.Context(), "missing news_id and parent_id")
 		http.Error(w, "Either news_id or parent_id must be specified", http.StatusBadRequest)
 		return
 	}
 
-	if err := api.db.AddComment(c); err != nil {
-		api.log.ErrorWithRequestID(requestID.(string), "[addCommentHandler] failed to add comment:", err)
+	// Автор берётся только из заголовков шлюза: поля author_* в теле запроса игнорируются.
+	c.AuthorID, c.AuthorName = "", ""
+	if id, ok := middl.IdentityFromRequest(r); ok {
+		c.AuthorID, c.AuthorName = id.ID, id.Name
+	}
+
+	c.IdempotencyKey = r.Header.Get(IdempotencyKeyHeader)
+
+	switch c.Status {
+	case "":
+		c.Status = storage.StatusApproved
+	case storage.StatusApproved, storage.StatusPending:
+	default:
+		slog.WarnContext(r.Context(), "invalid status", "status", c.Status)
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+
+	id, err := api.db.AddComment(c)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to add comment", "err", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	api.log.InfoWithRequestID(requestID.(string), "[addCommentHandler] comment added to news_id=", c.NewsID)
+	slog.InfoContext(r.Context(), "comment added", "news_id", c.NewsID, "status", c.Status)
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "status": c.Status})
 }
 
-func (api *API) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
-	requestID, _ := r.Context().Value("request_id").(string)
-	if requestID == "" {
-		requestID = "unknown"
+// editCommentHandler заменяет текст комментария; прежний текст сохраняется в истории правок.
+// Текст уже проверен цензором на шлюзе; status=pending отправляет комментарий на модерацию.
+func (api *API) editCommentHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var body struct {
+		Content string `json:"content"`
+		Status  string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		slog.WarnContext(r.Context(), "failed to decode comment", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.Content == "" {
+		http.Error(w, "Comment content cannot be empty", http.StatusBadRequest)
+		return
+	}
+	if body.Status != "" && body.Status != storage.StatusApproved && body.Status != storage.StatusPending {
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+	if _, ok := api.authorize(w, r, id); !ok {
+		return
 	}
 
+	c, err := api.db.EditComment(storage.Edit{CommentID: id, Content: body.Content, Status: body.Status})
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to edit comment", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "comment edited", "comment_id", id, "status", c.Status)
+	w.Header().Set(NewsIDHeader, strconv.Itoa(c.NewsID))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// revisionsHandler возвращает историю правок комментария. Историю опубликованного комментария
+// видят все, а скрытого или удалённого — только автор и модераторы; остальные получают 404,
+// как и для несуществующего комментария.
+func (api *API) revisionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	c, err := api.db.Comment(id)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		slog.ErrorContext(r.Context(), "failed to get comment", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err != nil || !canView(r, c) {
+		http.Error(w, storage.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	revisions, err := api.db.Revisions(id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get revisions", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+func (api *API) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+
 	idStr := r.URL.Query().Get("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		api.log.ErrorWithRequestID(requestID, "[deleteCommentHandler] invalid id:", idStr, err)
+		slog.WarnContext(r.Context(), "invalid comment id", "comment_id", idStr, "err", err)
 		http.Error(w, "Invalid id", http.StatusBadRequest)
 		return
 	}
+	c, ok := api.authorize(w, r, id)
+	if !ok {
+		return
+	}
 
-	if err := api.db.DeleteComment(id); err != nil {
-		api.log.ErrorWithRequestID(requestID, "[deleteCommentHandler] failed to delete comment:", err)
+	err = api.db.DeleteComment(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to delete comment", "err", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	api.log.InfoWithRequestID(requestID, "[deleteCommentHandler] deleted comment id=", id)
+	slog.InfoContext(r.Context(), "comment deleted", "comment_id", id)
+	w.Header().Set(NewsIDHeader, strconv.Itoa(c.NewsID))
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// authorize пропускает к изменению комментария его автора и модераторов. Анонимные
+// комментарии может менять только модератор. Возвращает проверенный комментарий;
+// при отказе отвечает клиенту и возвращает ok=false.
+func (api *API) authorize(w http.ResponseWriter, r *http.Request, commentID int) (storage.Comment, bool) {
+	id, ok := middl.IdentityFromRequest(r)
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return storage.Comment{}, false
+	}
+	c, err := api.db.Comment(commentID)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return c, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get comment", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return c, false
+	}
+	if (c.AuthorID == "" || c.AuthorID != id.ID) && !id.HasRole(middl.RoleModerator) {
+		http.Error(w, "only the author or a moderator can change this comment", http.StatusForbidden)
+		return c, false
+	}
+	return c, true
+}
+
+// canView сообщает, виден ли комментарий c автору запроса r: опубликованный — всем,
+// остальные — только автору и модераторам.
+func canView(r *http.Request, c storage.Comment) bool {
+	if c.Status == storage.StatusApproved && c.DeletedAt == nil {
+		return true
+	}
+	id, ok := middl.IdentityFromRequest(r)
+	return ok && ((c.AuthorID != "" && c.AuthorID == id.ID) || id.HasRole(middl.RoleModerator))
+}
+
+// authorCommentsHandler возвращает опубликованные комментарии автора, от новых к старым.
+// Поддерживает limit и cursor так же, как выборка комментариев новости.
+func (api *API) authorCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	author := mux.Vars(r)["id"]
+
+	q, _, err := listOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	comments, page, err := api.db.CommentsByAuthor(author, q)
+	if errors.Is(err, storage.ErrInvalidCursor) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get author comments", "author", author, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comments)
+}
+
+func (api *API) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// readyzHandler проверяет пул соединений с БД.
+func (api *API) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	if err := api.db.Ping(ctx); err != nil {
+		slog.ErrorContext(r.Context(), "database unavailable", "err", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"status": "unavailable", "database": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok", "database": "ok"})
+}
@@ -1,7 +1,7 @@
 package api
 
 import (
-	"APIGateway/aggregator/pkg/logger"
+	"APIGateway/comments/pkg/middl"
 	"APIGateway/comments/pkg/storage"
 	"bytes"
 	"context"
@@ -10,6 +10,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -23,12 +24,7 @@ func TestCommentHandler(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	log, err := logger.NewLogger("comments.log")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	api := New(psgr, log)
+	api := New(psgr)
 
 	// === 1. Добавление комментария к новости ===
 	var testBody = []byte(`{"news_id": 1, "content": "Комментарий к новости"}`)
@@ -79,3 +75,516 @@ func TestCommentHandler(t *testing.T) {
 		t.Log("Тело ответа:", rr.Body.String())
 	}
 }
+
+func TestListOptions(t *testing.T) {
+	tests := []struct {
+		query     string
+		wantErr   bool
+		wantLimit int
+		wantSort  string
+		wantDepth int
+	}{
+		{"", false, defaultPageLimit, storage.SortNewest, 0},
+		{"limit=10&sort=replies&depth=2", false, 10, storage.SortReplies, 2},
+		{"limit=0", true, 0, "", 0},
+		{"limit=1000", true, 0, "", 0},
+		{"sort=random", true, 0, "", 0},
+		{"depth=-1", true, 0, "", 0},
+		{"sort=replies&offset=50", false, defaultPageLimit, storage.SortReplies, 0},
+		{"sort=replies&cursor=abc", true, 0, "", 0},
+		{"offset=50", true, 0, "", 0},
+		{"sort=replies&offset=-1", true, 0, "", 0},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/comments?news_id=1&"+tt.query, nil)
+		q, opts, err := listOptions(req)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: ошибка %v, ожидали ошибку: %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if q.Limit != tt.wantLimit || q.Sort != tt.wantSort || opts.Sort != tt.wantSort || opts.MaxDepth != tt.wantDepth {
+			t.Errorf("%q: получили %+v %+v", tt.query, q, opts)
+		}
+	}
+}
+
+// memDB — хранилище комментариев в памяти для тестов обработчиков.
+type memDB struct {
+	comments  []storage.Comment
+	audit     []storage.AuditEntry
+	revisions []storage.Revision
+}
+
+func (m *memDB) AllComments(newsID int) ([]storage.Comment, error) {
+	var out []storage.Comment
+	for _, c := range m.comments {
+		if c.NewsID == newsID && c.Status == storage.StatusApproved {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (m *memDB) CommentsPage(newsID int, q storage.PageQuery) ([]storage.Comment, storage.Page, error) {
+	all, _ := m.AllComments(newsID)
+	return all, storage.Page{Total: len(all), Limit: q.Limit}, nil
+}
+
+func (m *memDB) Comment(id int) (storage.Comment, error) {
+	for _, c := range m.comments {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+	return storage.Comment{}, storage.ErrNotFound
+}
+
+func (m *memDB) CommentsByAuthor(authorID string, q storage.PageQuery) ([]storage.Comment, storage.Page, error) {
+	out := []storage.Comment{}
+	for i := len(m.comments) - 1; i >= 0; i-- {
+		c := m.comments[i]
+		if c.AuthorID == authorID && c.Status == storage.StatusApproved && c.DeletedAt == nil {
+			out = append(out, c)
+		}
+	}
+	return out, storage.Page{Total: len(out), Limit: q.Limit}, nil
+}
+
+func (m *memDB) AddComment(c storage.Comment) (int, error) {
+	for _, prev := range m.comments {
+		if c.IdempotencyKey != "" && prev.AuthorID == c.AuthorID && prev.IdempotencyKey == c.IdempotencyKey {
+			return prev.ID, nil
+		}
+	}
+	c.ID = len(m.comments) + 1
+	m.comments = append(m.comments, c)
+	return c.ID, nil
+}
+
+func (m *memDB) DeleteComment(id int) error {
+	for i, c := range m.comments {
+		if c.ID == id && c.DeletedAt == nil {
+			now := time.Now().Unix()
+			m.comments[i].DeletedAt = &now
+			return nil
+		}
+	}
+	return storage.ErrNotFound
+}
+
+func (m *memDB) RestoreComment(a storage.Action) (storage.Comment, error) {
+	for i, c := range m.comments {
+		if c.ID == a.CommentID && c.DeletedAt != nil {
+			m.comments[i].DeletedAt = nil
+			m.audit = append(m.audit, storage.AuditEntry{
+				ID: len(m.audit) + 1, CommentID: c.ID, Actor: a.Actor,
+				FromStatus: storage.StatusDeleted, ToStatus: c.Status, Reason: a.Reason,
+			})
+			return m.comments[i], nil
+		}
+	}
+	return storage.Comment{}, storage.ErrNotFound
+}
+
+func (m *memDB) PurgeDeleted(olderThan time.Duration) (int, error) { return 0, nil }
+
+func (m *memDB) Ping(ctx context.Context) error { return nil }
+
+func (m *memDB) EditComment(e storage.Edit) (storage.Comment, error) {
+	for i, c := range m.comments {
+		if c.ID != e.CommentID {
+			continue
+		}
+		m.revisions = append(m.revisions, storage.Revision{ID: len(m.revisions) + 1, CommentID: c.ID, Content: c.Content})
+		edited := int64(len(m.revisions))
+		m.comments[i].Content, m.comments[i].EditedAt = e.Content, &edited
+		if e.Status == storage.StatusPending {
+			m.comments[i].Status = storage.StatusPending
+		}
+		return m.comments[i], nil
+	}
+	return storage.Comment{}, storage.ErrNotFound
+}
+
+func (m *memDB) Revisions(commentID int) ([]storage.Revision, error) {
+	out := []storage.Revision{}
+	for _, r := range m.revisions {
+		if r.CommentID == commentID {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (m *memDB) ModerationQueue(q storage.QueueQuery) ([]storage.Comment, error) {
+	out := []storage.Comment{}
+	for _, c := range m.comments {
+		if c.Status == q.Status && c.ID > q.AfterID && len(out) < q.Limit {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (m *memDB) Moderate(a storage.Action) (storage.Comment, error) {
+	for i, c := range m.comments {
+		if c.ID == a.CommentID {
+			m.audit = append(m.audit, storage.AuditEntry{
+				ID: len(m.audit) + 1, CommentID: c.ID, Actor: a.Actor,
+				FromStatus: c.Status, ToStatus: a.Status, Reason: a.Reason,
+			})
+			m.comments[i].Status = a.Status
+			return m.comments[i], nil
+		}
+	}
+	return storage.Comment{}, storage.ErrNotFound
+}
+
+func (m *memDB) AuditLog(commentID int) ([]storage.AuditEntry, error) {
+	out := []storage.AuditEntry{}
+	for _, e := range m.audit {
+		if e.CommentID == commentID {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func newTestAPI(t *testing.T, db storage.Interface) *API {
+	t.Helper()
+	return New(db)
+
+}
+
+// setIdentity выставляет заголовки личности, как это делает шлюз; пустой id — анонимный запрос.
+func setIdentity(req *http.Request, id string, roles ...string) {
+	if id == "" {
+		return
+	}
+	req.Header.Set(middl.UserIDHeader, id)
+	req.Header.Set(middl.UserRolesHeader, strings.Join(roles, ","))
+}
+
+func TestModeration(t *testing.T) {
+	db := &memDB{}
+	api := newTestAPI(t, db)
+
+	serve := func(method, target, body, moderator string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		setIdentity(req, moderator, middl.RoleModerator)
+		rr := httptest.NewRecorder()
+		api.Router().ServeHTTP(rr, req)
+		return rr
+	}
+
+	serve(http.MethodPost, "/comments", `{"news_id": 1, "content": "обычный"}`, "")
+	serve(http.MethodPost, "/comments", `{"news_id": 1, "content": "спорный ***", "status": "pending"}`, "")
+	serve(http.MethodPost, "/comments", `{"news_id": 1, "content": "ещё ***", "status": "pending"}`, "")
+	if rr := serve(http.MethodPost, "/comments", `{"news_id": 1, "content": "x", "status": "bogus"}`, ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid status: expected 400, got %d", rr.Code)
+	}
+
+	var queue []storage.Comment
+	rr := serve(http.MethodGet, "/moderation/queue?limit=1", "", "mod")
+	json.NewDecoder(rr.Body).Decode(&queue)
+	if len(queue) != 1 || queue[0].ID != 2 || rr.Header().Get("X-Next-After") != "2" {
+		t.Fatalf("unexpected queue page: %+v, next=%q", queue, rr.Header().Get("X-Next-After"))
+	}
+	rr = serve(http.MethodGet, "/moderation/queue?after=2", "", "mod")
+	json.NewDecoder(rr.Body).Decode(&queue)
+	if len(queue) != 1 || queue[0].ID != 3 {
+		t.Fatalf("unexpected second queue page: %+v", queue)
+	}
+
+	if rr := serve(http.MethodPost, "/moderation/comments/2/approve", "", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous moderation: expected 401, got %d", rr.Code)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/moderation/comments/2/approve", nil)
+	setIdentity(req, "carol")
+	rr = httptest.NewRecorder()
+	api.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("moderation without the role: expected 403, got %d", rr.Code)
+	}
+	if rr := serve(http.MethodPost, "/moderation/comments/3/reject", "", "mod"); rr.Code != http.StatusBadRequest {
+		t.Errorf("reject without reason: expected 400, got %d", rr.Code)
+	}
+	if rr := serve(http.MethodPost, "/moderation/comments/99/approve", "", "mod"); rr.Code != http.StatusNotFound {
+		t.Errorf("unknown comment: expected 404, got %d", rr.Code)
+	}
+	if rr := serve(http.MethodPost, "/moderation/comments/2/approve", "", "alice"); rr.Code != http.StatusOK {
+		t.Fatalf("approve: expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	if rr := serve(http.MethodPost, "/moderation/comments/3/reject", `{"reason": "оскорбление"}`, "bob"); rr.Code != http.StatusOK {
+		t.Fatalf("reject: expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	serve(http.MethodPost, "/moderation/comments/2/hide", `{"reason": "жалоба"}`, "bob")
+
+	// Публичная выборка видит только одобренные комментарии.
+	var public []storage.Comment
+	json.NewDecoder(serve(http.MethodGet, "/comments?news_id=1", "", "").Body).Decode(&public)
+	if len(public) != 1 || public[0].ID != 1 {
+		t.Errorf("public listing must contain only approved comments, got %+v", public)
+	}
+
+	var audit []storage.AuditEntry
+	json.NewDecoder(serve(http.MethodGet, "/moderation/comments/2/audit", "", "mod").Body).Decode(&audit)
+	if len(audit) != 2 ||
+		audit[0].Actor != "alice" || audit[0].FromStatus != storage.StatusPending || audit[0].ToStatus != storage.StatusApproved ||
+		audit[1].Actor != "bob" || audit[1].ToStatus != storage.StatusHidden || audit[1].Reason != "жалоба" {
+		t.Errorf("unexpected audit trail: %+v", audit)
+	}
+}
+
+// TestModerationUnsignedIdentity проверяет, что без подписи шлюза модерация недоступна,
+// какие бы заголовки ни прислал клиент.
+func TestModerationUnsignedIdentity(t *testing.T) {
+	db := &memDB{comments: []storage.Comment{{ID: 1, NewsID: 1, Status: storage.StatusPending}}}
+	h := middl.VerifyIdentity([]byte("secret"), 5*time.Minute)(newTestAPI(t, db).Router())
+
+	tests := []struct {
+		name   string
+		header http.Header
+	}{
+		{"X-Moderator", http.Header{"X-Moderator": {"mod"}}},
+		{"unsigned identity", http.Header{middl.UserIDHeader: {"mod"}, middl.UserRolesHeader: {middl.RoleModerator}}},
+	}
+	for _, tt := range tests {
+		for _, target := range []string{"/moderation/queue", "/moderation/comments/1/approve"} {
+			method := http.MethodPost
+			if target == "/moderation/queue" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, target, nil)
+			req.Header = tt.header.Clone()
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			if rr.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s: expected 401, got %d", tt.name, target, rr.Code)
+			}
+		}
+	}
+	if db.comments[0].Status != storage.StatusPending {
+		t.Errorf("comment status changed to %q", db.comments[0].Status)
+	}
+}
+
+func TestEditComment(t *testing.T) {
+	db := &memDB{}
+	api := newTestAPI(t, db)
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		setIdentity(req, "u1")
+		rr := httptest.NewRecorder()
+		api.Router().ServeHTTP(rr, req)
+		return rr
+	}
+
+	serve(http.MethodPost, "/comments", `{"news_id": 1, "content": "опечтака"}`)
+
+	rr := serve(http.MethodPatch, "/comments/1", `{"content": "опечатка"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("edit: expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var c storage.Comment
+	json.NewDecoder(rr.Body).Decode(&c)
+	if c.Content != "опечатка" || c.EditedAt == nil || c.Status != storage.StatusApproved {
+		t.Errorf("unexpected edited comment: %+v", c)
+	}
+
+	serve(http.MethodPatch, "/comments/1", `{"content": "опечатка ***", "status": "pending"}`)
+	if db.comments[0].Status != storage.StatusPending {
+		t.Errorf("flagged edit must send the comment to moderation, got %q", db.comments[0].Status)
+	}
+
+	var revisions []storage.Revision
+	json.NewDecoder(serve(http.MethodGet, "/comments/1/revisions", "").Body).Decode(&revisions)
+	if len(revisions) != 2 || revisions[0].Content != "опечтака" || revisions[1].Content != "опечатка" {
+		t.Errorf("unexpected revisions: %+v", revisions)
+	}
+
+	if rr := serve(http.MethodPatch, "/comments/9", `{"content": "x"}`); rr.Code != http.StatusNotFound {
+		t.Errorf("unknown comment: expected 404, got %d", rr.Code)
+	}
+	if rr := serve(http.MethodPatch, "/comments/1", `{"content": ""}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("empty content: expected 400, got %d", rr.Code)
+	}
+}
+
+func TestRevisionsVisibility(t *testing.T) {
+	db := &memDB{}
+	api := newTestAPI(t, db)
+
+	get := func(target, user string, roles ...string) int {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		setIdentity(req, user, roles...)
+		rr := httptest.NewRecorder()
+		api.Router().ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	db.AddComment(storage.Comment{NewsID: 1, AuthorID: "u1", Content: "на модерации", Status: storage.StatusPending})
+	for _, tt := range []struct {
+		user  string
+		roles []string
+		want  int
+	}{
+		{"", nil, http.StatusNotFound},
+		{"u2", nil, http.StatusNotFound},
+		{"u1", nil, http.StatusOK},
+		{"mod", []string{middl.RoleModerator}, http.StatusOK},
+	} {
+		if code := get("/comments/1/revisions", tt.user, tt.roles...); code != tt.want {
+			t.Errorf("pending comment, user %q: expected %d, got %d", tt.user, tt.want, code)
+		}
+	}
+	if code := get("/comments/9/revisions", "mod", middl.RoleModerator); code != http.StatusNotFound {
+		t.Errorf("unknown comment: expected 404, got %d", code)
+	}
+
+	db.comments[0].Status = storage.StatusApproved
+	if code := get("/comments/1/revisions", ""); code != http.StatusOK {
+		t.Errorf("approved comment: expected 200, got %d", code)
+	}
+	db.DeleteComment(1)
+	if code := get("/comments/1/revisions", ""); code != http.StatusNotFound {
+		t.Errorf("deleted comment: expected 404, got %d", code)
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	db := &memDB{}
+	api := newTestAPI(t, db)
+
+	serve := func(method, target, moderator string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		setIdentity(req, moderator, middl.RoleModerator)
+		rr := httptest.NewRecorder()
+		api.Router().ServeHTTP(rr, req)
+		return rr
+	}
+
+	db.AddComment(storage.Comment{NewsID: 1, Content: "родитель", Status: storage.StatusApproved})
+
+	if rr := serve(http.MethodDelete, "/comments?id=1", "alice"); rr.Code != http.StatusNoContent || rr.Header().Get(NewsIDHeader) != "1" {
+		t.Fatalf("delete: expected 204 with %s, got %d %q", NewsIDHeader, rr.Code, rr.Header().Get(NewsIDHeader))
+	}
+	if db.comments[0].DeletedAt == nil {
+		t.Fatal("comment must be soft-deleted")
+	}
+	if rr := serve(http.MethodDelete, "/comments?id=1", "alice"); rr.Code != http.StatusNotFound {
+		t.Errorf("second delete: expected 404, got %d", rr.Code)
+	}
+
+	if rr := serve(http.MethodPost, "/moderation/comments/1/restore", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous restore: expected 401, got %d", rr.Code)
+	}
+	if rr := serve(http.MethodPost, "/moderation/comments/1/restore", "alice"); rr.Code != http.StatusOK {
+		t.Fatalf("restore: expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	if db.comments[0].DeletedAt != nil || db.comments[0].Content != "родитель" {
+		t.Errorf("comment is not restored: %+v", db.comments[0])
+	}
+	if rr := serve(http.MethodPost, "/moderation/comments/1/restore", "alice"); rr.Code != http.StatusNotFound {
+		t.Errorf("restore of a live comment: expected 404, got %d", rr.Code)
+	}
+	if len(db.audit) != 1 || db.audit[0].FromStatus != storage.StatusDeleted || db.audit[0].Actor != "alice" {
+		t.Errorf("restore is not audited: %+v", db.audit)
+	}
+}
+
+func TestAddCommentIdempotencyKey(t *testing.T) {
+	db := &memDB{}
+	api := newTestAPI(t, db)
+
+	post := func(user, key string) int {
+		req := httptest.NewRequest(http.MethodPost, "/comments", bytes.NewBufferString(`{"news_id": 1, "content": "повтор"}`))
+		setIdentity(req, user)
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		rr := httptest.NewRecorder()
+		api.Router().ServeHTTP(rr, req)
+		var body struct {
+			ID int `json:"id"`
+		}
+		json.NewDecoder(rr.Body).Decode(&body)
+		return body.ID
+	}
+
+	first := post("alice", "k1")
+	if again := post("alice", "k1"); again != first || len(db.comments) != 1 {
+		t.Fatalf("replayed request must return the stored comment %d, got %d with %d comments", first, again, len(db.comments))
+	}
+	if other := post("bob", "k1"); other == first {
+		t.Error("keys of different authors must not collide")
+	}
+	post("alice", "")
+	post("alice", "")
+	if len(db.comments) != 4 {
+		t.Errorf("requests without a key must not be deduplicated, got %d comments", len(db.comments))
+	}
+}
+
+func TestAuthorship(t *testing.T) {
+	db := &memDB{}
+	api := newTestAPI(t, db)
+
+	serve := func(method, target, body, user string, roles ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		setIdentity(req, user, roles...)
+		if user != "" {
+			req.Header.Set(middl.UserNameHeader, "Имя "+user)
+		}
+		rr := httptest.NewRecorder()
+		api.Router().ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Поля автора в теле запроса подделать нельзя: автор берётся из заголовков.
+	serve(http.MethodPost, "/comments", `{"news_id": 1, "content": "первый", "author_id": "bob"}`, "alice")
+	serve(http.MethodPost, "/comments", `{"news_id": 1, "content": "аноним", "author_id": "bob"}`, "")
+	serve(http.MethodPost, "/comments", `{"news_id": 2, "content": "второй"}`, "alice")
+	if db.comments[0].AuthorID != "alice" || db.comments[0].AuthorName != "Имя alice" || db.comments[1].AuthorID != "" {
+		t.Fatalf("unexpected authors: %+v", db.comments)
+	}
+
+	var list []storage.Comment
+	rr := serve(http.MethodGet, "/authors/alice/comments", "", "")
+	json.NewDecoder(rr.Body).Decode(&list)
+	if len(list) != 2 || list[0].ID != 3 || list[1].ID != 1 || rr.Header().Get("X-Total-Count") != "2" {
+		t.Errorf("unexpected author listing: %+v", list)
+	}
+
+	tests := []struct {
+		name  string
+		id    string
+		user  string
+		roles []string
+		want  int
+	}{
+		{"anonymous", "1", "", nil, http.StatusUnauthorized},
+		{"other user", "1", "bob", nil, http.StatusForbidden},
+		{"anonymous comment", "2", "bob", nil, http.StatusForbidden},
+		{"unknown comment", "9", "alice", nil, http.StatusNotFound},
+		{"author", "1", "alice", nil, http.StatusOK},
+		{"moderator", "2", "mod", []string{middl.RoleModerator}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rr := serve(http.MethodPatch, "/comments/"+tt.id, `{"content": "правка"}`, tt.user, tt.roles...); rr.Code != tt.want {
+			t.Errorf("edit by %s: expected %d, got %d", tt.name, tt.want, rr.Code)
+		}
+	}
+
+	if rr := serve(http.MethodDelete, "/comments?id=3", "", "bob"); rr.Code != http.StatusForbidden {
+		t.Errorf("delete by another user: expected 403, got %d", rr.Code)
+	}
+	if rr := serve(http.MethodDelete, "/comments?id=3", "", "alice"); rr.Code != http.StatusNoContent {
+		t.Errorf("delete by the author: expected 204, got %d", rr.Code)
+	}
+}
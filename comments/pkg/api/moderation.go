@@ -0,0 +1,191 @@
+// api/moderation.go
+package api
+
+import (
+	"APIGateway/comments/pkg/middl"
+	"APIGateway/comments/pkg/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// moderationActions — действие модератора и статус, в который оно переводит комментарий.
+var moderationActions = map[string]string{
+	"approve": storage.StatusApproved,
+	"reject":  storage.StatusRejected,
+	"hide":    storage.StatusHidden,
+}
+
+func (api *API) moderationEndpoints() {
+	api.router.HandleFunc("/moderation/queue", api.queueHandler).Methods(http.MethodGet)
+	api.router.HandleFunc("/moderation/comments/{id:[0-9]+}/audit", api.auditHandler).Methods(http.MethodGet)
+	api.router.HandleFunc("/moderation/comments/{id:[0-9]+}/{action:approve|reject|hide}", api.moderateHandler).Methods(http.MethodPost)
+	api.router.HandleFunc("/moderation/comments/{id:[0-9]+}/restore", api.restoreHandler).Methods(http.MethodPost)
+}
+
+// queueHandler возвращает очередь модерации: комментарии со статусом status (по умолчанию pending)
+// от старых к новым. Следующая страница запрашивается с after=<ID последнего комментария>.
+func (api *API) queueHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireModerator(w, r); !ok {
+		return
+	}
+
+	q, err := queueOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	comments, err := api.db.ModerationQueue(q)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get moderation queue", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(comments) == q.Limit {
+		w.Header().Set("X-Next-After", strconv.Itoa(comments[len(comments)-1].ID))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comments)
+}
+
+func queueOptions(r *http.Request) (storage.QueueQuery, error) {
+	query := r.URL.Query()
+	q := storage.QueueQuery{Status: storage.StatusPending, Limit: defaultPageLimit}
+	if s := query.Get("status"); s != "" {
+		if !storage.ValidStatus(s) {
+			return q, fmt.Errorf("invalid status parameter: %q", s)
+		}
+		q.Status = s
+	}
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 || limit > maxPageLimit {
+			return q, fmt.Errorf("invalid limit parameter: %q (1..%d)", l, maxPageLimit)
+		}
+		q.Limit = limit
+	}
+	if a := query.Get("after"); a != "" {
+		after, err := strconv.Atoi(a)
+		if err != nil || after < 0 {
+			return q, fmt.Errorf("invalid after parameter: %q", a)
+		}
+		q.AfterID = after
+	}
+	return q, nil
+}
+
+// moderateHandler одобряет, отклоняет или скрывает комментарий. Отклонение и скрытие требуют причины.
+func (api *API) moderateHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	actor, reason, ok := moderationRequest(w, r)
+	if !ok {
+		return
+	}
+	status := moderationActions[vars["action"]]
+	if status != storage.StatusApproved && strings.TrimSpace(reason) == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+
+	c, err := api.db.Moderate(storage.Action{CommentID: id, Status: status, Actor: actor, Reason: reason})
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to moderate comment", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "comment moderated", "actor", actor, "action", vars["action"], "comment_id", id)
+	w.Header().Set(NewsIDHeader, strconv.Itoa(c.NewsID))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// restoreHandler восстанавливает удалённый комментарий.
+func (api *API) restoreHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	actor, reason, ok := moderationRequest(w, r)
+	if !ok {
+		return
+	}
+	c, err := api.db.RestoreComment(storage.Action{CommentID: id, Actor: actor, Reason: reason})
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "comment not found or not deleted", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to restore comment", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "comment restored", "actor", actor, "comment_id", id)
+	w.Header().Set(NewsIDHeader, strconv.Itoa(c.NewsID))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// requireModerator пропускает только пользователей с ролью модератора:
+// анонимный запрос получает 401, пользователь без роли — 403.
+func requireModerator(w http.ResponseWriter, r *http.Request) (middl.Identity, bool) {
+	id, ok := middl.IdentityFromRequest(r)
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return id, false
+	}
+	if !id.HasRole(middl.RoleModerator) {
+		http.Error(w, "moderator role required", http.StatusForbidden)
+		return id, false
+	}
+	return id, true
+}
+
+// moderationRequest проверяет права модератора и читает необязательную причину из тела запроса.
+// В журнал модерации попадает ID пользователя. При ошибке отвечает клиенту и возвращает ok=false.
+func moderationRequest(w http.ResponseWriter, r *http.Request) (actor, reason string, ok bool) {
+	id, ok := requireModerator(w, r)
+	if !ok {
+		return "", "", false
+	}
+	actor = id.ID
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return "", "", false
+		}
+	}
+	return actor, body.Reason, true
+}
+
+// auditHandler возвращает журнал модерации комментария.
+func (api *API) auditHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireModerator(w, r); !ok {
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	entries, err := api.db.AuditLog(id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get audit log", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
@@ -0,0 +1,98 @@
+package middl
+
+import (
+	"APIGateway/pkg/logging"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Заголовки с личностью пользователя. Их выставляет шлюз после аутентификации
+// и подписывает общим секретом; см. VerifyIdentity.
+const (
+	UserIDHeader    = "X-User-ID"
+	UserNameHeader  = "X-User-Name"
+	UserRolesHeader = "X-User-Roles"
+	// UserSignatureHeader — подпись шлюза: "<unix-время>.<HMAC-SHA256 в base64url>".
+	UserSignatureHeader = "X-User-Signature"
+)
+
+// RoleModerator — роль, которой разрешены модерация и правка чужих комментариев.
+const RoleModerator = "moderator"
+
+// Identity — пользователь, от имени которого выполняется запрос.
+type Identity struct {
+	ID    string
+	Name  string
+	Roles []string
+}
+
+// IdentityFromRequest читает личность из заголовков запроса; ok=false — запрос анонимный.
+func IdentityFromRequest(r *http.Request) (id Identity, ok bool) {
+	id.ID = strings.TrimSpace(r.Header.Get(UserIDHeader))
+	if id.ID == "" {
+		return Identity{}, false
+	}
+	id.Name = strings.TrimSpace(r.Header.Get(UserNameHeader))
+	for _, role := range strings.Split(r.Header.Get(UserRolesHeader), ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			id.Roles = append(id.Roles, role)
+		}
+	}
+	return id, true
+}
+
+// HasRole сообщает, есть ли у пользователя роль role.
+func (id Identity) HasRole(role string) bool {
+	for _, r := range id.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyIdentity проверяет подпись заголовков личности секретом, общим со шлюзом.
+// Запрос с неверной или устаревшей (старше maxAge) подписью обрабатывается как анонимный;
+// с пустым secret анонимными считаются все запросы. Записи лога запроса получают user_id.
+func VerifyIdentity(secret []byte, maxAge time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(UserIDHeader) != "" && (len(secret) == 0 || !validSignature(r.Header, secret, maxAge, time.Now())) {
+				slog.WarnContext(r.Context(), "invalid identity signature", logging.UserKey, r.Header.Get(UserIDHeader))
+				r.Header.Del(UserIDHeader)
+				r.Header.Del(UserNameHeader)
+				r.Header.Del(UserRolesHeader)
+			}
+			r.Header.Del(UserSignatureHeader)
+			if id := r.Header.Get(UserIDHeader); id != "" {
+				r = r.WithContext(logging.With(r.Context(), slog.String(logging.UserKey, id)))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func validSignature(h http.Header, secret []byte, maxAge time.Duration, now time.Time) bool {
+	ts, sig, ok := strings.Cut(h.Get(UserSignatureHeader), ".")
+	if !ok {
+		return false
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(sec, 0)); age > maxAge || age < -maxAge {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", ts, h.Get(UserIDHeader), h.Get(UserNameHeader), h.Get(UserRolesHeader))
+	want := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(sig), []byte(want))
+}
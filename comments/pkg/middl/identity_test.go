@@ -0,0 +1,55 @@
+package middl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// gatewaySignature подписывает заголовки так же, как шлюз.
+func gatewaySignature(secret []byte, ts time.Time, id, name, roles string) string {
+	t := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", t, id, name, roles)
+	return t + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyIdentity(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Now()
+	tests := []struct {
+		name   string
+		secret []byte
+		sig    string
+		want   string
+	}{
+		{"valid", secret, gatewaySignature(secret, now, "u1", "", "moderator"), "u1"},
+		{"unsigned", secret, "", ""},
+		{"wrong secret", secret, gatewaySignature([]byte("other"), now, "u1", "", "moderator"), ""},
+		{"tampered roles", secret, gatewaySignature(secret, now, "u1", "", ""), ""},
+		{"expired", secret, gatewaySignature(secret, now.Add(-time.Hour), "u1", "", "moderator"), ""},
+		{"no secret", nil, gatewaySignature(nil, now, "u1", "", "moderator"), ""},
+	}
+	for _, tt := range tests {
+		var got Identity
+		h := VerifyIdentity(tt.secret, 5*time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, _ = IdentityFromRequest(r)
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(UserIDHeader, "u1")
+		req.Header.Set(UserRolesHeader, "moderator")
+		if tt.sig != "" {
+			req.Header.Set(UserSignatureHeader, tt.sig)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if got.ID != tt.want {
+			t.Errorf("%s: got user %q, want %q", tt.name, got.ID, tt.want)
+		}
+	}
+}
@@ -1,9 +1,8 @@
 package middl
 
 import (
+	"APIGateway/pkg/logging"
 	"context"
-	"github.com/gofrs/uuid"
-	"log"
 	"net/http"
 	"time"
 )
@@ -30,11 +29,7 @@ func (lrw *LoggingResponseWriter) WriteHeader(code int) {
 func Middle(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		start := time.Now()
-		reqID := req.URL.Query().Get("request_id")
-		if reqID == "" {
-			rID, _ := uuid.NewV4()
-			reqID = rID.String()
-		}
+		reqID, _ := logging.RequestID(req)
 		req.Header.Set("X-Request-ID", reqID)
 		w.Header().Set("X-Request-ID", reqID)
 
@@ -46,19 +41,12 @@ func Middle(next http.Handler) http.Handler {
 		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Request-ID")
 
+		req = req.WithContext(context.WithValue(logging.WithRequest(req, reqID), RequestIDKey, reqID))
+
 		lrw := NewLoggingResponseWriter(w)
 		next.ServeHTTP(lrw, req)
 
-		log.Printf(
-			"<-- time: %s, client ip: %s, method: %s, url: %s, status code: %d %s, trace id: %s",
-			start.Format(time.RFC3339),
-			req.RemoteAddr,
-			req.Method,
-			req.URL.Path,
-			lrw.statusCode,
-			http.StatusText(lrw.statusCode),
-			reqID,
-		)
+		logging.LogRequest(req, lrw.statusCode, start)
 	})
 }
 
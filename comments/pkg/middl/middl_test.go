@@ -0,0 +1,32 @@
+package middl
+
+import (
+	"APIGateway/pkg/logging"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleRequestID(t *testing.T) {
+	var gotID string
+	var gotFields int
+	handler := Middle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID = GetRequestID(r.Context())
+		gotFields = len(logging.Fields(r.Context()))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/comments?news_id=1", nil)
+	req.Header.Set("X-Request-ID", "req-7")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if gotID != "req-7" {
+		t.Errorf("handler must see the gateway request ID, got %q", gotID)
+	}
+	if gotFields == 0 {
+		t.Error("request ID must be added to the log fields of the request")
+	}
+	if w.Header().Get("X-Request-ID") != "req-7" {
+		t.Errorf("response X-Request-ID = %q", w.Header().Get("X-Request-ID"))
+	}
+}
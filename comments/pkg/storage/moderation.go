@@ -0,0 +1,46 @@
+// storage/moderation.go
+package storage
+
+import "errors"
+
+// ErrNotFound — комментарий не существует.
+var ErrNotFound = errors.New("comment not found")
+
+// QueueQuery — параметры выборки очереди модерации.
+type QueueQuery struct {
+	// Status — статус комментариев в очереди, по умолчанию pending.
+	Status string
+	Limit  int
+	// AfterID — ID последнего комментария предыдущей страницы.
+	AfterID int
+}
+
+// Action — решение модератора по комментарию.
+type Action struct {
+	CommentID int
+	// Status — новый статус комментария.
+	Status string
+	// Actor — кто принял решение.
+	Actor  string
+	Reason string
+}
+
+// AuditEntry — запись журнала модерации.
+type AuditEntry struct {
+	ID         int    `json:"id"`
+	CommentID  int    `json:"comment_id"`
+	Actor      string `json:"actor"`
+	FromStatus string `json:"from_status"`
+	ToStatus   string `json:"to_status"`
+	Reason     string `json:"reason,omitempty"`
+	Time       int64  `json:"time"`
+}
+
+// ValidStatus сообщает, является ли s известным статусом комментария.
+func ValidStatus(s string) bool {
+	switch s {
+	case StatusApproved, StatusPending, StatusRejected, StatusHidden:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+// storage/page.go
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalidCursor возвращается для повреждённого курсора или курсора от другой сортировки.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PageQuery — параметры постраничной выборки комментариев новости.
+type PageQuery struct {
+	Limit  int
+	Cursor string
+	// Offset — смещение страницы при сортировке replies, где курсор не применяется.
+	Offset int
+	// Sort — newest, oldest или replies.
+	Sort string
+	// RootsOnly ограничивает выборку комментариями верхнего уровня, например для дерева.
+	RootsOnly bool
+}
+
+// Page — сведения о странице выборки.
+type Page struct {
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	NextOffset int    `json:"next_offset,omitempty"`
+}
+
+// cursor — позиция последнего комментария страницы в порядке сортировки.
+type cursor struct {
+	Sort    string `json:"s"`
+	PubTime int64  `json:"t"`
+	ID      int    `json:"id"`
+}
+
+func (c cursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s, sort string) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, &c); err != nil || c.Sort != sort {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
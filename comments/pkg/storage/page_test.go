@@ -0,0 +1,21 @@
+package storage
+
+import "testing"
+
+func TestCursor(t *testing.T) {
+	c := cursor{Sort: SortOldest, PubTime: 1700000000, ID: 42}
+	got, err := decodeCursor(c.encode(), SortOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != c {
+		t.Errorf("получили %+v, ожидали %+v", got, c)
+	}
+
+	if _, err := decodeCursor(c.encode(), SortNewest); err != ErrInvalidCursor {
+		t.Error("курсор другой сортировки должен отклоняться")
+	}
+	if _, err := decodeCursor("не base64!", SortNewest); err != ErrInvalidCursor {
+		t.Error("повреждённый курсор должен отклоняться")
+	}
+}
@@ -2,9 +2,12 @@
 package storage
 
 import (
+	"APIGateway/pkg/tracing"
 	"context"
-	"fmt"
+	"errors"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
 )
 
 type Store struct {
@@ -12,31 +15,62 @@ type Store struct {
 }
 
 func New(ctx context.Context, constr string) (*Store, error) {
-	db, err := pgxpool.New(ctx, constr)
+	db, err := tracing.NewPgxPool(ctx, constr)
 	if err != nil {
 		return nil, err
 	}
 	return &Store{db: db}, nil
 }
 
-func (p *Store) AddComment(c Comment) error {
-	_, err := p.db.Exec(context.Background(), `
-    INSERT INTO comments (news_id, parent_id, content) 
-    VALUES ($1, $2, $3)`, c.NewsID, c.ParentID, c.Content)
-	if err != nil {
-		fmt.Println("[AddComment ERROR]", err)
+// Stat возвращает статистику пула соединений.
+func (p *Store) Stat() *pgxpool.Stat {
+	return p.db.Stat()
+}
+
+func (p *Store) AddComment(c Comment) (int, error) {
+	// Ответ без news_id наследует новость родительского комментария,
+	// иначе он не попадёт в выборку комментариев новости.
+	var id int
+	err := p.db.QueryRow(context.Background(), `
+    INSERT INTO comments (news_id, parent_id, content, status, author_id, author_name, idempotency_key) 
+    VALUES (COALESCE(NULLIF($1, 0), (SELECT news_id FROM comments WHERE id = $2)), $2, $3, COALESCE(NULLIF($4, ''), 'approved'), $5, $6, NULLIF($7, ''))
+    ON CONFLICT (author_id, idempotency_key) DO NOTHING
+    RETURNING id`, c.NewsID, c.ParentID, c.Content, c.Status, c.AuthorID, c.AuthorName, c.IdempotencyKey).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Повтор запроса: комментарий с этим ключом уже сохранён.
+		err = p.db.QueryRow(context.Background(),
+			`SELECT id FROM comments WHERE author_id = $1 AND idempotency_key = $2`, c.AuthorID, c.IdempotencyKey).Scan(&id)
 	}
-	return err
+	return id, err
 }
 
+// commentColumns — столбцы comments в порядке Comment.dest.
+const commentColumns = "id, news_id, parent_id, content, pubtime, status, edited_at, deleted_at, author_id, author_name"
+
+// visibleCTE — комментарии новости $1, которые видны публично. Комментарий опубликован,
+// если одобрены он и все его предки: ответ под скрытым или отклонённым комментарием
+// скрывается вместе с веткой. Из опубликованных видны неудалённые и удалённые,
+// под которыми остались видимые ответы (они показываются как "[deleted]").
+const visibleCTE = `
+    WITH RECURSIVE published AS (
+        SELECT id, parent_id, deleted_at FROM comments
+        WHERE news_id = $1 AND parent_id IS NULL AND status = 'approved'
+        UNION ALL
+        SELECT c.id, c.parent_id, c.deleted_at FROM comments c JOIN published p ON c.parent_id = p.id
+        WHERE c.status = 'approved'
+    ), visible AS (
+        SELECT id, parent_id FROM published WHERE deleted_at IS NULL
+        UNION
+        SELECT p.id, p.parent_id FROM published p JOIN visible v ON p.id = v.parent_id
+    )`
+
 func (p *Store) AllComments(newsID int) ([]Comment, error) {
-	rows, err := p.db.Query(context.Background(), `
-    SELECT id, news_id, parent_id, content, pubtime 
+	rows, err := p.db.Query(context.Background(), visibleCTE+`
+    SELECT `+commentColumns+`
     FROM comments 
-    WHERE news_id = $1
+    WHERE id IN (SELECT id FROM visible)
     ORDER BY pubtime DESC`, newsID)
 	if err != nil {
-		fmt.Println("[AllComments ERROR]", err)
 		return nil, err
 	}
 
@@ -45,19 +79,373 @@ func (p *Store) AllComments(newsID int) ([]Comment, error) {
 	var comments []Comment
 	for rows.Next() {
 		var c Comment
-		err = rows.Scan(&c.ID, &c.NewsID, &c.ParentID, &c.Content, &c.PubTime)
+		err = rows.Scan(c.dest()...)
 		if err != nil {
 			return nil, err
 		}
+		c.tombstone()
 		comments = append(comments, c)
 	}
 	return comments, rows.Err()
 }
 
+// CommentsPage возвращает страницу комментариев новости в заданном порядке.
+// При сортировке по времени страницы листаются курсором (pubtime, id), и вставка новых
+// комментариев не сдвигает следующие страницы. Число ответов меняется между запросами,
+// поэтому для sort=replies курсор не принимается, а страницы задаются смещением.
+func (p *Store) CommentsPage(newsID int, q PageQuery) ([]Comment, Page, error) {
+	page := Page{Limit: q.Limit}
+
+	// Публичные выборки показывают только одобренные комментарии.
+	filter := "id IN (SELECT id FROM visible)"
+	if q.RootsOnly {
+		filter += " AND parent_id IS NULL"
+	}
+
+	err := p.db.QueryRow(context.Background(),
+		visibleCTE+" SELECT count(*) FROM comments WHERE "+filter, newsID).Scan(&page.Total)
+	if err != nil {
+		return nil, page, err
+	}
+
+	args := []interface{}{newsID, q.Limit + 1}
+	var after, order string
+	switch q.Sort {
+	case SortOldest:
+		order = "pubtime ASC, id ASC"
+		after = "(pubtime, id) > ($3, $4)"
+	case SortReplies:
+		order = "reply_count DESC, pubtime DESC, id DESC"
+	default:
+		q.Sort = SortNewest
+		order = "pubtime DESC, id DESC"
+		after = "(pubtime, id) < ($3, $4)"
+	}
+
+	where, offset := "TRUE", "0"
+	switch {
+	case q.Sort == SortReplies:
+		if q.Cursor != "" {
+			return nil, page, ErrInvalidCursor
+		}
+		args = append(args, q.Offset)
+		offset = "$3"
+	case q.Cursor != "":
+		c, err := decodeCursor(q.Cursor, q.Sort)
+		if err != nil {
+			return nil, page, err
+		}
+		args = append(args, c.PubTime, c.ID)
+		where = after
+	}
+
+	rows, err := p.db.Query(context.Background(), visibleCTE+`
+    SELECT `+commentColumns+`, reply_count
+    FROM (
+        SELECT c.*,
+               (SELECT count(*) FROM comments r WHERE r.parent_id = c.id AND r.id IN (SELECT id FROM visible))::int AS reply_count
+        FROM comments c
+        WHERE `+filter+`
+    ) s
+    WHERE `+where+`
+    ORDER BY `+order+`
+    LIMIT $2 OFFSET `+offset, args...)
+	if err != nil {
+		return nil, page, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	var last cursor
+	for rows.Next() {
+		var c Comment
+		var replies int
+		if err := rows.Scan(append(c.dest(), &replies)...); err != nil {
+			return nil, page, err
+		}
+		c.tombstone()
+		if len(comments) == q.Limit {
+			if q.Sort == SortReplies {
+				page.NextOffset = q.Offset + q.Limit
+			} else {
+				page.NextCursor = last.encode()
+			}
+			break
+		}
+		comments = append(comments, c)
+		last = cursor{Sort: q.Sort, PubTime: c.PubTime, ID: c.ID}
+	}
+	return comments, page, rows.Err()
+}
+
+func (p *Store) Comment(id int) (Comment, error) {
+	var c Comment
+	err := p.db.QueryRow(context.Background(),
+		`SELECT `+commentColumns+` FROM comments WHERE id = $1`, id).Scan(c.dest()...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Comment{}, ErrNotFound
+	}
+	return c, err
+}
+
+// CommentsByAuthor листает одобренные неудалённые комментарии автора по курсору (pubtime, id).
+func (p *Store) CommentsByAuthor(authorID string, q PageQuery) ([]Comment, Page, error) {
+	page := Page{Limit: q.Limit}
+	const filter = "author_id = $1 AND status = 'approved' AND deleted_at IS NULL"
+
+	err := p.db.QueryRow(context.Background(),
+		"SELECT count(*) FROM comments WHERE "+filter, authorID).Scan(&page.Total)
+	if err != nil {
+		return nil, page, err
+	}
+
+	args := []interface{}{authorID, q.Limit + 1}
+	where := "TRUE"
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor, SortNewest)
+		if err != nil {
+			return nil, page, err
+		}
+		args = append(args, c.PubTime, c.ID)
+		where = "(pubtime, id) < ($3, $4)"
+	}
+
+	rows, err := p.db.Query(context.Background(), `
+    SELECT `+commentColumns+`
+    FROM comments
+    WHERE `+filter+` AND `+where+`
+    ORDER BY pubtime DESC, id DESC
+    LIMIT $2`, args...)
+	if err != nil {
+		return nil, page, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	var last cursor
+	for rows.Next() {
+		var c Comment
+		if err := rows.Scan(c.dest()...); err != nil {
+			return nil, page, err
+		}
+		if len(comments) == q.Limit {
+			page.NextCursor = last.encode()
+			break
+		}
+		comments = append(comments, c)
+		last = cursor{Sort: SortNewest, PubTime: c.PubTime, ID: c.ID}
+	}
+	return comments, page, rows.Err()
+}
+
+// DeleteComment помечает комментарий удалённым, не трогая ответы на него.
 func (p *Store) DeleteComment(id int) error {
-	_, err := p.db.Exec(context.Background(), `DELETE FROM comments WHERE id = $1`, id)
+	tag, err := p.db.Exec(context.Background(),
+		`UPDATE comments SET deleted_at = extract (epoch from now()) WHERE id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RestoreComment снимает пометку об удалении и записывает восстановление в журнал модерации.
+func (p *Store) RestoreComment(a Action) (Comment, error) {
+	ctx := context.Background()
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return Comment{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var c Comment
+	err = tx.QueryRow(ctx, `
+    UPDATE comments SET deleted_at = NULL
+    WHERE id = $1 AND deleted_at IS NOT NULL
+    RETURNING `+commentColumns, a.CommentID).
+		Scan(c.dest()...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Comment{}, ErrNotFound
+	}
+	if err != nil {
+		return Comment{}, err
+	}
+
+	_, err = tx.Exec(ctx, `
+    INSERT INTO moderation_log (comment_id, actor, from_status, to_status, reason)
+    VALUES ($1, $2, $3, $4, $5)`, c.ID, a.Actor, StatusDeleted, c.Status, a.Reason)
+	if err != nil {
+		return Comment{}, err
+	}
+	return c, tx.Commit(ctx)
+}
+
+// PurgeDeleted окончательно удаляет комментарии, удалённые раньше olderThan назад и оставшиеся без ответов.
+// Цепочки удалённых комментариев удаляются снизу вверх за несколько проходов.
+func (p *Store) PurgeDeleted(olderThan time.Duration) (int, error) {
+	total := 0
+	for {
+		tag, err := p.db.Exec(context.Background(), `
+    DELETE FROM comments c
+    WHERE c.deleted_at IS NOT NULL AND c.deleted_at < extract (epoch from now()) - $1
+      AND NOT EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id)`, int64(olderThan.Seconds()))
+		if err != nil {
+			return total, err
+		}
+		if tag.RowsAffected() == 0 {
+			return total, nil
+		}
+		total += int(tag.RowsAffected())
+	}
+}
+
+func (p *Store) ModerationQueue(q QueueQuery) ([]Comment, error) {
+	rows, err := p.db.Query(context.Background(), `
+    SELECT `+commentColumns+`
+    FROM comments
+    WHERE status = $1 AND id > $2 AND deleted_at IS NULL
+    ORDER BY id ASC
+    LIMIT $3`, q.Status, q.AfterID, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		var c Comment
+		if err := rows.Scan(c.dest()...); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+// Moderate меняет статус и пишет журнал в одной транзакции,
+// поэтому каждое изменение статуса модератором оставляет запись в moderation_log.
+func (p *Store) Moderate(a Action) (Comment, error) {
+	ctx := context.Background()
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return Comment{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var c Comment
+	err = tx.QueryRow(ctx, `
+    SELECT `+commentColumns+`
+    FROM comments WHERE id = $1 FOR UPDATE`, a.CommentID).
+		Scan(c.dest()...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Comment{}, ErrNotFound
+	}
+	if err != nil {
+		return Comment{}, err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE comments SET status = $2 WHERE id = $1`, a.CommentID, a.Status); err != nil {
+		return Comment{}, err
+	}
+	_, err = tx.Exec(ctx, `
+    INSERT INTO moderation_log (comment_id, actor, from_status, to_status, reason)
+    VALUES ($1, $2, $3, $4, $5)`, a.CommentID, a.Actor, c.Status, a.Status, a.Reason)
 	if err != nil {
-		fmt.Println("[DeleteComment ERROR]", err)
+		return Comment{}, err
+	}
+
+	c.Status = a.Status
+	return c, tx.Commit(ctx)
+}
+
+func (p *Store) AuditLog(commentID int) ([]AuditEntry, error) {
+	rows, err := p.db.Query(context.Background(), `
+    SELECT id, comment_id, actor, from_status, to_status, reason, created_at
+    FROM moderation_log
+    WHERE comment_id = $1
+    ORDER BY id ASC`, commentID)
+	if err != nil {
+		return nil, err
 	}
-	return err
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.CommentID, &e.Actor, &e.FromStatus, &e.ToStatus, &e.Reason, &e.Time); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// EditComment сохраняет прежний текст в comment_revisions и заменяет его новым в одной транзакции.
+func (p *Store) EditComment(e Edit) (Comment, error) {
+	ctx := context.Background()
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return Comment{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var c Comment
+	err = tx.QueryRow(ctx, `
+    SELECT `+commentColumns+`
+    FROM comments WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, e.CommentID).
+		Scan(c.dest()...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Comment{}, ErrNotFound
+	}
+	if err != nil {
+		return Comment{}, err
+	}
+
+	_, err = tx.Exec(ctx, `
+    INSERT INTO comment_revisions (comment_id, content, created_at)
+    VALUES ($1, $2, COALESCE($3, $4))`, c.ID, c.Content, c.EditedAt, c.PubTime)
+	if err != nil {
+		return Comment{}, err
+	}
+
+	c.Content = e.Content
+	if e.Status == StatusPending {
+		c.Status = StatusPending
+	}
+	err = tx.QueryRow(ctx, `
+    UPDATE comments SET content = $2, status = $3, edited_at = extract (epoch from now())
+    WHERE id = $1
+    RETURNING edited_at`, c.ID, c.Content, c.Status).Scan(&c.EditedAt)
+	if err != nil {
+		return Comment{}, err
+	}
+	return c, tx.Commit(ctx)
+}
+
+func (p *Store) Revisions(commentID int) ([]Revision, error) {
+	rows, err := p.db.Query(context.Background(), `
+    SELECT id, comment_id, content, created_at
+    FROM comment_revisions
+    WHERE comment_id = $1
+    ORDER BY id ASC`, commentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []Revision{}
+	for rows.Next() {
+		var r Revision
+		if err := rows.Scan(&r.ID, &r.CommentID, &r.Content, &r.Time); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
+
+func (p *Store) Ping(ctx context.Context) error {
+	return p.db.Ping(ctx)
 }
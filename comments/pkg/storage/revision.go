@@ -0,0 +1,20 @@
+// storage/revision.go
+package storage
+
+// Edit — правка текста комментария.
+type Edit struct {
+	CommentID int
+	Content   string
+	// Status — pending, если цензор отправил новый текст на модерацию;
+	// иначе статус комментария не меняется.
+	Status string
+}
+
+// Revision — прежняя версия текста комментария.
+type Revision struct {
+	ID        int    `json:"id"`
+	CommentID int    `json:"comment_id"`
+	Content   string `json:"content"`
+	// Time — когда была опубликована эта версия текста.
+	Time int64 `json:"time"`
+}
@@ -1,16 +1,84 @@
 // storage/storage.go
 package storage
 
+import (
+	"context"
+	"time"
+)
+
+// Статусы модерации комментария.
+const (
+	// StatusApproved — комментарий опубликован.
+	StatusApproved = "approved"
+	// StatusPending — цензор отправил комментарий на модерацию.
+	StatusPending = "pending"
+	// StatusRejected — модератор отклонил комментарий.
+	StatusRejected = "rejected"
+	// StatusHidden — модератор скрыл ранее опубликованный комментарий.
+	StatusHidden = "hidden"
+	// StatusDeleted — отметка журнала модерации для удалённого комментария; в поле status не хранится.
+	StatusDeleted = "deleted"
+)
+
+// DeletedContent — текст, который показывается вместо удалённого комментария с ответами.
+const DeletedContent = "[deleted]"
+
 type Comment struct {
 	ID       int    `json:"id"`
 	NewsID   int    `json:"news_id"`
 	ParentID *int   `json:"parent_id,omitempty"`
 	Content  string `json:"content"`
 	PubTime  int64  `json:"pubtime,omitempty"`
+	Status   string `json:"status,omitempty"`
+	// EditedAt — время последнего редактирования; nil, если комментарий не редактировался.
+	EditedAt *int64 `json:"edited_at,omitempty"`
+	// DeletedAt — время удаления; удалённый комментарий остаётся в дереве, пока у него есть ответы.
+	DeletedAt *int64 `json:"deleted_at,omitempty"`
+	// AuthorID и AuthorName — пользователь, опубликовавший комментарий; пустые у анонимных комментариев.
+	AuthorID   string `json:"author_id,omitempty"`
+	AuthorName string `json:"author_name,omitempty"`
+	// IdempotencyKey — ключ запроса на создание, уникальный в пределах автора; наружу не отдаётся.
+	IdempotencyKey string `json:"-"`
+}
+
+// dest возвращает поля комментария для Scan в порядке commentColumns.
+func (c *Comment) dest() []any {
+	return []any{&c.ID, &c.NewsID, &c.ParentID, &c.Content, &c.PubTime, &c.Status,
+		&c.EditedAt, &c.DeletedAt, &c.AuthorID, &c.AuthorName}
+}
+
+// tombstone скрывает текст удалённого комментария.
+func (c *Comment) tombstone() {
+	if c.DeletedAt != nil {
+		c.Content = DeletedContent
+	}
 }
 
 type Interface interface {
 	AllComments(newsID int) ([]Comment, error)
-	AddComment(Comment) error
+	CommentsPage(newsID int, q PageQuery) ([]Comment, Page, error)
+	// Comment возвращает комментарий по ID в любом статусе; ErrNotFound — комментария нет.
+	Comment(id int) (Comment, error)
+	// CommentsByAuthor возвращает опубликованные комментарии автора, от новых к старым.
+	CommentsByAuthor(authorID string, q PageQuery) ([]Comment, Page, error)
+	// AddComment сохраняет комментарий и возвращает его ID. Если у автора уже есть комментарий
+	// с тем же IdempotencyKey, новый не сохраняется и возвращается ID прежнего.
+	AddComment(Comment) (int, error)
+	// DeleteComment мягко удаляет комментарий; ErrNotFound — комментария нет или он уже удалён.
 	DeleteComment(id int) error
+	// RestoreComment восстанавливает удалённый комментарий.
+	RestoreComment(a Action) (Comment, error)
+	// PurgeDeleted окончательно удаляет давно удалённые комментарии без ответов и возвращает их число.
+	PurgeDeleted(olderThan time.Duration) (int, error)
+	// EditComment заменяет текст комментария, сохраняя прежний как ревизию.
+	EditComment(e Edit) (Comment, error)
+	// Revisions возвращает прежние версии комментария от старых к новым.
+	Revisions(commentID int) ([]Revision, error)
+	// ModerationQueue возвращает комментарии с заданным статусом, от старых к новым.
+	ModerationQueue(q QueueQuery) ([]Comment, error)
+	// Moderate меняет статус комментария и записывает действие в журнал модерации.
+	Moderate(a Action) (Comment, error)
+	// AuditLog возвращает журнал модерации комментария от старых записей к новым.
+	AuditLog(commentID int) ([]AuditEntry, error)
+	Ping(ctx context.Context) error
 }
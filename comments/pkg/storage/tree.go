@@ -0,0 +1,103 @@
+// storage/tree.go
+package storage
+
+import "sort"
+
+// Порядок сортировки комментариев.
+const (
+	SortNewest  = "newest"
+	SortOldest  = "oldest"
+	SortReplies = "replies"
+)
+
+// Node — комментарий с вложенными ответами.
+type Node struct {
+	Comment
+	// ReplyCount — число прямых ответов, включая отрезанные ограничением глубины.
+	ReplyCount int `json:"reply_count"`
+	// TotalReplies — число всех ответов в поддереве.
+	TotalReplies int     `json:"total_replies"`
+	Replies      []*Node `json:"replies"`
+}
+
+// TreeOptions — параметры построения дерева.
+type TreeOptions struct {
+	// MaxDepth — сколько уровней ответов включать под корневыми комментариями; 0 — без ограничения.
+	MaxDepth int
+	// Sort — порядок на каждом уровне: newest, oldest или replies (по числу прямых ответов).
+	Sort string
+}
+
+// BuildTree собирает дерево из плоского списка комментариев одной новости.
+// Ответы, родитель которых отсутствует в списке, становятся корневыми.
+func BuildTree(comments []Comment, opts TreeOptions) []*Node {
+	nodes := make(map[int]*Node, len(comments))
+	for _, c := range comments {
+		nodes[c.ID] = &Node{Comment: c, Replies: []*Node{}}
+	}
+
+	roots := []*Node{}
+	for _, c := range comments {
+		n := nodes[c.ID]
+		if c.ParentID != nil {
+			if parent, ok := nodes[*c.ParentID]; ok && parent != n {
+				parent.Replies = append(parent.Replies, n)
+				continue
+			}
+		}
+		roots = append(roots, n)
+	}
+
+	for _, n := range roots {
+		countReplies(n)
+	}
+	sortLevel(roots, opts.Sort)
+	for _, n := range roots {
+		prune(n, 1, opts)
+	}
+	return roots
+}
+
+// countReplies заполняет счётчики ответов и возвращает размер поддерева без самого узла.
+func countReplies(n *Node) int {
+	n.ReplyCount = len(n.Replies)
+	total := 0
+	for _, r := range n.Replies {
+		total += 1 + countReplies(r)
+	}
+	n.TotalReplies = total
+	return total
+}
+
+// prune сортирует ответы и отрезает уровни глубже MaxDepth; счётчики при этом сохраняются.
+func prune(n *Node, depth int, opts TreeOptions) {
+	if opts.MaxDepth > 0 && depth > opts.MaxDepth {
+		n.Replies = []*Node{}
+		return
+	}
+	sortLevel(n.Replies, opts.Sort)
+	for _, r := range n.Replies {
+		prune(r, depth+1, opts)
+	}
+}
+
+func sortLevel(nodes []*Node, order string) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		switch order {
+		case SortOldest:
+			if a.PubTime != b.PubTime {
+				return a.PubTime < b.PubTime
+			}
+			return a.ID < b.ID
+		case SortReplies:
+			if a.ReplyCount != b.ReplyCount {
+				return a.ReplyCount > b.ReplyCount
+			}
+		}
+		if a.PubTime != b.PubTime {
+			return a.PubTime > b.PubTime
+		}
+		return a.ID > b.ID
+	})
+}
@@ -0,0 +1,50 @@
+package storage
+
+import "testing"
+
+func intPtr(v int) *int { return &v }
+
+func testComments() []Comment {
+	return []Comment{
+		{ID: 1, NewsID: 1, Content: "корень 1", PubTime: 100},
+		{ID: 2, NewsID: 1, Content: "корень 2", PubTime: 200},
+		{ID: 3, NewsID: 1, ParentID: intPtr(1), Content: "ответ на 1", PubTime: 300},
+		{ID: 4, NewsID: 1, ParentID: intPtr(3), Content: "ответ на 3", PubTime: 400},
+		{ID: 5, NewsID: 1, ParentID: intPtr(1), Content: "ещё ответ на 1", PubTime: 500},
+		{ID: 6, NewsID: 1, ParentID: intPtr(99), Content: "родитель не найден", PubTime: 50},
+	}
+}
+
+func TestBuildTree(t *testing.T) {
+	roots := BuildTree(testComments(), TreeOptions{Sort: SortOldest})
+
+	if len(roots) != 3 {
+		t.Fatalf("ожидалось 3 корня, получено %d", len(roots))
+	}
+	if roots[0].ID != 6 || roots[1].ID != 1 || roots[2].ID != 2 {
+		t.Errorf("неверный порядок корней: %d %d %d", roots[0].ID, roots[1].ID, roots[2].ID)
+	}
+	root := roots[1]
+	if root.ReplyCount != 2 || root.TotalReplies != 3 {
+		t.Errorf("счётчики ответов: получили %d/%d, ожидали 2/3", root.ReplyCount, root.TotalReplies)
+	}
+	if root.Replies[0].ID != 3 || root.Replies[0].Replies[0].ID != 4 {
+		t.Errorf("неверная вложенность ответов")
+	}
+}
+
+func TestBuildTreeDepthAndSort(t *testing.T) {
+	roots := BuildTree(testComments(), TreeOptions{MaxDepth: 1, Sort: SortReplies})
+
+	if roots[0].ID != 1 {
+		t.Fatalf("при сортировке по ответам первым должен идти комментарий 1, получили %d", roots[0].ID)
+	}
+	reply := roots[0].Replies[0]
+	if reply.ID != 3 {
+		t.Fatalf("первым ответом должен быть комментарий 3 с ответом, получили %d", reply.ID)
+	}
+	if len(reply.Replies) != 0 || reply.ReplyCount != 1 {
+		t.Errorf("ответы глубже ограничения должны быть отрезаны с сохранением счётчика: %d/%d",
+			len(reply.Replies), reply.ReplyCount)
+	}
+}
@@ -3,11 +3,23 @@ package main
 import (
 	"APIGateway/gateway/config"
 	"APIGateway/gateway/pkg/api"
+	"APIGateway/gateway/pkg/auth"
 	"APIGateway/gateway/pkg/middl"
+	"APIGateway/gateway/pkg/upstream"
+	"APIGateway/pkg/logging"
+	"APIGateway/pkg/metrics"
+	"APIGateway/pkg/tracing"
+	"context"
 	"flag"
+	"fmt"
 	"github.com/joho/godotenv"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 type server struct {
@@ -21,22 +33,131 @@ func init() {
 }
 
 func main() {
+	logs, err := logging.FromEnv("gateway", "GATEWAY")
+	if err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+	err = run()
+	if err != nil {
+		slog.Error("gateway failed", "err", err)
+	}
+	// os.Exit не выполняет отложенные вызовы, поэтому файл логов закрывается явно.
+	logs.Close()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// run запускает шлюз и возвращает управление после его остановки.
+func run() error {
 	var srv server
 	cfg := config.New()
 	port := cfg.Gateway.AdrPort
-	newsPort := cfg.News.AdrPort
-	censorPort := cfg.Censor.AdrPort
-	comment := cfg.Comments.AdrPort
 
 	portFlag := flag.String("gateway-port", port, "Gateway port")
-	portFlagNews := flag.String("news-port", newsPort, "News service port")
-	portFlagCensor := flag.String("censor-port", censorPort, "Censor service port")
-	portFlagComment := flag.String("comments-port", comment, "Comments service port")
+	flag.StringVar(&cfg.News.URL, "news-url", cfg.News.URL, "News service base URL")
+	flag.StringVar(&cfg.Censor.URL, "censor-url", cfg.Censor.URL, "Censor service base URL")
+	flag.StringVar(&cfg.Comments.URL, "comments-url", cfg.Comments.URL, "Comments service base URL")
+	routesFlag := flag.String("routes", cfg.Gateway.RoutesFile, "Routes configuration file")
 
 	flag.Parse()
-	srv.api = api.New(cfg, *portFlagNews, *portFlagCensor, *portFlagComment)
-	srv.api.Router().Use(middl.Middle)
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if *routesFlag != "" {
+		routes, err := config.LoadRoutes(*routesFlag)
+		if err != nil {
+			return fmt.Errorf("failed to load routes: %w", err)
+		}
+		cfg.Routes = routes
+	}
+
+	var err error
+	srv.api, err = api.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to init API: %w", err)
+	}
+	tracer := tracing.FromEnv("gateway")
+	srv.api.Router().Use(metrics.Middleware, middl.Middle, tracing.Middleware, middl.TrustedIdentity(cfg.Gateway.TrustIdentityHeaders))
+	if cfg.Gateway.JWT.Enabled() {
+		verifier, err := auth.NewVerifier(cfg.Gateway.JWT)
+		if err != nil {
+			return fmt.Errorf("failed to init JWT authentication: %w", err)
+		}
+		srv.api.Router().Use(auth.Middleware(auth.Bearer{Verifier: verifier}))
+	}
+	keys, err := newAPIKeys(cfg.Gateway.APIKeys)
+	if err != nil {
+		return err
+	}
+	if keys != nil {
+		srv.api.Router().Use(keys.Middleware)
+		srv.api.InitAdminRoutes(keys, cfg.Gateway.APIKeys.RotationOverlap)
+	}
+	if cfg.Gateway.IdentitySecret == "" {
+		slog.Warn("GATEWAY_IDENTITY_SECRET is not set: identity headers are forwarded unsigned")
+	}
+	srv.api.Router().Use(middl.IdentityHeaders([]byte(cfg.Gateway.IdentitySecret)))
+
+	prober := upstream.NewProber(srv.api.Pools(), cfg.Gateway.HealthPath,
+		cfg.Gateway.HealthInterval, cfg.Gateway.HealthTimeout)
+	go prober.Run(context.Background())
 
-	log.Println("Gateway running at http://127.0.0.1" + *portFlag)
-	log.Fatal(http.ListenAndServe(*portFlag, srv.api.Router()))
+	server := &http.Server{
+		Addr:    *portFlag,
+		Handler: srv.api.Router(),
+	}
+
+	done := make(chan os.Signal, 1)
+	signal.Notify(done, os.Interrupt, syscall.SIGTERM)
+	serveErr := make(chan error, 1)
+
+	go func() {
+		slog.Info("gateway running", "addr", "http://127.0.0.1"+*portFlag)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case <-done:
+		slog.Info("gateway shutting down")
+	case err = <-serveErr:
+		err = fmt.Errorf("server stopped: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("failed to shut down server", "err", err)
+	}
+	if err := tracer.Shutdown(ctx); err != nil {
+		slog.Error("failed to export traces", "err", err)
+	}
+	slog.Info("gateway stopped")
+	return err
+}
+
+// newAPIKeys подключает хранилище API-ключей из конфигурации; nil — ключи не настроены.
+func newAPIKeys(cfg config.APIKeys) (*middl.APIKeys, error) {
+	var store middl.KeyStore
+	switch {
+	case cfg.DB != "":
+		db, err := middl.NewPostgresKeyStore(context.Background(), cfg.DB)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to API keys database: %w", err)
+		}
+		store = db
+		metrics.Default.RegisterPgxPool("apikeys", db)
+	case cfg.File != "":
+		store = middl.NewFileKeyStore(cfg.File)
+	default:
+		return nil, nil
+	}
+
+	keys := middl.NewAPIKeys(store, cfg.Required)
+	if err := keys.Reload(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to load API keys: %w", err)
+	}
+	go keys.Run(context.Background(), cfg.ReloadInterval)
+	return keys, nil
 }
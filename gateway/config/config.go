@@ -1,51 +1,353 @@
 package config
 
-import "os"
+import (
+	"APIGateway/gateway/pkg/auth"
+	"APIGateway/gateway/pkg/breaker"
+	"APIGateway/gateway/pkg/ratelimit"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Config struct {
 	Censor   Censor
 	Comments Comments
 	News     News
 	Gateway  Gateway
+	Routes   []Route
+
+	// envErrs накапливает ошибки разбора переменных окружения до вызова Validate.
+	envErrs []error
 }
 
+// Censor — сервис цензуры: шлюз отправляет ему текст комментария до сохранения.
 type Censor struct {
 	AdrPort string
 	URLdb   string
+	// URL — экземпляры цензора из CENSOR_URL; проверка текста идемпотентна,
+	// поэтому любой экземпляр может ответить на повтор запроса.
+	URL string
+	// Balancer — CENSOR_BALANCER: round_robin (по умолчанию), least_in_flight
+	// или p2c (синоним random_two_choices).
+	Balancer string
 }
 
+// Comments — сервис комментариев, к которому идут запросы /comments и часть /news/{id}.
 type Comments struct {
 	AdrPort string
 	URLdb   string
+	// URL — экземпляры сервиса комментариев из COMMENTS_URL.
+	URL string
+	// Balancer — COMMENTS_BALANCER, значения те же, что у CENSOR_BALANCER; least_in_flight
+	// разгружает экземпляр, занятый медленными выборками дерева комментариев.
+	Balancer string
 }
 
+// News — агрегатор новостей, источник /news.
 type News struct {
 	AdrPort string
 	URLdb   string
+	// URL — экземпляры агрегатора из NEWS_URL.
+	URL string
+	// Balancer — NEWS_BALANCER: round_robin (по умолчанию), least_in_flight
+	// или p2c (синоним random_two_choices).
+	Balancer string
 }
 
 type Gateway struct {
-	AdrPort string
+	AdrPort    string
+	RoutesFile string
+	// EjectAfter и EjectFor управляют пассивным исключением экземпляров upstream.
+	EjectAfter int
+	EjectFor   time.Duration
+	// Активные проверки готовности экземпляров upstream.
+	HealthPath     string
+	HealthInterval time.Duration
+	HealthTimeout  time.Duration
+	// Breaker — пороги выключателя, общие для всех upstream.
+	Breaker breaker.Config
+	// Retry — политика повторов для маршрутов, где она не задана явно.
+	Retry RetryPolicy
+	// TrustIdentityHeaders — принимать заголовки X-User-* от аутентифицирующего прокси перед шлюзом.
+	// По умолчанию они удаляются из запросов клиентов.
+	TrustIdentityHeaders bool
+	// JWT — проверка токенов клиентов; без ключей аутентификация по JWT выключена.
+	JWT auth.Options
+	// IdentitySecret — общий с внутренними сервисами секрет для подписи заголовков личности.
+	IdentitySecret string
+	// APIKeys — аутентификация клиентов по API-ключам и их квоты.
+	APIKeys APIKeys
+	// RateLimit — ограничение частоты для маршрутов, где оно не задано явно.
+	RateLimit RateLimit
+	// RateLimitDB — Postgres с общими для экземпляров шлюза вёдрами; пустой — вёдра в памяти.
+	RateLimitDB string
+	// CacheMaxBytes — предельный объём кеша ответов в памяти.
+	CacheMaxBytes int64
+	// Coalesce — объединять одинаковые одновременные GET-запросы к upstream.
+	Coalesce bool
+}
+
+// APIKeys — хранилище API-ключей: файл или Postgres. Без хранилища ключи не проверяются.
+type APIKeys struct {
+	File string
+	DB   string
+	// Required отклоняет запросы без ключа.
+	Required bool
+	// ReloadInterval — как часто перечитывать ключи из хранилища.
+	ReloadInterval time.Duration
+	// RotationOverlap — сколько прежние ключи клиента действуют после ротации.
+	RotationOverlap time.Duration
 }
 
 func New() *Config {
-	return &Config{
+	cfg := &Config{
 		Censor: Censor{
-			AdrPort: getEnv("CENSOR_PORT", ""),
-			URLdb:   getEnv("CENSOR_DB", ""),
+			AdrPort:  getEnv("CENSOR_PORT", ""),
+			URLdb:    getEnv("CENSOR_DB", ""),
+			URL:      upstreamURL("CENSOR"),
+			Balancer: getEnv("CENSOR_BALANCER", "round_robin"),
 		},
 		Comments: Comments{
-			AdrPort: getEnv("COMMENTS_PORT", ""),
-			URLdb:   getEnv("COMMENTS_DB", ""),
+			AdrPort:  getEnv("COMMENTS_PORT", ""),
+			URLdb:    getEnv("COMMENTS_DB", ""),
+			URL:      upstreamURL("COMMENTS"),
+			Balancer: getEnv("COMMENTS_BALANCER", "round_robin"),
 		},
 		News: News{
-			AdrPort: getEnv("NEWS_PORT", ""),
-			URLdb:   getEnv("NEWS_DB", ""),
+			AdrPort:  getEnv("NEWS_PORT", ""),
+			URLdb:    getEnv("NEWS_DB", ""),
+			URL:      upstreamURL("NEWS"),
+			Balancer: getEnv("NEWS_BALANCER", "round_robin"),
 		},
 		Gateway: Gateway{
-			AdrPort: getEnv("GATEWAY_PORT", ""),
+			AdrPort:    getEnv("GATEWAY_PORT", ""),
+			RoutesFile: getEnv("GATEWAY_ROUTES", ""),
+			HealthPath: getEnv("GATEWAY_HEALTH_PATH", "/readyz"),
 		},
 	}
+	cfg.Gateway.EjectAfter = cfg.envInt("GATEWAY_EJECT_AFTER", 3)
+	cfg.Gateway.EjectFor = cfg.envDuration("GATEWAY_EJECT_FOR", 30*time.Second)
+	cfg.Gateway.HealthInterval = cfg.envDuration("GATEWAY_HEALTH_INTERVAL", 10*time.Second)
+	cfg.Gateway.HealthTimeout = cfg.envDuration("GATEWAY_HEALTH_TIMEOUT", 2*time.Second)
+	cfg.Gateway.TrustIdentityHeaders = cfg.envBool("GATEWAY_TRUST_IDENTITY_HEADERS", false)
+	cfg.Gateway.IdentitySecret = getEnv("GATEWAY_IDENTITY_SECRET", "")
+	cfg.Gateway.RateLimit = RateLimit{
+		Requests: cfg.envInt("GATEWAY_RATE_LIMIT_REQUESTS", 0),
+		Per:      Duration(cfg.envDuration("GATEWAY_RATE_LIMIT_PER", time.Minute)),
+		Burst:    cfg.envInt("GATEWAY_RATE_LIMIT_BURST", 0),
+		Key:      getEnv("GATEWAY_RATE_LIMIT_KEY", ratelimit.ByIP),
+	}
+	cfg.Gateway.RateLimitDB = getEnv("GATEWAY_RATE_LIMIT_DB", "")
+	cfg.Gateway.CacheMaxBytes = int64(cfg.envInt("GATEWAY_CACHE_MAX_BYTES", 64<<20))
+	cfg.Gateway.Coalesce = cfg.envBool("GATEWAY_COALESCE", true)
+	cfg.Gateway.APIKeys = APIKeys{
+		File:            getEnv("GATEWAY_API_KEYS_FILE", ""),
+		DB:              getEnv("GATEWAY_API_KEYS_DB", ""),
+		Required:        cfg.envBool("GATEWAY_API_KEYS_REQUIRED", false),
+		ReloadInterval:  cfg.envDuration("GATEWAY_API_KEYS_RELOAD", time.Minute),
+		RotationOverlap: cfg.envDuration("GATEWAY_API_KEYS_OVERLAP", 24*time.Hour),
+	}
+	cfg.Gateway.JWT = auth.Options{
+		HS256Secret: getEnv("GATEWAY_JWT_HS256_SECRET", ""),
+		JWKSFile:    getEnv("GATEWAY_JWT_JWKS", ""),
+		Issuer:      getEnv("GATEWAY_JWT_ISSUER", ""),
+		Audience:    getEnv("GATEWAY_JWT_AUDIENCE", ""),
+		Leeway:      cfg.envDuration("GATEWAY_JWT_LEEWAY", 30*time.Second),
+		NameClaim:   getEnv("GATEWAY_JWT_NAME_CLAIM", "name"),
+		RolesClaim:  getEnv("GATEWAY_JWT_ROLES_CLAIM", "roles"),
+	}
+	cfg.Gateway.Breaker = breaker.Config{
+		Window:         cfg.envDuration("GATEWAY_BREAKER_WINDOW", 30*time.Second),
+		MinRequests:    cfg.envInt("GATEWAY_BREAKER_MIN_REQUESTS", 10),
+		FailureRate:    cfg.envFloat("GATEWAY_BREAKER_FAILURE_RATE", 0.5),
+		SlowCall:       cfg.envDuration("GATEWAY_BREAKER_SLOW_CALL", 5*time.Second),
+		SlowCallRate:   cfg.envFloat("GATEWAY_BREAKER_SLOW_CALL_RATE", 0.8),
+		Cooldown:       cfg.envDuration("GATEWAY_BREAKER_COOLDOWN", 15*time.Second),
+		HalfOpenProbes: cfg.envInt("GATEWAY_BREAKER_HALF_OPEN_PROBES", 1),
+	}
+	cfg.Gateway.Retry = RetryPolicy{
+		MaxAttempts:         cfg.envInt("GATEWAY_RETRY_MAX_ATTEMPTS", 3),
+		BaseDelay:           Duration(cfg.envDuration("GATEWAY_RETRY_BASE_DELAY", 50*time.Millisecond)),
+		MaxDelay:            Duration(cfg.envDuration("GATEWAY_RETRY_MAX_DELAY", time.Second)),
+		RetryOn:             cfg.envInts("GATEWAY_RETRY_ON", []int{502, 503, 504}),
+		Budget:              cfg.envFloat("GATEWAY_RETRY_BUDGET", 0.2),
+		MinRetriesPerSecond: cfg.envFloat("GATEWAY_RETRY_MIN_PER_SECOND", 5),
+	}
+	return cfg
+}
+
+// Validate проверяет адреса upstream-сервисов и возвращает все найденные ошибки разом.
+func (c *Config) Validate() error {
+	errs := append([]error(nil), c.envErrs...)
+	for _, u := range []struct{ name, raw string }{
+		{"news", c.News.URL},
+		{"comments", c.Comments.URL},
+		{"censor", c.Censor.URL},
+	} {
+		if _, err := ParseUpstreamURLs(u.raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s upstream: %w", u.name, err))
+		}
+	}
+	if c.Gateway.EjectAfter < 0 {
+		errs = append(errs, errors.New("GATEWAY_EJECT_AFTER must not be negative"))
+	}
+	if r := c.Gateway.Breaker.FailureRate; r < 0 || r > 1 {
+		errs = append(errs, errors.New("GATEWAY_BREAKER_FAILURE_RATE must be between 0 and 1"))
+	}
+	if r := c.Gateway.Breaker.SlowCallRate; r < 0 || r > 1 {
+		errs = append(errs, errors.New("GATEWAY_BREAKER_SLOW_CALL_RATE must be between 0 and 1"))
+	}
+	if err := c.Gateway.Retry.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Gateway.APIKeys.File != "" && c.Gateway.APIKeys.DB != "" {
+		errs = append(errs, errors.New("GATEWAY_API_KEYS_FILE and GATEWAY_API_KEYS_DB are mutually exclusive"))
+	}
+	if c.Gateway.APIKeys.ReloadInterval <= 0 {
+		errs = append(errs, errors.New("GATEWAY_API_KEYS_RELOAD must be positive"))
+	}
+	if err := c.Gateway.RateLimit.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Gateway.CacheMaxBytes <= 0 {
+		errs = append(errs, errors.New("GATEWAY_CACHE_MAX_BYTES must be positive"))
+	}
+	if c.Gateway.JWT.Leeway < 0 {
+		errs = append(errs, errors.New("GATEWAY_JWT_LEEWAY must not be negative"))
+	}
+	if c.Gateway.HealthInterval <= 0 {
+		errs = append(errs, errors.New("GATEWAY_HEALTH_INTERVAL must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// ParseUpstreamURLs разбирает список базовых адресов экземпляров, разделённых запятыми.
+func ParseUpstreamURLs(raw string) ([]*url.URL, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, errors.New("url is not set")
+	}
+	var urls []*url.URL
+	for _, part := range strings.Split(raw, ",") {
+		u, err := ParseUpstreamURL(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, u)
+	}
+	return urls, nil
+}
+
+// ParseUpstreamURL разбирает базовый адрес upstream и проверяет, что он пригоден для проксирования.
+// Завершающий слэш пути отбрасывается.
+func ParseUpstreamURL(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, errors.New("url is not set")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %q: %w", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid url %q: scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid url %q: host is empty", raw)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("invalid url %q: query and fragment are not allowed", raw)
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("invalid url %q: credentials are not allowed", raw)
+	}
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+	return u, nil
+}
+
+// upstreamURL читает <PREFIX>_URL — базовые адреса экземпляров через запятую
+// (схема, хост, порт и необязательный путь), а при его отсутствии собирает адрес
+// из <PREFIX>_PORT, как это делал шлюз раньше.
+func upstreamURL(prefix string) string {
+	if u := getEnv(prefix+"_URL", ""); u != "" {
+		return u
+	}
+	if port := getEnv(prefix+"_PORT", ""); port != "" {
+		return "http://localhost" + port
+	}
+	return ""
+}
+
+func (c *Config) envInt(key string, defaultVal int) int {
+	raw := getEnv(key, "")
+	if raw == "" {
+		return defaultVal
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		c.envErrs = append(c.envErrs, fmt.Errorf("%s: %q is not an integer", key, raw))
+		return defaultVal
+	}
+	return v
+}
+
+func (c *Config) envInts(key string, defaultVal []int) []int {
+	raw := getEnv(key, "")
+	if raw == "" {
+		return defaultVal
+	}
+	var vals []int
+	for _, part := range strings.Split(raw, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			c.envErrs = append(c.envErrs, fmt.Errorf("%s: %q is not a list of integers", key, raw))
+			return defaultVal
+		}
+		vals = append(vals, v)
+	}
+	return vals
+}
+
+func (c *Config) envBool(key string, defaultVal bool) bool {
+	raw := getEnv(key, "")
+	if raw == "" {
+		return defaultVal
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		c.envErrs = append(c.envErrs, fmt.Errorf("%s: %q is not a boolean", key, raw))
+		return defaultVal
+	}
+	return v
+}
+
+func (c *Config) envFloat(key string, defaultVal float64) float64 {
+	raw := getEnv(key, "")
+	if raw == "" {
+		return defaultVal
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		c.envErrs = append(c.envErrs, fmt.Errorf("%s: %q is not a number", key, raw))
+		return defaultVal
+	}
+	return v
+}
+
+func (c *Config) envDuration(key string, defaultVal time.Duration) time.Duration {
+	raw := getEnv(key, "")
+	if raw == "" {
+		return defaultVal
+	}
+	v, err := time.ParseDuration(raw)
+	if err != nil {
+		c.envErrs = append(c.envErrs, fmt.Errorf("%s: %q is not a duration", key, raw))
+		return defaultVal
+	}
+	return v
 }
 
 func getEnv(key, defaultVal string) string {
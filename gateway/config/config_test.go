@@ -0,0 +1,26 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigValidate(t *testing.T) {
+	cfg := &Config{
+		News:     News{URL: "https://news.internal:8443/api"},
+		Comments: Comments{URL: "comments:8082"},
+		Censor:   Censor{URL: ""},
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"comments upstream", "censor upstream: url is not set"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "news upstream") {
+		t.Errorf("valid news url reported as invalid: %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package config
+
+import (
+	"APIGateway/gateway/pkg/ratelimit"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Duration — time.Duration, читаемый из JSON строкой вида "5s" или "250ms".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"5s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Режимы составных маршрутов.
+const (
+	// ModeStrict — ошибка любой части ответа проваливает весь ответ.
+	ModeStrict = "strict"
+	// ModePartial — необязательные части могут отсутствовать, причина попадает в поле degraded.
+	ModePartial = "partial"
+)
+
+// Route описывает один маршрут шлюза.
+// Маршрут либо проксируется на Upstream (с переписыванием пути UpstreamPath),
+// либо обслуживается зарегистрированным по имени Handler.
+type Route struct {
+	Path         string       `json:"path"`
+	Methods      []string     `json:"methods"`
+	Upstream     string       `json:"upstream,omitempty"`
+	UpstreamPath string       `json:"upstream_path,omitempty"`
+	Handler      string       `json:"handler,omitempty"`
+	Timeout      Duration     `json:"timeout,omitempty"`
+	Retry        *RetryPolicy `json:"retry,omitempty"`
+	// Mode — режим составного маршрута: strict (по умолчанию) или partial.
+	Mode string `json:"mode,omitempty"`
+	// Auth — required пускает на маршрут только аутентифицированных пользователей,
+	// optional пускает и анонимных, а права проверяет upstream по переданной личности.
+	Auth string `json:"auth,omitempty"`
+	// Roles — роли, которые нужны пользователю для маршрута; требуют auth=required.
+	Roles []string `json:"roles,omitempty"`
+	// RateLimit — ограничение частоты запросов; без него действует лимит по умолчанию.
+	RateLimit *RateLimit `json:"rate_limit,omitempty"`
+	// Cache — кеширование ответов на GET-запросы; без него ответы не кешируются.
+	Cache *RouteCache `json:"cache,omitempty"`
+}
+
+// RouteCache — кеширование ответов маршрута. TTL действует, если upstream
+// не задал срок хранения в Cache-Control.
+type RouteCache struct {
+	TTL Duration `json:"ttl"`
+}
+
+// RateLimit — ограничение частоты запросов маршрута: Requests запросов за Per
+// с запасом Burst для всплесков, отдельно для каждого IP, API-ключа или пользователя.
+type RateLimit struct {
+	// Requests — 0 отключает ограничение.
+	Requests int      `json:"requests"`
+	Per      Duration `json:"per"`
+	// Burst — 0 означает Requests.
+	Burst int `json:"burst,omitempty"`
+	// Key — ip (по умолчанию), api_key или user.
+	Key string `json:"key,omitempty"`
+}
+
+// Значения Route.Auth.
+const (
+	// AuthRequired — маршрут закрыт аутентификацией.
+	AuthRequired = "required"
+	// AuthOptional — ответ маршрута зависит от пользователя, но анонимные запросы допустимы.
+	AuthOptional = "optional"
+)
+
+// RetryPolicy — политика повторов вызовов upstream для маршрута.
+type RetryPolicy struct {
+	// MaxAttempts — максимум попыток, включая первую; 1 отключает повторы.
+	MaxAttempts int      `json:"max_attempts"`
+	BaseDelay   Duration `json:"base_delay"`
+	MaxDelay    Duration `json:"max_delay"`
+	// RetryOn — коды ответа upstream, при которых запрос повторяется.
+	RetryOn []int `json:"retry_on"`
+	// Budget — допустимая доля повторов от числа запросов маршрута (0.2 — 20%).
+	Budget float64 `json:"budget"`
+	// MinRetriesPerSecond — повторы, разрешённые сверх доли при малом трафике.
+	MinRetriesPerSecond float64 `json:"min_retries_per_second"`
+}
+
+type routesFile struct {
+	Routes []Route `json:"routes"`
+}
+
+// LoadRoutes загружает таблицу маршрутов из JSON-файла.
+func LoadRoutes(filename string) ([]Route, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("read routes file: %w", err)
+	}
+
+	var rf routesFile
+	if err := json.Unmarshal(data, &rf); err != nil {
+		return nil, fmt.Errorf("parse routes file %s: %w", filename, err)
+	}
+
+	for i, rt := range rf.Routes {
+		if err := rt.validate(); err != nil {
+			return nil, fmt.Errorf("routes file %s: route #%d (%s): %w", filename, i, rt.Path, err)
+		}
+	}
+	return rf.Routes, nil
+}
+
+// DefaultRoutes возвращает маршруты, которые шлюз обслуживал до появления файла маршрутов.
+func DefaultRoutes() []Route {
+	return []Route{
+		{Path: "/news", Methods: []string{http.MethodGet}, Upstream: "news", UpstreamPath: "/news"},
+		{Path: "/news/{id:[0-9]+}", Methods: []string{http.MethodGet}, Handler: "news_detail"},
+		{Path: "/news/{id:[0-9]+}/comments", Methods: []string{http.MethodPost}, Handler: "post_comment"},
+	}
+}
+
+func (rt Route) validate() error {
+	if !strings.HasPrefix(rt.Path, "/") {
+		return fmt.Errorf("path must start with /")
+	}
+	if len(rt.Methods) == 0 {
+		return fmt.Errorf("at least one method is required")
+	}
+	if rt.Handler == "" && rt.Upstream == "" {
+		return fmt.Errorf("either upstream or handler must be set")
+	}
+	if rt.Handler != "" && rt.Upstream != "" {
+		return fmt.Errorf("upstream and handler are mutually exclusive")
+	}
+	if rt.UpstreamPath != "" && !strings.HasPrefix(rt.UpstreamPath, "/") {
+		return fmt.Errorf("upstream_path must start with /")
+	}
+	if rt.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	switch rt.Mode {
+	case "", ModeStrict, ModePartial:
+	default:
+		return fmt.Errorf("unknown mode %q", rt.Mode)
+	}
+	switch rt.Auth {
+	case "", AuthRequired, AuthOptional:
+	default:
+		return fmt.Errorf("unknown auth %q", rt.Auth)
+	}
+	if len(rt.Roles) > 0 && rt.Auth != AuthRequired {
+		return fmt.Errorf("roles require auth %q", AuthRequired)
+	}
+	if rt.RateLimit != nil {
+		if err := rt.RateLimit.Validate(); err != nil {
+			return err
+		}
+	}
+	if rt.Cache != nil {
+		if rt.Cache.TTL <= 0 {
+			return fmt.Errorf("cache: ttl must be positive")
+		}
+		// Ответы таких маршрутов зависят от пользователя, а кеш у всех общий.
+		if rt.Auth != "" {
+			return fmt.Errorf("cache is not allowed with auth %q", rt.Auth)
+		}
+	}
+	if rt.Retry != nil {
+		return rt.Retry.Validate()
+	}
+	return nil
+}
+
+// Validate проверяет параметры политики повторов.
+func (p RetryPolicy) Validate() error {
+	if p.MaxAttempts < 0 {
+		return fmt.Errorf("retry: max_attempts must not be negative")
+	}
+	if p.BaseDelay < 0 || p.MaxDelay < 0 {
+		return fmt.Errorf("retry: delays must not be negative")
+	}
+	if p.Budget < 0 || p.MinRetriesPerSecond < 0 {
+		return fmt.Errorf("retry: budget must not be negative")
+	}
+	return nil
+}
+
+// Validate проверяет параметры ограничения частоты.
+func (l RateLimit) Validate() error {
+	if l.Requests < 0 || l.Burst < 0 {
+		return fmt.Errorf("rate_limit: requests and burst must not be negative")
+	}
+	if l.Requests > 0 && l.Per <= 0 {
+		return fmt.Errorf("rate_limit: per must be positive")
+	}
+	if l.Key != "" && !ratelimit.ValidKey(l.Key) {
+		return fmt.Errorf("rate_limit: unknown key %q", l.Key)
+	}
+	return nil
+}
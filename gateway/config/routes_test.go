@@ -0,0 +1,30 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRoutesFileAdminRoutes(t *testing.T) {
+	routes, err := LoadRoutes("../routes.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]bool{"/moderation": false, "/stop": false}
+	for _, rt := range routes {
+		prefix, _, _ := strings.Cut(rt.Path[1:], "/")
+		if _, ok := found["/"+prefix]; !ok {
+			continue
+		}
+		found["/"+prefix] = true
+		if rt.Auth != AuthRequired || !slices.Contains(rt.Roles, "moderator") {
+			t.Errorf("%s %v: auth %q, roles %v; want required moderator", rt.Path, rt.Methods, rt.Auth, rt.Roles)
+		}
+	}
+	for prefix, ok := range found {
+		if !ok {
+			t.Errorf("routes.json has no %s routes", prefix)
+		}
+	}
+}
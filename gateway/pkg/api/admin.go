@@ -0,0 +1,97 @@
+package api
+
+import (
+	"APIGateway/gateway/pkg/auth"
+	"APIGateway/gateway/pkg/middl"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// InitAdminRoutes регистрирует управление API-ключами. Эндпоинты доступны только роли admin;
+// overlap — сколько прежние ключи клиента действуют после ротации.
+func (a *API) InitAdminRoutes(keys *middl.APIKeys, overlap time.Duration) {
+	admin := []string{middl.RoleAdmin}
+	a.router.HandleFunc("/admin/usage", auth.Require(admin, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(keys.Usage())
+	})).Methods(http.MethodGet)
+	a.router.HandleFunc("/admin/keys", auth.Require(admin, handleCreateKey(keys))).Methods(http.MethodPost)
+	a.router.HandleFunc("/admin/keys/{client}/rotate", auth.Require(admin, handleRotateKey(keys, overlap))).Methods(http.MethodPost)
+}
+
+// initCacheRoutes регистрирует очистку кеша ответов: DELETE /admin/cache?prefix=/news
+// удаляет записи, путь которых начинается с prefix, без prefix — весь кеш.
+func (a *API) initCacheRoutes() {
+	a.router.HandleFunc("/admin/cache", auth.Require([]string{middl.RoleAdmin}, func(w http.ResponseWriter, r *http.Request) {
+		prefix := r.URL.Query().Get("prefix")
+		n := a.cache.Purge(prefix)
+		slog.InfoContext(r.Context(), "cache purged", "prefix", prefix, "entries", n)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"purged": n})
+	})).Methods(http.MethodDelete)
+}
+
+// issuedKey — ответ с новым ключом; сам ключ больше нигде не показывается.
+type issuedKey struct {
+	Key string `json:"key"`
+	middl.APIKey
+}
+
+func handleCreateKey(keys *middl.APIKeys) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var spec middl.APIKey
+		if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(spec.Client) == "" || spec.PerMinute < 0 || spec.PerDay < 0 {
+			http.Error(w, "client is required and quotas must not be negative", http.StatusBadRequest)
+			return
+		}
+		plain, key, err := keys.Create(r.Context(), spec)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to create API key", "client", spec.Client, "err", err)
+			http.Error(w, "failed to create key", http.StatusInternalServerError)
+			return
+		}
+		slog.InfoContext(r.Context(), "API key issued", "key_id", key.ID, "client", key.Client)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(issuedKey{Key: plain, APIKey: key})
+	}
+}
+
+// handleRotateKey выдаёт клиенту новый ключ; длительность перекрытия можно
+// переопределить параметром overlap, например ?overlap=1h.
+func handleRotateKey(keys *middl.APIKeys, overlap time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client := mux.Vars(r)["client"]
+		d := overlap
+		if o := r.URL.Query().Get("overlap"); o != "" {
+			var err error
+			if d, err = time.ParseDuration(o); err != nil || d < 0 {
+				http.Error(w, "invalid overlap parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		plain, key, err := keys.Rotate(r.Context(), client, d)
+		if errors.Is(err, middl.ErrUnknownClient) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to rotate API key", "client", client, "err", err)
+			http.Error(w, "failed to rotate key", http.StatusInternalServerError)
+			return
+		}
+		slog.InfoContext(r.Context(), "API key rotated", "client", client, "key_id", key.ID, "overlap", d)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(issuedKey{Key: plain, APIKey: key})
+	}
+}
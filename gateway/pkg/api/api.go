@@ -2,11 +2,27 @@ package api
 
 import (
 	"APIGateway/gateway/config"
+	"APIGateway/gateway/pkg/auth"
+	"APIGateway/gateway/pkg/breaker"
+	"APIGateway/gateway/pkg/cache"
+	"APIGateway/gateway/pkg/coalesce"
+	"APIGateway/gateway/pkg/ratelimit"
+	"APIGateway/gateway/pkg/retry"
+	"APIGateway/gateway/pkg/upstream"
+	"APIGateway/pkg/metrics"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,64 +30,200 @@ import (
 )
 
 type API struct {
-	router      *mux.Router
-	cfg         *config.Config
-	newsURL     string
-	censorURL   string
-	commentsURL string
-	client      *http.Client
+	router    *mux.Router
+	cfg       *config.Config
+	news      *upstream.Pool
+	censor    *upstream.Pool
+	comments  *upstream.Pool
+	client    *http.Client
+	upstreams map[string]*upstream.Pool
+	handlers  map[string]http.HandlerFunc
+	limits    ratelimit.Store
+	cache     *cache.Cache
+	// coalesce — группы объединения запросов по имени upstream; пусто, если объединение выключено.
+	coalesce map[string]*coalesce.Group
 }
 
-func New(cfg *config.Config, newsURL, censorURL, commentsURL string) *API {
+func New(cfg *config.Config) (*API, error) {
 	api := &API{
-		router:      mux.NewRouter(),
-		cfg:         cfg,
-		newsURL:     "http://localhost" + newsURL,
-		censorURL:   "http://localhost" + censorURL,
-		commentsURL: "http://localhost" + commentsURL,
-		client:      &http.Client{Timeout: 10 * time.Second},
+		router: mux.NewRouter(),
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		cache:  cache.New(cfg.Gateway.CacheMaxBytes),
 	}
-	api.initRoutes()
-	return api
+	opts := upstream.Options{
+		EjectAfter: cfg.Gateway.EjectAfter,
+		EjectFor:   cfg.Gateway.EjectFor,
+		Breaker:    cfg.Gateway.Breaker,
+	}
+	for _, u := range []struct {
+		name     string
+		raw      string
+		balancer string
+		dst      **upstream.Pool
+	}{
+		{"news", cfg.News.URL, cfg.News.Balancer, &api.news},
+		{"censor", cfg.Censor.URL, cfg.Censor.Balancer, &api.censor},
+		{"comments", cfg.Comments.URL, cfg.Comments.Balancer, &api.comments},
+	} {
+		var urls []*url.URL
+		if u.raw != "" {
+			var err error
+			if urls, err = config.ParseUpstreamURLs(u.raw); err != nil {
+				return nil, fmt.Errorf("%s upstream: %w", u.name, err)
+			}
+		}
+		balancer, err := upstream.NewBalancer(u.balancer)
+		if err != nil {
+			return nil, fmt.Errorf("%s upstream: %w", u.name, err)
+		}
+		*u.dst = upstream.NewPool(u.name, urls, balancer, api.client, opts)
+	}
+	api.upstreams = map[string]*upstream.Pool{
+		"news":     api.news,
+		"censor":   api.censor,
+		"comments": api.comments,
+	}
+	api.coalesce = map[string]*coalesce.Group{}
+	if cfg.Gateway.Coalesce {
+		for name := range api.upstreams {
+			api.coalesce[name] = coalesce.New(name, nil)
+		}
+	}
+	// Составные обработчики, на которые можно сослаться из таблицы маршрутов по имени.
+	api.handlers = map[string]http.HandlerFunc{
+		"news_detail":  api.handleGetNewsByID,
+		"post_comment": api.handlePostComment,
+		"edit_comment": api.handleEditComment,
+	}
+
+	api.limits = ratelimit.NewMemory()
+	if cfg.Gateway.RateLimitDB != "" {
+		store, err := ratelimit.NewPostgres(context.Background(), cfg.Gateway.RateLimitDB)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit store: %w", err)
+		}
+		api.limits = store
+		metrics.Default.RegisterPgxPool("ratelimit", store)
+	}
+
+	api.initHealthRoutes()
+	api.initCacheRoutes()
+	routes := cfg.Routes
+	if len(routes) == 0 {
+		routes = config.DefaultRoutes()
+	}
+	if err := api.initRoutes(routes); err != nil {
+		return nil, err
+	}
+	return api, nil
 }
 
 func (a *API) Router() *mux.Router {
 	return a.router
 }
 
-func (a *API) initRoutes() {
-	a.router.HandleFunc("/news", a.handleGetNews).Methods("GET")
-	a.router.HandleFunc("/news/{id:[0-9]+}", a.handleGetNewsByID).Methods("GET")
-	a.router.HandleFunc("/news/{id:[0-9]+}/comments", a.handlePostComment).Methods("POST")
+// initRoutes регистрирует маршруты из таблицы: проксирующие на upstream
+// либо составные обработчики, зарегистрированные по имени.
+func (a *API) initRoutes(routes []config.Route) error {
+	for _, rt := range routes {
+		var h http.HandlerFunc
+		switch {
+		case rt.Handler != "":
+			named, ok := a.handlers[rt.Handler]
+			if !ok {
+				return fmt.Errorf("route %s: unknown handler %q", rt.Path, rt.Handler)
+			}
+			h = named
+		case rt.Upstream != "":
+			pool, ok := a.upstreams[rt.Upstream]
+			if !ok {
+				return fmt.Errorf("route %s: unknown upstream %q", rt.Path, rt.Upstream)
+			}
+			h = a.proxy(pool, rt)
+		default:
+			return fmt.Errorf("route %s: neither upstream nor handler set", rt.Path)
+		}
+
+		if rt.Timeout > 0 {
+			h = withTimeout(time.Duration(rt.Timeout), h)
+		}
+		if rt.Cache != nil {
+			h = a.cache.Handler(time.Duration(rt.Cache.TTL), h)
+		}
+		if rt.Auth == config.AuthRequired {
+			h = auth.Require(rt.Roles, h)
+		}
+		rl := a.cfg.Gateway.RateLimit
+		if rt.RateLimit != nil {
+			rl = *rt.RateLimit
+		}
+		if l := (ratelimit.Limit{Requests: rl.Requests, Per: time.Duration(rl.Per), Burst: rl.Burst}); l.Enabled() {
+			by := rl.Key
+			if by == "" {
+				by = ratelimit.ByIP
+			}
+			h = ratelimit.Handler(a.limits, strings.Join(rt.Methods, ",")+" "+rt.Path, l, by, h)
+		}
+		h = newRoute(rt, a.cfg.Gateway.Retry).handler(h)
+		a.router.HandleFunc(rt.Path, h).Methods(rt.Methods...)
+	}
+	return nil
 }
 
-func (a *API) handleGetNews(w http.ResponseWriter, r *http.Request) {
-	req, err := http.NewRequest("GET", a.newsURL+"/news", nil)
-	if err != nil {
-		http.Error(w, "failed to create request", http.StatusInternalServerError)
-		return
+// withTimeout ограничивает время обработки запроса, включая все вызовы upstream.
+func withTimeout(d time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		next(w, r.WithContext(ctx))
 	}
-	req.URL.RawQuery = r.URL.RawQuery
-	copyHeader(r.Header, req.Header)
+}
 
-	resp, err := a.client.Do(req)
-	if err != nil {
-		http.Error(w, "failed to fetch news", http.StatusBadGateway)
-		return
+// degradation описывает часть составного ответа, которую не удалось получить.
+type degradation struct {
+	Part string `json:"part"`
+	// Reason — одно из значений degradationReason; подробности ошибки остаются в логе.
+	Reason string `json:"reason"`
+}
+
+// degradationReason сводит ошибку upstream к причине, которую можно показать клиенту:
+// circuit_open, timeout, upstream_error (неуспешный код ответа) или unavailable.
+func degradationReason(err error) string {
+	var open *breaker.OpenError
+	var se *statusError
+	var ne net.Error
+	switch {
+	case errors.As(err, &open):
+		return "circuit_open"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &ne) && ne.Timeout():
+		return "timeout"
+	case errors.As(err, &se):
+		return "upstream_error"
 	}
-	defer resp.Body.Close()
+	return "unavailable"
+}
 
-	copyHeader(resp.Header, w.Header())
-	w.WriteHeader(resp.StatusCode)
-	io.Copy(w, resp.Body)
+// statusError — неуспешный код ответа upstream.
+type statusError struct {
+	service string
+	code    int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s service returned %d", e.service, e.code)
 }
 
+// handleGetNewsByID собирает новость и комментарии к ней. Без новости ответ не имеет смысла,
+// а недоступность комментариев в режиме partial не мешает отдать новость:
+// comments будет null, а причина попадёт в degraded.
 func (a *API) handleGetNewsByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	newsID := vars["id"]
 
 	var newsData map[string]interface{}
 	var commentsData []map[string]interface{}
+	var commentsHeader http.Header
 	var wg sync.WaitGroup
 	var newsErr, commentsErr error
 
@@ -79,143 +231,338 @@ func (a *API) handleGetNewsByID(w http.ResponseWriter, r *http.Request) {
 
 	go func() {
 		defer wg.Done()
-		url := a.newsURL + "/news/" + newsID
-		newsResp, err := a.client.Get(url)
-		if err != nil {
-			newsErr = err
-			return
-		}
-		defer newsResp.Body.Close()
-		if newsResp.StatusCode != http.StatusOK {
-			newsErr = err
-			return
-		}
-		if err := json.NewDecoder(newsResp.Body).Decode(&newsData); err != nil {
-			newsErr = err
-		}
+		_, newsErr = a.getJSON(r.Context(), a.news, "/news/"+newsID, &newsData)
 	}()
 
 	go func() {
 		defer wg.Done()
-		url := a.commentsURL + "/comments?news_id=" + newsID
-		commentsResp, err := a.client.Get(url)
-		if err != nil {
-			commentsErr = err
-			return
-		}
-		defer commentsResp.Body.Close()
-		if commentsResp.StatusCode != http.StatusOK {
-			commentsErr = err
-			return
-		}
-		if err := json.NewDecoder(commentsResp.Body).Decode(&commentsData); err != nil {
-			commentsErr = err
-		}
+		commentsHeader, commentsErr = a.getJSON(r.Context(), a.comments, "/comments?"+commentsQuery(newsID, r.URL.Query()).Encode(), &commentsData)
 	}()
 
 	wg.Wait()
 
 	if newsErr != nil {
-		http.Error(w, "failed to get news", http.StatusBadGateway)
+		slog.ErrorContext(r.Context(), "failed to get news", "news_id", newsID, "err", newsErr)
+		var se *statusError
+		if errors.As(newsErr, &se) && se.code == http.StatusNotFound {
+			http.Error(w, "news not found", http.StatusNotFound)
+			return
+		}
+		upstreamError(w, newsErr, "failed to get news")
 		return
 	}
+
+	var degraded []degradation
 	if commentsErr != nil {
-		http.Error(w, "failed to get comments", http.StatusBadGateway)
-		return
+		slog.ErrorContext(r.Context(), "failed to get comments", "news_id", newsID, "err", commentsErr)
+		if !a.partial(r) {
+			upstreamError(w, commentsErr, "failed to get comments")
+			return
+		}
+		commentsData = nil
+		degraded = append(degraded, degradation{Part: "comments", Reason: degradationReason(commentsErr)})
 	}
 
 	result := map[string]interface{}{
 		"news":     newsData,
 		"comments": commentsData,
 	}
+	if page := commentsPage(commentsHeader); page != nil {
+		result["comments_page"] = page
+	}
+	if len(degraded) > 0 {
+		result["degraded"] = degraded
+		// Неполный ответ не должен задерживаться в кеше.
+		w.Header().Set("Cache-Control", "no-store")
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
 }
 
+// commentsQueryParams — параметры запроса к шлюзу, которые передаются сервису комментариев:
+// представление (flat или tree), глубина дерева, порядок сортировки и страница.
+var commentsQueryParams = []string{"view", "depth", "sort", "limit", "cursor", "offset"}
+
+func commentsQuery(newsID string, in url.Values) url.Values {
+	q := url.Values{"news_id": {newsID}}
+	for _, name := range commentsQueryParams {
+		if v := in.Get(name); v != "" {
+			q.Set(name, v)
+		}
+	}
+	return q
+}
+
+// partial сообщает, разрешены ли маршрутом частичные ответы.
+func (a *API) partial(r *http.Request) bool {
+	rt := routeFrom(r.Context())
+	return rt != nil && rt.cfg.Mode == config.ModePartial
+}
+
+// getJSON выполняет GET к upstream, декодирует ответ 200 в dst и возвращает заголовки ответа.
+func (a *API) getJSON(ctx context.Context, pool *upstream.Pool, path string, dst interface{}) (http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.do(pool, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{service: pool.Name(), code: resp.StatusCode}
+	}
+	return resp.Header, json.NewDecoder(resp.Body).Decode(dst)
+}
+
+// commentsPage собирает сведения о странице комментариев из заголовков ответа сервиса комментариев.
+func commentsPage(h http.Header) map[string]interface{} {
+	total, err := strconv.Atoi(h.Get("X-Total-Count"))
+	if err != nil {
+		return nil
+	}
+	page := map[string]interface{}{"total": total}
+	if next := h.Get("X-Next-Cursor"); next != "" {
+		page["next_cursor"] = next
+	}
+	if next, err := strconv.Atoi(h.Get("X-Next-Offset")); err == nil {
+		page["next_offset"] = next
+	}
+	return page
+}
+
+// Действия цензора, см. censors/pkg/engine.
+const (
+	censorMask   = "mask"
+	censorFlag   = "flag"
+	censorReject = "reject"
+)
+
+// Статусы модерации, с которыми шлюз сохраняет комментарии.
+const (
+	statusApproved = "approved"
+	statusPending  = "pending"
+)
+
+// verdict — решение цензора по тексту комментария.
+type verdict struct {
+	Action     string   `json:"action"`
+	Score      int      `json:"score"`
+	Categories []string `json:"categories"`
+	Text       string   `json:"text"`
+	Reason     string   `json:"reason"`
+}
+
+// commentBody — комментарий от клиента. Текст принимается в поле text или content.
+type commentBody struct {
+	Text     string `json:"text"`
+	Content  string `json:"content"`
+	ParentID *int   `json:"parent_id"`
+}
+
+// handlePostComment проверяет комментарий цензором и сохраняет его согласно вердикту:
+// reject — 422 с причиной, flag — комментарий сохраняется со статусом pending (ответ 202),
+// mask и allow — публикуется текст с замаскированными стоп-словами.
 func (a *API) handlePostComment(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	newsID := vars["id"]
-	log.Println("POST comment to news ID:", newsID)
+	newsID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	slog.DebugContext(r.Context(), "posting comment", "news_id", newsID)
+
+	comment, text, ok := readComment(w, r)
+	if !ok {
+		return
+	}
+	content, status, ok := a.censorComment(w, r, text)
+	if !ok {
+		return
+	}
+
+	jsonBody, _ := json.Marshal(map[string]interface{}{
+		"news_id":   newsID,
+		"parent_id": comment.ParentID,
+		"content":   content,
+		"status":    status,
+	})
+	if code := a.forwardComment(w, r, http.MethodPost, "/comments", jsonBody, status); code < 300 {
+		// Закешированная новость больше не содержит всех комментариев.
+		a.cache.Purge(fmt.Sprintf("/news/%d?", newsID))
+	}
+}
+
+// handleEditComment проверяет новый текст комментария цензором так же, как при создании,
+// и передаёт правку сервису комментариев, который сохраняет прежний текст в истории.
+func (a *API) handleEditComment(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	slog.DebugContext(r.Context(), "editing comment", "comment_id", id)
 
+	_, text, ok := readComment(w, r)
+	if !ok {
+		return
+	}
+	content, status, ok := a.censorComment(w, r, text)
+	if !ok {
+		return
+	}
+
+	jsonBody, _ := json.Marshal(map[string]interface{}{
+		"content": content,
+		"status":  status,
+	})
+	a.forwardComment(w, r, http.MethodPatch, "/comments/"+id, jsonBody, status)
+}
+
+// readComment читает комментарий из тела запроса; при ошибке отвечает 400 и возвращает ok=false.
+func readComment(w http.ResponseWriter, r *http.Request) (commentBody, string, bool) {
+	var comment commentBody
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Println("Error reading body:", err)
+		slog.WarnContext(r.Context(), "failed to read body", "err", err)
 		http.Error(w, "invalid body", http.StatusBadRequest)
-		return
+		return comment, "", false
 	}
 	defer r.Body.Close()
 
-	var comment map[string]string
 	if err := json.Unmarshal(body, &comment); err != nil {
-		log.Println("Error unmarshalling JSON:", err)
+		slog.WarnContext(r.Context(), "failed to decode comment", "err", err)
 		http.Error(w, "invalid json", http.StatusBadRequest)
-		return
+		return comment, "", false
 	}
 
-	text := comment["text"]
-	log.Println("Original comment text:", text)
+	text := comment.Text
+	if text == "" {
+		text = comment.Content
+	}
+	if strings.TrimSpace(text) == "" {
+		http.Error(w, "comment text is required", http.StatusBadRequest)
+		return comment, "", false
+	}
+	return comment, text, true
+}
 
-	filteredText, err := a.filterText(text)
+// censorComment получает вердикт цензора и возвращает текст и статус, с которыми комментарий сохраняется.
+// Если комментарий сохранять нельзя, отвечает клиенту сам и возвращает ok=false.
+func (a *API) censorComment(w http.ResponseWriter, r *http.Request, text string) (content, status string, ok bool) {
+	v, err := a.checkText(r.Context(), text, r.Header.Get(retry.IdempotencyKeyHeader))
 	if err != nil {
-		log.Println("Error filtering text:", err)
-		http.Error(w, "censorship failed", http.StatusBadGateway)
-		return
+		slog.ErrorContext(r.Context(), "failed to check comment text", "err", err)
+		upstreamError(w, err, "censorship failed")
+		return "", "", false
+	}
+	slog.InfoContext(r.Context(), "censor verdict", "action", v.Action, "score", v.Score, "categories", v.Categories)
+
+	switch v.Action {
+	case censorReject:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":      "comment rejected",
+			"reason":     v.Reason,
+			"categories": v.Categories,
+		})
+		return "", "", false
+	case censorFlag:
+		return v.Text, statusPending, true
 	}
-	comment["text"] = filteredText
-	log.Println("Filtered comment text:", filteredText)
+	return v.Text, statusApproved, true
+}
 
-	jsonBody, _ := json.Marshal(comment)
-	req, err := http.NewRequest("POST", a.commentsURL+"/comments?news_id="+newsID, bytes.NewBuffer(jsonBody))
+// forwardComment отправляет комментарий сервису комментариев, копирует ответ клиенту
+// и возвращает его код. Успешно сохранённый комментарий, ожидающий модерации, отдаётся с кодом 202.
+func (a *API) forwardComment(w http.ResponseWriter, r *http.Request, method, path string, body []byte, status string) int {
+	req, err := http.NewRequestWithContext(r.Context(), method, path, bytes.NewBuffer(body))
 	if err != nil {
-		log.Println("Error creating comment request:", err)
+		slog.ErrorContext(r.Context(), "failed to create comment request", "err", err)
 		http.Error(w, "failed to create request", http.StatusInternalServerError)
-		return
+		return http.StatusInternalServerError
 	}
-	req.Header.Set("Content-Type", "application/json")
 	copyHeader(r.Header, req.Header)
+	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := a.client.Do(req)
+	resp, err := a.do(a.comments, req)
 	if err != nil {
-		log.Println("Error sending comment:", err)
-		http.Error(w, "failed to send comment", http.StatusBadGateway)
-		return
+		slog.ErrorContext(r.Context(), "failed to send comment", "err", err)
+		upstreamError(w, err, "failed to send comment")
+		return http.StatusBadGateway
 	}
 	defer resp.Body.Close()
 
-	log.Println("Comment service response status:", resp.StatusCode)
-	w.WriteHeader(resp.StatusCode)
+	slog.DebugContext(r.Context(), "comment service responded", "status", resp.StatusCode)
+	a.purgeNews(resp)
+	code := resp.StatusCode
+	if code < 300 && status == statusPending {
+		code = http.StatusAccepted
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	w.WriteHeader(code)
 	io.Copy(w, resp.Body)
+	return code
 }
 
-func (a *API) filterText(text string) (string, error) {
-	log.Println("Filtering text:", text)
+// newsIDHeader — заголовок, которым сервис комментариев сообщает ID новости изменённого комментария.
+const newsIDHeader = "X-News-ID"
+
+// purgeNews сбрасывает закешированную страницу новости, если upstream успешно изменил
+// комментарий к ней: правка, удаление и модерация меняют список комментариев новости.
+func (a *API) purgeNews(resp *http.Response) {
+	if resp.StatusCode >= 300 {
+		return
+	}
+	if id, err := strconv.Atoi(resp.Header.Get(newsIDHeader)); err == nil {
+		a.cache.Purge(fmt.Sprintf("/news/%d?", id))
+	}
+}
 
+// checkText получает вердикт цензора по тексту. idemKey — Idempotency-Key клиента:
+// проверка текста идемпотентна, и с ключом её можно повторять так же, как сохранение комментария.
+func (a *API) checkText(ctx context.Context, text, idemKey string) (verdict, error) {
+	var v verdict
 	requestBody := map[string]string{"text": text}
 	jsonBody, _ := json.Marshal(requestBody)
 
-	req, err := http.NewRequest("POST", a.censorURL+"/censor", bytes.NewBuffer(jsonBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", "/censor", bytes.NewBuffer(jsonBody))
 	if err != nil {
-		log.Println("Error creating censor request:", err)
-		return "", err
+		slog.ErrorContext(ctx, "failed to create censor request", "err", err)
+		return v, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if idemKey != "" {
+		req.Header.Set(retry.IdempotencyKeyHeader, idemKey)
+	}
 
-	resp, err := a.client.Do(req)
+	resp, err := a.do(a.censor, req)
 	if err != nil {
-		log.Println("Error sending censor request:", err)
-		return "", err
+		slog.ErrorContext(ctx, "failed to send censor request", "err", err)
+		return v, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return v, &statusError{service: a.censor.Name(), code: resp.StatusCode}
+	}
 
-	var respData map[string]string
-	if err := json.NewDecoder(resp.Body).Decode(&respData); err != nil {
-		log.Println("Error decoding censor response:", err)
-		return "", err
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		slog.ErrorContext(ctx, "failed to decode censor response", "err", err)
+		return v, err
 	}
+	// Цензор без вердикта возвращает только замаскированный текст.
+	if v.Action == "" {
+		v.Action = censorMask
+	}
+	return v, nil
+}
 
-	log.Println("Filtered text from censor:", respData["text"])
-	return respData["text"], nil
+// upstreamError отвечает 503 с Retry-After, если вызов отклонён выключателем,
+// и 502 при прочих ошибках upstream.
+func upstreamError(w http.ResponseWriter, err error, msg string) {
+	var open *breaker.OpenError
+	if errors.As(err, &open) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(open.RetryAfter.Seconds()))))
+		http.Error(w, msg+": "+open.Name+" temporarily unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, msg, http.StatusBadGateway)
 }
 
 func copyHeader(src http.Header, dst http.Header) {
@@ -2,12 +2,25 @@ package api
 
 import (
 	"APIGateway/gateway/config"
+	"APIGateway/gateway/pkg/breaker"
+	"APIGateway/gateway/pkg/middl"
+	"APIGateway/gateway/pkg/upstream"
+	"APIGateway/pkg/tracing"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // mockServer создает простой mock-сервер с указанным ответом и кодом
@@ -28,8 +41,11 @@ func TestHandleGetNews(t *testing.T) {
 	newsSrv := mockServer(http.StatusOK, newsResp)
 	defer newsSrv.Close()
 
-	cfg := &config.Config{}
-	a := New(cfg, newsSrv.URL[len("http://localhost"):], "", "")
+	cfg := &config.Config{News: config.News{URL: newsSrv.URL}}
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
 	req := httptest.NewRequest("GET", "/news", nil)
 	w := httptest.NewRecorder()
 
@@ -58,8 +74,14 @@ func TestHandleGetNewsByID(t *testing.T) {
 	defer newsSrv.Close()
 	defer commentsSrv.Close()
 
-	cfg := &config.Config{}
-	a := New(cfg, newsSrv.URL[len("http://localhost"):], "", commentsSrv.URL[len("http://localhost"):])
+	cfg := &config.Config{
+		News:     config.News{URL: newsSrv.URL},
+		Comments: config.Comments{URL: commentsSrv.URL},
+	}
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	req := httptest.NewRequest("GET", "/news/1", nil)
 	w := httptest.NewRecorder()
@@ -83,8 +105,14 @@ func TestHandlePostComment(t *testing.T) {
 	defer censorSrv.Close()
 	defer commentsSrv.Close()
 
-	cfg := &config.Config{}
-	a := New(cfg, "", censorSrv.URL[len("http://localhost"):], commentsSrv.URL[len("http://localhost"):])
+	cfg := &config.Config{
+		Censor:   config.Censor{URL: censorSrv.URL},
+		Comments: config.Comments{URL: commentsSrv.URL},
+	}
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	comment := map[string]string{"text": "badword"}
 	body, _ := json.Marshal(comment)
@@ -104,3 +132,874 @@ func TestHandlePostComment(t *testing.T) {
 		t.Errorf("unexpected body: %s", string(respBody))
 	}
 }
+
+func TestPostCommentVerdict(t *testing.T) {
+	tests := []struct {
+		name        string
+		verdict     map[string]interface{}
+		wantStatus  int
+		wantStored  bool
+		wantState   string
+		wantContent string
+	}{
+		{"allow", map[string]interface{}{"action": "allow", "text": "hello"}, http.StatusCreated, true, "approved", "hello"},
+		{"mask", map[string]interface{}{"action": "mask", "text": "hello ***"}, http.StatusCreated, true, "approved", "hello ***"},
+		{"flag", map[string]interface{}{"action": "flag", "text": "hello ***"}, http.StatusAccepted, true, "pending", "hello ***"},
+		{"reject", map[string]interface{}{"action": "reject", "text": "***", "reason": "threat", "categories": []string{"threat"}}, http.StatusUnprocessableEntity, false, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			censorSrv := mockServer(http.StatusOK, tt.verdict)
+			defer censorSrv.Close()
+
+			var stored map[string]interface{}
+			commentsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&stored)
+				w.WriteHeader(http.StatusCreated)
+			}))
+			defer commentsSrv.Close()
+
+			a, err := New(&config.Config{
+				Censor:   config.Censor{URL: censorSrv.URL},
+				Comments: config.Comments{URL: commentsSrv.URL},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/news/7/comments", bytes.NewBufferString(`{"text":"hello qwerty"}`))
+			w := httptest.NewRecorder()
+			a.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, w.Code, w.Body)
+			}
+			if !tt.wantStored {
+				if stored != nil {
+					t.Errorf("rejected comment was stored: %v", stored)
+				}
+				if !bytes.Contains(w.Body.Bytes(), []byte("threat")) {
+					t.Errorf("rejection reason missing: %s", w.Body)
+				}
+				return
+			}
+			if stored["status"] != tt.wantState || stored["content"] != tt.wantContent || stored["news_id"] != float64(7) {
+				t.Errorf("unexpected stored comment: %v", stored)
+			}
+		})
+	}
+}
+
+func TestEditComment(t *testing.T) {
+	verdict := map[string]interface{}{"action": "flag", "text": "fixed ***"}
+	censorSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(verdict)
+	}))
+	defer censorSrv.Close()
+
+	var gotMethod, gotPath string
+	var stored map[string]interface{}
+	commentsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath = r.Method, r.URL.Path
+		json.NewDecoder(r.Body).Decode(&stored)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer commentsSrv.Close()
+
+	cfg := &config.Config{
+		Censor:   config.Censor{URL: censorSrv.URL},
+		Comments: config.Comments{URL: commentsSrv.URL},
+		Routes: []config.Route{
+			{Path: "/comments/{id:[0-9]+}", Methods: []string{http.MethodPatch}, Handler: "edit_comment"},
+		},
+	}
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edit := func() int {
+		req := httptest.NewRequest(http.MethodPatch, "/comments/5", bytes.NewBufferString(`{"text":"fixed qwerty"}`))
+		w := httptest.NewRecorder()
+		a.Router().ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := edit(); code != http.StatusAccepted {
+		t.Fatalf("flagged edit: expected 202, got %d", code)
+	}
+	if gotMethod != http.MethodPatch || gotPath != "/comments/5" || stored["content"] != "fixed ***" || stored["status"] != "pending" {
+		t.Errorf("unexpected upstream edit: %s %s %v", gotMethod, gotPath, stored)
+	}
+
+	verdict = map[string]interface{}{"action": "reject", "text": "***"}
+	gotMethod = ""
+	if code := edit(); code != http.StatusUnprocessableEntity || gotMethod != "" {
+		t.Errorf("rejected edit: expected 422 without upstream call, got %d (%s)", code, gotMethod)
+	}
+}
+
+func TestRouteTable(t *testing.T) {
+	var gotPath, gotQuery, gotMethod string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery, gotMethod = r.URL.Path, r.URL.RawQuery, r.Method
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		News:     config.News{URL: upstream.URL},
+		Comments: config.Comments{URL: upstream.URL + "/"},
+	}
+	cfg.Routes = []config.Route{
+		{Path: "/latest", Methods: []string{http.MethodGet}, Upstream: "news", UpstreamPath: "/news/latest"},
+		{Path: "/posts/{id:[0-9]+}", Methods: []string{http.MethodGet}, Upstream: "news", UpstreamPath: "/news/search?id={id}"},
+		{Path: "/comments", Methods: []string{http.MethodDelete}, Upstream: "comments"},
+	}
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method, target   string
+		wantPath, wantRQ string
+	}{
+		{http.MethodGet, "/latest?page=2", "/news/latest", "page=2"},
+		{http.MethodGet, "/posts/42", "/news/search", "id=42"},
+		{http.MethodDelete, "/comments?id=7", "/comments", "id=7"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		a.Router().ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("%s %s: expected 204, got %d", tt.method, tt.target, w.Code)
+		}
+		if gotMethod != tt.method || gotPath != tt.wantPath || gotQuery != tt.wantRQ {
+			t.Errorf("%s %s proxied as %s %s?%s", tt.method, tt.target, gotMethod, gotPath, gotQuery)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	a.Router().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/latest", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for unlisted method, got %d", w.Code)
+	}
+}
+
+func TestRouteAuth(t *testing.T) {
+	var gotUser, gotSig string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, gotSig = r.Header.Get(middl.UserIDHeader), r.Header.Get(middl.UserSignatureHeader)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{Comments: config.Comments{URL: upstream.URL}}
+	cfg.Routes = []config.Route{
+		{Path: "/moderation/queue", Methods: []string{http.MethodGet}, Upstream: "comments",
+			Auth: config.AuthRequired, Roles: []string{middl.RoleModerator}},
+	}
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Router().Use(middl.IdentityHeaders([]byte("secret")))
+
+	tests := []struct {
+		name string
+		id   *middl.Identity
+		want int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"no role", &middl.Identity{ID: "u1"}, http.StatusForbidden},
+		{"moderator", &middl.Identity{ID: "m1", Roles: []string{middl.RoleModerator}}, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/moderation/queue", nil)
+		req.Header.Set(middl.UserIDHeader, "forged")
+		if tt.id != nil {
+			req = middl.WithIdentity(req, *tt.id)
+		}
+		w := httptest.NewRecorder()
+		a.Router().ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, w.Code)
+		}
+	}
+	if gotUser != "m1" || gotSig == "" {
+		t.Errorf("upstream got identity %q with signature %q", gotUser, gotSig)
+	}
+}
+
+func TestRouteRateLimit(t *testing.T) {
+	upstream := mockServer(http.StatusOK, nil)
+	defer upstream.Close()
+
+	cfg := &config.Config{News: config.News{URL: upstream.URL}}
+	cfg.Gateway.RateLimit = config.RateLimit{Requests: 100, Per: config.Duration(time.Minute)}
+	cfg.Routes = []config.Route{
+		{Path: "/news", Methods: []string{http.MethodGet}, Upstream: "news",
+			RateLimit: &config.RateLimit{Requests: 2, Per: config.Duration(time.Minute)}},
+		{Path: "/news/latest", Methods: []string{http.MethodGet}, Upstream: "news"},
+	}
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codes := []int{}
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		a.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/news", nil))
+		codes = append(codes, w.Code)
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("route limit: got %v", codes)
+	}
+	w := httptest.NewRecorder()
+	a.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/news/latest", nil))
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "100" {
+		t.Errorf("default limit: got %d, RateLimit-Limit %q", w.Code, w.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestCommentChangesPurgeNews(t *testing.T) {
+	newsHits := 0
+	newsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		newsHits++
+		w.Write([]byte(`{"id": 3}`))
+	}))
+	defer newsSrv.Close()
+	censorSrv := mockServer(http.StatusOK, map[string]interface{}{"action": "allow", "text": "fixed"})
+	defer censorSrv.Close()
+	commentsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(newsIDHeader, "3")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer commentsSrv.Close()
+
+	cfg := &config.Config{
+		News:     config.News{URL: newsSrv.URL},
+		Censor:   config.Censor{URL: censorSrv.URL},
+		Comments: config.Comments{URL: commentsSrv.URL},
+		Routes: []config.Route{
+			{Path: "/news/{id:[0-9]+}", Methods: []string{http.MethodGet}, Upstream: "news",
+				Cache: &config.RouteCache{TTL: config.Duration(time.Minute)}},
+			{Path: "/comments/{id:[0-9]+}", Methods: []string{http.MethodPatch}, Handler: "edit_comment"},
+			{Path: "/comments", Methods: []string{http.MethodDelete}, Upstream: "comments"},
+		},
+	}
+	cfg.Gateway.CacheMaxBytes = 1 << 20
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, target, body string) {
+		a.Router().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, target, bytes.NewBufferString(body)))
+	}
+	for i, change := range []func(){
+		func() { serve(http.MethodPatch, "/comments/5", `{"text":"fixed"}`) },
+		func() { serve(http.MethodDelete, "/comments?id=5", "") },
+	} {
+		serve(http.MethodGet, "/news/3", "")
+		change()
+		serve(http.MethodGet, "/news/3", "")
+		if want := i + 2; newsHits != want {
+			t.Errorf("change %d must purge the cached news: upstream hits %d, want %d", i, newsHits, want)
+		}
+	}
+}
+
+func TestRouteCache(t *testing.T) {
+	hits := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte(`{"posts": []}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{News: config.News{URL: upstream.URL}}
+	cfg.Gateway.CacheMaxBytes = 1 << 20
+	cfg.Routes = []config.Route{
+		{Path: "/news", Methods: []string{http.MethodGet}, Upstream: "news",
+			Cache: &config.RouteCache{TTL: config.Duration(time.Minute)}},
+	}
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		a.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/news?page=1", nil))
+		return w
+	}
+	get()
+	if w := get(); w.Code != http.StatusOK || w.Header().Get("X-Cache") != "HIT" || hits != 1 {
+		t.Fatalf("second request: got %d, X-Cache %q, upstream hits %d", w.Code, w.Header().Get("X-Cache"), hits)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/cache?prefix=/news", nil)
+	req = middl.WithIdentity(req, middl.Identity{ID: "ops", Roles: []string{middl.RoleAdmin}})
+	w := httptest.NewRecorder()
+	a.Router().ServeHTTP(w, req)
+	var purged struct {
+		Purged int `json:"purged"`
+	}
+	json.NewDecoder(w.Body).Decode(&purged)
+	if w.Code != http.StatusOK || purged.Purged != 1 {
+		t.Fatalf("purge: got %d %+v", w.Code, purged)
+	}
+	if w := get(); w.Header().Get("X-Cache") != "MISS" || hits != 2 {
+		t.Errorf("after purge: X-Cache %q, upstream hits %d", w.Header().Get("X-Cache"), hits)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	upstream := mockServer(http.StatusOK, nil)
+	defer upstream.Close()
+	a, err := New(&config.Config{News: config.News{URL: upstream.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Router().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/news", nil))
+
+	w := httptest.NewRecorder()
+	a.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(),
+		`gateway_upstream_request_duration_seconds_count{upstream="news",method="GET",code="200"}`) {
+		t.Errorf("upstream latency is not exported: %d\n%s", w.Code, w.Body)
+	}
+}
+
+func TestAdminKeys(t *testing.T) {
+	a, err := New(&config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := middl.NewAPIKeys(middl.NewFileKeyStore(filepath.Join(t.TempDir(), "apikeys.json")), false)
+	a.InitAdminRoutes(keys, time.Hour)
+
+	serve := func(method, target, body string, roles ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req = middl.WithIdentity(req, middl.Identity{ID: "ops", Roles: roles})
+		w := httptest.NewRecorder()
+		a.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	if w := serve(http.MethodGet, "/admin/usage", ""); w.Code != http.StatusForbidden {
+		t.Errorf("usage without admin role: expected 403, got %d", w.Code)
+	}
+	w := serve(http.MethodPost, "/admin/keys", `{"client": "partner", "per_minute": 60}`, middl.RoleAdmin)
+	var issued struct {
+		Key    string `json:"key"`
+		Client string `json:"client"`
+	}
+	json.NewDecoder(w.Body).Decode(&issued)
+	if w.Code != http.StatusCreated || issued.Key == "" || issued.Client != "partner" {
+		t.Fatalf("create key: got %d %+v", w.Code, issued)
+	}
+	if w := serve(http.MethodPost, "/admin/keys/partner/rotate?overlap=10m", "", middl.RoleAdmin); w.Code != http.StatusOK {
+		t.Errorf("rotate: expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if w := serve(http.MethodPost, "/admin/keys/nobody/rotate", "", middl.RoleAdmin); w.Code != http.StatusNotFound {
+		t.Errorf("rotate unknown client: expected 404, got %d", w.Code)
+	}
+	if w := serve(http.MethodGet, "/admin/usage", "", middl.RoleAdmin); w.Code != http.StatusOK {
+		t.Errorf("usage: expected 200, got %d", w.Code)
+	}
+}
+
+func TestRouteTableUnknownHandler(t *testing.T) {
+	cfg := &config.Config{Routes: []config.Route{
+		{Path: "/x", Methods: []string{http.MethodGet}, Handler: "no_such_handler"},
+	}}
+	if _, err := New(cfg); err == nil {
+		t.Fatal("expected error for unknown handler")
+	}
+}
+
+func TestUpstreamBasePath(t *testing.T) {
+	var gotPath string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+	}))
+	defer upstream.Close()
+
+	a, err := New(&config.Config{News: config.News{URL: upstream.URL + "/aggregator/"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	a.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/news", nil))
+	if gotPath != "/aggregator/news" {
+		t.Errorf("expected upstream path /aggregator/news, got %q", gotPath)
+	}
+}
+
+func TestUpstreamPool(t *testing.T) {
+	var hitsA, hitsB int
+	srvA := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hitsA++ }))
+	defer srvA.Close()
+	srvB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hitsB++ }))
+	defer srvB.Close()
+
+	a, err := New(&config.Config{News: config.News{URL: srvA.URL + "," + srvB.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		a.Router().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/news", nil))
+	}
+	if hitsA != 2 || hitsB != 2 {
+		t.Errorf("expected round-robin 2/2, got %d/%d", hitsA, hitsB)
+	}
+}
+
+func TestGatewayStatus(t *testing.T) {
+	up := mockServer(http.StatusOK, nil)
+	defer up.Close()
+	down := mockServer(http.StatusServiceUnavailable, nil)
+	defer down.Close()
+
+	a, err := New(&config.Config{
+		News:     config.News{URL: up.URL},
+		Comments: config.Comments{URL: up.URL + "," + down.URL},
+		Censor:   config.Censor{URL: down.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream.NewProber(a.Pools(), "/readyz", time.Hour, time.Second).CheckAll(context.Background())
+
+	w := httptest.NewRecorder()
+	a.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/gateway/status", nil))
+	var st struct {
+		Status    string `json:"status"`
+		Upstreams []struct {
+			Name      string `json:"name"`
+			Available int    `json:"available"`
+		} `json:"upstreams"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Status != "down" {
+		t.Errorf("censor has no available instances, expected down, got %q", st.Status)
+	}
+	want := map[string]int{"news": 1, "comments": 1, "censor": 0}
+	for _, u := range st.Upstreams {
+		if want[u.Name] != u.Available {
+			t.Errorf("%s: expected %d available, got %d", u.Name, want[u.Name], u.Available)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	a.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected /readyz 503, got %d", w.Code)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	failing := true
+	var calls int
+	newsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"title": "ok"})
+	}))
+	defer newsSrv.Close()
+
+	cfg := &config.Config{News: config.News{URL: newsSrv.URL}}
+	cfg.Gateway.Breaker = breaker.Config{MinRequests: 3, FailureRate: 0.5, Cooldown: 50 * time.Millisecond}
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		a.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/news", nil))
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		get()
+	}
+	w := get()
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected fast 503 with Retry-After, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if calls != 3 {
+		t.Errorf("open breaker must not call upstream, got %d calls", calls)
+	}
+
+	failing = false
+	time.Sleep(60 * time.Millisecond)
+	if w := get(); w.Code != http.StatusOK {
+		t.Fatalf("expected recovery after cooldown, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	a.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/gateway/status", nil))
+	if !bytes.Contains(w.Body.Bytes(), []byte(`"state":"closed"`)) {
+		t.Errorf("breaker state missing from status: %s", w.Body.String())
+	}
+}
+
+func TestRetryPostCommentWithIdempotencyKey(t *testing.T) {
+	censorSrv := mockServer(http.StatusOK, map[string]string{"text": "clean"})
+	defer censorSrv.Close()
+
+	var attempts int
+	var keys []string
+	commentsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer commentsSrv.Close()
+
+	cfg := &config.Config{
+		Censor:   config.Censor{URL: censorSrv.URL},
+		Comments: config.Comments{URL: commentsSrv.URL},
+	}
+	cfg.Gateway.Retry = config.RetryPolicy{MaxAttempts: 3, RetryOn: []int{503}, Budget: 1, MinRetriesPerSecond: 1}
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(key string) int {
+		req := httptest.NewRequest(http.MethodPost, "/news/1/comments", bytes.NewBufferString(`{"text":"hi"}`))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		a.Router().ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := post(""); code != http.StatusServiceUnavailable || attempts != 1 {
+		t.Fatalf("without key expected single attempt and 503, got %d after %d", code, attempts)
+	}
+	attempts = 0
+	if code := post("key-1"); code != http.StatusCreated || attempts != 2 {
+		t.Fatalf("with key expected retry and 201, got %d after %d", code, attempts)
+	}
+	if keys[len(keys)-1] != "key-1" {
+		t.Errorf("Idempotency-Key not forwarded to comments service: %v", keys)
+	}
+}
+
+func TestNewsByIDDegradation(t *testing.T) {
+	newsSrv := mockServer(http.StatusOK, map[string]interface{}{"id": 1, "title": "Test News"})
+	defer newsSrv.Close()
+	commentsSrv := mockServer(http.StatusInternalServerError, nil)
+	defer commentsSrv.Close()
+
+	tests := []struct {
+		mode       string
+		wantStatus int
+	}{
+		{config.ModeStrict, http.StatusBadGateway},
+		{config.ModePartial, http.StatusOK},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{
+			News:     config.News{URL: newsSrv.URL},
+			Comments: config.Comments{URL: commentsSrv.URL},
+			Routes: []config.Route{
+				{Path: "/news/{id:[0-9]+}", Methods: []string{http.MethodGet}, Handler: "news_detail", Mode: tt.mode},
+			},
+		}
+		a, err := New(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		a.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/news/1", nil))
+		if w.Code != tt.wantStatus {
+			t.Fatalf("%s: expected %d, got %d", tt.mode, tt.wantStatus, w.Code)
+		}
+		if tt.mode != config.ModePartial {
+			continue
+		}
+
+		var body struct {
+			News     map[string]interface{} `json:"news"`
+			Comments *[]interface{}         `json:"comments"`
+			Degraded []struct {
+				Part   string `json:"part"`
+				Reason string `json:"reason"`
+			} `json:"degraded"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.News["title"] != "Test News" || body.Comments != nil {
+			t.Errorf("expected news with null comments, got %+v", body)
+		}
+		if len(body.Degraded) != 1 || body.Degraded[0].Part != "comments" || body.Degraded[0].Reason != "upstream_error" {
+			t.Errorf("unexpected degraded field: %+v", body.Degraded)
+		}
+	}
+}
+
+func TestDegradationReason(t *testing.T) {
+	for err, want := range map[error]string{
+		&breaker.OpenError{Name: "comments"}:                              "circuit_open",
+		fmt.Errorf("get: %w", context.DeadlineExceeded):                   "timeout",
+		&url.Error{Op: "Get", URL: "http://10.0.0.1", Err: errTimeout{}}:  "timeout",
+		&statusError{service: "comments", code: http.StatusBadGateway}:    "upstream_error",
+		errors.New("dial tcp 10.0.0.1:8082: connect: connection refused"): "unavailable",
+	} {
+		if got := degradationReason(err); got != want {
+			t.Errorf("%v: got %q, want %q", err, got, want)
+		}
+	}
+}
+
+// errTimeout — сетевая ошибка истечения времени.
+type errTimeout struct{}
+
+func (errTimeout) Error() string   { return "i/o timeout" }
+func (errTimeout) Timeout() bool   { return true }
+func (errTimeout) Temporary() bool { return true }
+
+func TestNewsByIDCoalescing(t *testing.T) {
+	var newsHits atomic.Int32
+	release := make(chan struct{})
+	newsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		newsHits.Add(1)
+		<-release
+		json.NewEncoder(w).Encode(map[string]string{"title": "Test News"})
+	}))
+	defer newsSrv.Close()
+	commentsSrv := mockServer(http.StatusOK, []interface{}{})
+	defer commentsSrv.Close()
+
+	cfg := &config.Config{
+		News:     config.News{URL: newsSrv.URL},
+		Comments: config.Comments{URL: commentsSrv.URL},
+	}
+	cfg.Gateway.Coalesce = true
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const clients = 5
+	codes := make([]int, clients)
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			a.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/news/1", nil))
+			codes[i] = w.Code
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("client %d: got %d", i, code)
+		}
+	}
+	if n := newsHits.Load(); n != 1 {
+		t.Errorf("expected one call to news upstream, got %d", n)
+	}
+	if st := a.status().Coalescing["news"]; st.Calls != 1 || st.Coalesced != clients-1 {
+		t.Errorf("news coalescing stats: %+v", st)
+	}
+}
+
+func TestUpstreamTracePropagation(t *testing.T) {
+	var mu sync.Mutex
+	got := map[string]http.Header{}
+	record := func(name string, body interface{}) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			got[name] = r.Header.Clone()
+			mu.Unlock()
+			json.NewEncoder(w).Encode(body)
+		}))
+	}
+	newsSrv := record("news", map[string]string{"title": "Test News"})
+	defer newsSrv.Close()
+	commentsSrv := record("comments", []interface{}{})
+	defer commentsSrv.Close()
+
+	a, err := New(&config.Config{
+		News:     config.News{URL: newsSrv.URL},
+		Comments: config.Comments{URL: commentsSrv.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Router().Use(middl.Middle, tracing.Middleware)
+
+	req := httptest.NewRequest(http.MethodGet, "/news/1", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	req.Header.Set(tracing.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	a.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	for _, name := range []string{"news", "comments"} {
+		h := got[name]
+		if h.Get("X-Request-ID") != "req-42" {
+			t.Errorf("%s: X-Request-ID = %q", name, h.Get("X-Request-ID"))
+		}
+		sc, ok := tracing.Extract(h)
+		if !ok || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() == "00f067aa0ba902b7" {
+			t.Errorf("%s: upstream call must continue the trace in its own span: %q", name, h.Get(tracing.TraceparentHeader))
+		}
+	}
+}
+
+func TestNewsByIDNotFound(t *testing.T) {
+	newsSrv := mockServer(http.StatusNotFound, nil)
+	defer newsSrv.Close()
+	commentsSrv := mockServer(http.StatusOK, []interface{}{})
+	defer commentsSrv.Close()
+
+	a, err := New(&config.Config{
+		News:     config.News{URL: newsSrv.URL},
+		Comments: config.Comments{URL: commentsSrv.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	a.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/news/1", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for missing news, got %d", w.Code)
+	}
+}
+
+func TestNewsByIDForwardsTreeParams(t *testing.T) {
+	newsSrv := mockServer(http.StatusOK, map[string]interface{}{"id": 1})
+	defer newsSrv.Close()
+	var gotQuery url.Values
+	commentsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query()
+		json.NewEncoder(w).Encode([]map[string]interface{}{{"id": 1, "replies": []interface{}{}}})
+	}))
+	defer commentsSrv.Close()
+
+	a, err := New(&config.Config{
+		News:     config.News{URL: newsSrv.URL},
+		Comments: config.Comments{URL: commentsSrv.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	a.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/news/7?view=tree&depth=2&sort=oldest&other=x", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	want := url.Values{"news_id": {"7"}, "view": {"tree"}, "depth": {"2"}, "sort": {"oldest"}}
+	if gotQuery.Encode() != want.Encode() {
+		t.Errorf("comments query: got %s, want %s", gotQuery.Encode(), want.Encode())
+	}
+	if !bytes.Contains(w.Body.Bytes(), []byte(`"replies"`)) {
+		t.Errorf("tree not passed through: %s", w.Body.String())
+	}
+}
+
+func TestNewsByIDCommentsPage(t *testing.T) {
+	newsSrv := mockServer(http.StatusOK, map[string]interface{}{"id": 1})
+	defer newsSrv.Close()
+	var gotQuery url.Values
+	commentsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query()
+		w.Header().Set("X-Total-Count", "120")
+		w.Header().Set("X-Next-Cursor", "abc")
+		json.NewEncoder(w).Encode([]map[string]interface{}{{"id": 1}})
+	}))
+	defer commentsSrv.Close()
+
+	a, err := New(&config.Config{
+		News:     config.News{URL: newsSrv.URL},
+		Comments: config.Comments{URL: commentsSrv.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	a.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/news/1?limit=1&cursor=xyz", nil))
+
+	if gotQuery.Get("limit") != "1" || gotQuery.Get("cursor") != "xyz" {
+		t.Errorf("pagination params not forwarded: %s", gotQuery.Encode())
+	}
+	var body struct {
+		Page struct {
+			Total      int    `json:"total"`
+			NextCursor string `json:"next_cursor"`
+		} `json:"comments_page"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Page.Total != 120 || body.Page.NextCursor != "abc" {
+		t.Errorf("unexpected comments_page: %+v", body.Page)
+	}
+}
+
+func TestNewsByIDCommentsOffsetPage(t *testing.T) {
+	newsSrv := mockServer(http.StatusOK, map[string]interface{}{"id": 1})
+	defer newsSrv.Close()
+	var gotQuery url.Values
+	commentsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query()
+		w.Header().Set("X-Total-Count", "120")
+		w.Header().Set("X-Next-Offset", "20")
+		json.NewEncoder(w).Encode([]map[string]interface{}{{"id": 1}})
+	}))
+	defer commentsSrv.Close()
+
+	a, err := New(&config.Config{
+		News:     config.News{URL: newsSrv.URL},
+		Comments: config.Comments{URL: commentsSrv.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	a.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/news/1?sort=replies&limit=10&offset=10", nil))
+
+	if gotQuery.Get("sort") != "replies" || gotQuery.Get("offset") != "10" {
+		t.Errorf("offset params not forwarded: %s", gotQuery.Encode())
+	}
+	var body struct {
+		Page struct {
+			NextOffset int `json:"next_offset"`
+		} `json:"comments_page"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Page.NextOffset != 20 {
+		t.Errorf("unexpected comments_page: %+v", body.Page)
+	}
+}
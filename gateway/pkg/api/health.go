@@ -0,0 +1,75 @@
+package api
+
+import (
+	"APIGateway/gateway/pkg/breaker"
+	"APIGateway/gateway/pkg/coalesce"
+	"APIGateway/gateway/pkg/upstream"
+	"APIGateway/pkg/metrics"
+	"encoding/json"
+	"net/http"
+)
+
+// gatewayStatus — сводный отчёт о состоянии upstream-сервисов.
+type gatewayStatus struct {
+	Status    string                `json:"status"`
+	Upstreams []upstream.PoolStatus `json:"upstreams"`
+	// Coalescing — счётчики объединения запросов по upstream.
+	Coalescing map[string]coalesce.Stats `json:"coalescing,omitempty"`
+}
+
+// Pools возвращает пулы upstream-сервисов, например для запуска активных проверок.
+func (a *API) Pools() []*upstream.Pool {
+	return []*upstream.Pool{a.news, a.comments, a.censor}
+}
+
+func (a *API) initHealthRoutes() {
+	a.router.HandleFunc("/healthz", a.handleHealthz).Methods(http.MethodGet)
+	a.router.HandleFunc("/readyz", a.handleReadyz).Methods(http.MethodGet)
+	a.router.HandleFunc("/gateway/status", a.handleStatus).Methods(http.MethodGet)
+	a.router.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
+}
+
+func (a *API) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// handleReadyz отвечает 503, если хотя бы у одного upstream нет доступных экземпляров.
+func (a *API) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	st := a.status()
+	w.Header().Set("Content-Type", "application/json")
+	if st.Status == "down" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": st.Status})
+}
+
+func (a *API) handleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.status())
+}
+
+// status собирает отчёт: ok — доступны все экземпляры, degraded — часть,
+// down — у какого-то upstream не осталось ни одного.
+func (a *API) status() gatewayStatus {
+	st := gatewayStatus{Status: "ok"}
+	for _, pool := range a.Pools() {
+		ps := pool.Status()
+		st.Upstreams = append(st.Upstreams, ps)
+		if g := a.coalesce[pool.Name()]; g != nil {
+			if st.Coalescing == nil {
+				st.Coalescing = map[string]coalesce.Stats{}
+			}
+			st.Coalescing[pool.Name()] = g.Stats()
+		}
+		switch {
+		case ps.Available == 0:
+			st.Status = "down"
+		case ps.Available < len(ps.Instances) && st.Status == "ok":
+			st.Status = "degraded"
+		case ps.Breaker != nil && ps.Breaker.State != breaker.Closed && st.Status == "ok":
+			st.Status = "degraded"
+		}
+	}
+	return st
+}
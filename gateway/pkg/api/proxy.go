@@ -0,0 +1,64 @@
+package api
+
+import (
+	"APIGateway/gateway/config"
+	"APIGateway/gateway/pkg/upstream"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// proxy возвращает обработчик, который пересылает запрос на upstream без изменений,
+// кроме пути: он берётся из UpstreamPath маршрута с подстановкой переменных пути.
+func (a *API) proxy(pool *upstream.Pool, rt config.Route) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if rt.UpstreamPath != "" {
+			path = rewritePath(rt.UpstreamPath, mux.Vars(r))
+		}
+
+		req, err := http.NewRequestWithContext(r.Context(), r.Method, path, r.Body)
+		if err != nil {
+			http.Error(w, "failed to create request", http.StatusInternalServerError)
+			return
+		}
+		req.URL.RawQuery = joinQuery(req.URL.RawQuery, r.URL.RawQuery)
+		req.ContentLength = r.ContentLength
+		copyHeader(r.Header, req.Header)
+
+		resp, err := a.do(pool, req)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to proxy request", "upstream", rt.Upstream, "err", err)
+			upstreamError(w, err, "upstream "+rt.Upstream+" unavailable")
+			return
+		}
+		defer resp.Body.Close()
+		a.purgeNews(resp)
+
+		copyHeader(resp.Header, w.Header())
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}
+}
+
+// rewritePath подставляет переменные маршрута вида {id} в шаблон пути upstream.
+func rewritePath(tmpl string, vars map[string]string) string {
+	for k, v := range vars {
+		tmpl = strings.ReplaceAll(tmpl, "{"+k+"}", v)
+	}
+	return tmpl
+}
+
+// joinQuery объединяет query из шаблона upstream_path с query исходного запроса.
+func joinQuery(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	}
+	return a + "&" + b
+}
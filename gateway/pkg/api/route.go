@@ -0,0 +1,102 @@
+package api
+
+import (
+	"APIGateway/gateway/config"
+	"APIGateway/gateway/pkg/middl"
+	"APIGateway/gateway/pkg/retry"
+	"APIGateway/gateway/pkg/upstream"
+	"APIGateway/pkg/metrics"
+	"APIGateway/pkg/tracing"
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type routeKey struct{}
+
+// route — состояние маршрута, доступное обработчикам через контекст запроса.
+type route struct {
+	cfg     config.Route
+	retrier *retry.Retrier
+}
+
+func newRoute(rt config.Route, defaultRetry config.RetryPolicy) *route {
+	policy := defaultRetry
+	if rt.Retry != nil {
+		policy = *rt.Retry
+	}
+	// Бюджет повторов у каждого маршрута свой.
+	retrier := retry.New(retry.Policy{
+		MaxAttempts: policy.MaxAttempts,
+		BaseDelay:   time.Duration(policy.BaseDelay),
+		MaxDelay:    time.Duration(policy.MaxDelay),
+		RetryOn:     policy.RetryOn,
+	}, retry.NewBudget(policy.Budget, policy.MinRetriesPerSecond))
+	retrier.OnRetry = func(req *http.Request, attempt int, delay time.Duration, reason string) {
+		slog.WarnContext(req.Context(), "retrying upstream request",
+			"attempt", attempt, "max_attempts", policy.MaxAttempts, "method", req.Method,
+			"path", req.URL.Path, "delay", delay.Round(time.Millisecond), "reason", reason)
+	}
+	return &route{cfg: rt, retrier: retrier}
+}
+
+// handler кладёт состояние маршрута в контекст запроса.
+func (rt *route) handler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next(w, r.WithContext(context.WithValue(r.Context(), routeKey{}, rt)))
+	}
+}
+
+func routeFrom(ctx context.Context) *route {
+	rt, _ := ctx.Value(routeKey{}).(*route)
+	return rt
+}
+
+// do выполняет запрос к upstream с политикой повторов текущего маршрута.
+func (a *API) do(pool *upstream.Pool, req *http.Request) (*http.Response, error) {
+	var retrier *retry.Retrier
+	if rt := routeFrom(req.Context()); rt != nil {
+		retrier = rt.retrier
+	}
+	call := func(req *http.Request) (*http.Response, error) {
+		return retrier.Do(req, observe(pool))
+	}
+	if g := a.coalesce[pool.Name()]; g != nil {
+		return g.Do(req, call)
+	}
+	return call(req)
+}
+
+var upstreamDuration = metrics.NewHistogram("gateway_upstream_request_duration_seconds",
+	"Latency of gateway calls to upstream services; code is \"error\" when no response was received.",
+	nil, "upstream", "method", "code")
+
+// observe трассирует каждую попытку вызова upstream и учитывает её длительность.
+// Upstream получает контекст трассы в traceparent и идентификатор запроса в X-Request-ID.
+func observe(pool *upstream.Pool) func(*http.Request) (*http.Response, error) {
+	return func(req *http.Request) (*http.Response, error) {
+		req, span := tracing.StartClient(req, "upstream "+pool.Name())
+		defer span.End()
+		span.SetAttr("upstream", pool.Name())
+		if id := middl.GetRequestID(req.Context()); id != "unknown" {
+			req.Header.Set("X-Request-ID", id)
+		}
+
+		start := time.Now()
+		resp, err := pool.Do(req)
+		code := "error"
+		if err == nil {
+			code = strconv.Itoa(resp.StatusCode)
+			span.SetAttr("http.response.status_code", resp.StatusCode)
+			if resp.StatusCode >= http.StatusInternalServerError {
+				span.SetError(resp.Status)
+			}
+		} else {
+			span.SetError(err.Error())
+		}
+		upstreamDuration.Since(start, pool.Name(), req.Method, code)
+		return resp, err
+	}
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"APIGateway/gateway/pkg/middl"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testNow = time.Unix(1700000000, 0)
+
+// sign собирает JWT с заголовком hdr и claims; key — []byte для HS256 или *rsa.PrivateKey для RS256.
+func sign(t *testing.T, hdr, claims map[string]interface{}, key interface{}) string {
+	t.Helper()
+	h, _ := json.Marshal(hdr)
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func claims(extra map[string]interface{}) map[string]interface{} {
+	c := map[string]interface{}{"sub": "u1", "name": "Алиса", "exp": testNow.Add(time.Hour).Unix()}
+	for k, v := range extra {
+		c[k] = v
+	}
+	return c
+}
+
+// writeJWKS сохраняет открытый ключ в JWKS-файл во временном каталоге.
+func writeJWKS(t *testing.T, kid string, key *rsa.PublicKey) string {
+	t.Helper()
+	set := map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA", "kid": kid, "use": "sig", "alg": RS256,
+		"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	b, _ := json.Marshal(set)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestVerifier(t *testing.T, opts Options) *Verifier {
+	t.Helper()
+	v, err := NewVerifier(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.now = func() time.Time { return testNow }
+	return v
+}
+
+func TestVerifyHS256(t *testing.T) {
+	secret := []byte("test-secret")
+	v := newTestVerifier(t, Options{HS256Secret: string(secret), Issuer: "issuer", Audience: "gateway", Leeway: time.Minute})
+	hs := map[string]interface{}{"alg": HS256, "typ": "JWT"}
+	valid := map[string]interface{}{"iss": "issuer", "aud": []string{"gateway", "other"}, "roles": []string{"moderator"}}
+
+	id, err := v.Verify(sign(t, hs, claims(valid), secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.ID != "u1" || id.Name != "Алиса" || !id.HasRole("moderator") {
+		t.Errorf("unexpected identity: %+v", id)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"wrong secret", sign(t, hs, claims(valid), []byte("other")), ErrInvalidToken},
+		{"alg none", sign(t, map[string]interface{}{"alg": "none"}, claims(valid), []byte{}), ErrInvalidToken},
+		{"RS256 not enabled", sign(t, map[string]interface{}{"alg": RS256}, claims(valid), secret), ErrInvalidToken},
+		{"expired", sign(t, hs, claims(map[string]interface{}{"iss": "issuer", "aud": "gateway", "exp": testNow.Add(-2 * time.Minute).Unix()}), secret), ErrExpired},
+		{"within leeway", sign(t, hs, claims(map[string]interface{}{"iss": "issuer", "aud": "gateway", "exp": testNow.Add(-30 * time.Second).Unix()}), secret), nil},
+		{"no exp", sign(t, hs, map[string]interface{}{"sub": "u1", "iss": "issuer", "aud": "gateway"}, secret), ErrInvalidToken},
+		{"wrong issuer", sign(t, hs, claims(map[string]interface{}{"iss": "evil", "aud": "gateway"}), secret), ErrInvalidToken},
+		{"wrong audience", sign(t, hs, claims(map[string]interface{}{"iss": "issuer", "aud": "other"}), secret), ErrInvalidToken},
+		{"malformed", "abc.def", ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		if _, err := v.Verify(tt.token); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestVerifyRS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := newTestVerifier(t, Options{JWKSFile: writeJWKS(t, "k1", &key.PublicKey), RolesClaim: "realm_access.roles"})
+
+	token := sign(t, map[string]interface{}{"alg": RS256, "kid": "k1"},
+		claims(map[string]interface{}{"realm_access": map[string]interface{}{"roles": []string{"moderator", "user"}}}), key)
+	id, err := v.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !id.HasRole("moderator") || len(id.Roles) != 2 {
+		t.Errorf("nested roles claim is not mapped: %+v", id)
+	}
+
+	if _, err := v.Verify(sign(t, map[string]interface{}{"alg": RS256}, claims(nil), key)); err != nil {
+		t.Errorf("token without kid must use the only key: %v", err)
+	}
+	if _, err := v.Verify(sign(t, map[string]interface{}{"alg": RS256, "kid": "k1"}, claims(nil), other)); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("foreign key: got %v", err)
+	}
+	if _, err := v.Verify(sign(t, map[string]interface{}{"alg": RS256, "kid": "k2"}, claims(nil), key)); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("unknown kid: got %v", err)
+	}
+	// Открытый ключ RSA нельзя использовать как секрет HS256.
+	if _, err := v.Verify(sign(t, map[string]interface{}{"alg": HS256, "kid": "k1"}, claims(nil), key.PublicKey.N.Bytes())); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("alg confusion: got %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	secret := []byte("test-secret")
+	v := newTestVerifier(t, Options{HS256Secret: string(secret)})
+	token := sign(t, map[string]interface{}{"alg": HS256}, claims(map[string]interface{}{"roles": "user"}), secret)
+
+	h := Middleware(Bearer{Verifier: v})(Require([]string{"user"}, func(w http.ResponseWriter, r *http.Request) {
+		id, _ := middl.GetIdentity(r.Context())
+		w.Write([]byte(id.ID))
+	}))
+	modOnly := Middleware(Bearer{Verifier: v})(Require([]string{middl.RoleModerator}, func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		auth    string
+		want    int
+	}{
+		{"anonymous", h, "", http.StatusUnauthorized},
+		{"invalid token", h, "Bearer abc.def.ghi", http.StatusUnauthorized},
+		{"wrong scheme", h, "Basic dTE6cGFzcw==", http.StatusUnauthorized},
+		{"valid token", h, "Bearer " + token, http.StatusOK},
+		{"missing role", modOnly, "Bearer " + token, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		rr := httptest.NewRecorder()
+		tt.handler.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, rr.Code)
+		}
+		if rr.Code == http.StatusUnauthorized && rr.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: missing WWW-Authenticate", tt.name)
+		}
+		if tt.want == http.StatusOK && rr.Body.String() != "u1" {
+			t.Errorf("%s: identity is not passed to the handler: %q", tt.name, rr.Body)
+		}
+	}
+}
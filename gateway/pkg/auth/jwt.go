@@ -0,0 +1,268 @@
+// Package auth проверяет JWT клиентов шлюза и превращает их в личность пользователя.
+package auth
+
+import (
+	"APIGateway/gateway/pkg/middl"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Ошибки проверки токена.
+var (
+	ErrNoCredentials = errors.New("no credentials")
+	ErrInvalidToken  = errors.New("invalid token")
+	ErrExpired       = errors.New("token expired")
+)
+
+// Алгоритмы подписи, которые принимает шлюз.
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+)
+
+// Options — параметры проверки токенов.
+type Options struct {
+	// HS256Secret — общий секрет для токенов HS256; пустой отключает HS256.
+	HS256Secret string
+	// JWKSFile — локальный файл JWKS с открытыми ключами RS256; пустой отключает RS256.
+	JWKSFile string
+	// Issuer и Audience, если заданы, должны совпасть с iss и aud токена.
+	Issuer   string
+	Audience string
+	// Leeway — допустимое расхождение часов при проверке exp и nbf.
+	Leeway time.Duration
+	// NameClaim и RolesClaim — claims с именем и ролями пользователя;
+	// вложенные claims задаются через точку, например realm_access.roles.
+	NameClaim  string
+	RolesClaim string
+}
+
+// Enabled сообщает, настроен ли хотя бы один ключ проверки.
+func (o Options) Enabled() bool {
+	return o.HS256Secret != "" || o.JWKSFile != ""
+}
+
+// Verifier проверяет подпись и срок действия JWT.
+type Verifier struct {
+	opts   Options
+	secret []byte
+	keys   map[string]*rsa.PublicKey
+	now    func() time.Time
+}
+
+// NewVerifier создаёт проверяющего и загружает JWKS, если он задан.
+func NewVerifier(opts Options) (*Verifier, error) {
+	if !opts.Enabled() {
+		return nil, errors.New("auth: neither HS256 secret nor JWKS file is set")
+	}
+	if opts.NameClaim == "" {
+		opts.NameClaim = "name"
+	}
+	if opts.RolesClaim == "" {
+		opts.RolesClaim = "roles"
+	}
+	v := &Verifier{opts: opts, secret: []byte(opts.HS256Secret), now: time.Now}
+	if opts.JWKSFile != "" {
+		keys, err := LoadJWKS(opts.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = keys
+	}
+	return v, nil
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// registered — зарегистрированные claims, которые проверяет шлюз.
+type registered struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *float64 `json:"exp"`
+	NotBefore *float64 `json:"nbf"`
+}
+
+// audience — aud бывает и строкой, и массивом строк.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// Verify проверяет токен и возвращает личность из его claims.
+// Токены без exp и без sub не принимаются.
+func (v *Verifier) Verify(token string) (middl.Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return middl.Identity{}, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return middl.Identity{}, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return middl.Identity{}, fmt.Errorf("%w: signature encoding", ErrInvalidToken)
+	}
+	if err := v.verifySignature(h, parts[0]+"."+parts[1], sig); err != nil {
+		return middl.Identity{}, err
+	}
+
+	var reg registered
+	if err := decodeSegment(parts[1], &reg); err != nil {
+		return middl.Identity{}, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return middl.Identity{}, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	if err := v.validate(reg); err != nil {
+		return middl.Identity{}, err
+	}
+
+	id := middl.Identity{ID: reg.Subject}
+	id.Name, _ = lookup(claims, v.opts.NameClaim).(string)
+	id.Roles = roles(lookup(claims, v.opts.RolesClaim))
+	return id, nil
+}
+
+// verifySignature проверяет подпись ключом, соответствующим алгоритму из заголовка.
+// Алгоритм без настроенного ключа (в том числе none) отвергается.
+func (v *Verifier) verifySignature(h header, signed string, sig []byte) error {
+	switch h.Alg {
+	case HS256:
+		if len(v.secret) == 0 {
+			return fmt.Errorf("%w: HS256 is not enabled", ErrInvalidToken)
+		}
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	case RS256:
+		key, err := v.rsaKey(h.Kid)
+		if err != nil {
+			return err
+		}
+		digest := sha256.Sum256([]byte(signed))
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported alg %q", ErrInvalidToken, h.Alg)
+	}
+	return nil
+}
+
+// rsaKey ищет ключ по kid; токен без kid принимается, только если ключ в JWKS один.
+func (v *Verifier) rsaKey(kid string) (*rsa.PublicKey, error) {
+	if len(v.keys) == 0 {
+		return nil, fmt.Errorf("%w: RS256 is not enabled", ErrInvalidToken)
+	}
+	if kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys {
+			return k, nil
+		}
+	}
+	key, ok := v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key id %q", ErrInvalidToken, kid)
+	}
+	return key, nil
+}
+
+func (v *Verifier) validate(reg registered) error {
+	now := v.now()
+	if reg.ExpiresAt == nil {
+		return fmt.Errorf("%w: exp is required", ErrInvalidToken)
+	}
+	if now.After(unixTime(*reg.ExpiresAt).Add(v.opts.Leeway)) {
+		return ErrExpired
+	}
+	if reg.NotBefore != nil && now.Add(v.opts.Leeway).Before(unixTime(*reg.NotBefore)) {
+		return fmt.Errorf("%w: token is not valid yet", ErrInvalidToken)
+	}
+	if reg.Subject == "" {
+		return fmt.Errorf("%w: sub is required", ErrInvalidToken)
+	}
+	if v.opts.Issuer != "" && reg.Issuer != v.opts.Issuer {
+		return fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, reg.Issuer)
+	}
+	if v.opts.Audience != "" && !contains(reg.Audience, v.opts.Audience) {
+		return fmt.Errorf("%w: token is not issued for %q", ErrInvalidToken, v.opts.Audience)
+	}
+	return nil
+}
+
+func decodeSegment(seg string, dst interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dst)
+}
+
+func unixTime(sec float64) time.Time {
+	return time.Unix(0, int64(sec*float64(time.Second)))
+}
+
+// lookup достаёт claim по пути через точку.
+func lookup(claims map[string]interface{}, path string) interface{} {
+	var cur interface{} = claims
+	for _, key := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = m[key]
+	}
+	return cur
+}
+
+// roles принимает роли массивом строк или строкой через пробел, как scope в OAuth.
+func roles(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		var out []string
+		for _, r := range v {
+			if s, ok := r.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"APIGateway/gateway/pkg/middl"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Authenticator определяет пользователя по запросу. ErrNoCredentials означает,
+// что запрос анонимный; любая другая ошибка — что учётные данные неверны.
+type Authenticator interface {
+	Authenticate(r *http.Request) (middl.Identity, error)
+}
+
+// Bearer проверяет JWT из заголовка Authorization: Bearer <token>.
+type Bearer struct {
+	Verifier *Verifier
+}
+
+func (b Bearer) Authenticate(r *http.Request) (middl.Identity, error) {
+	h := r.Header.Get("Authorization")
+	if h == "" {
+		return middl.Identity{}, ErrNoCredentials
+	}
+	scheme, token, ok := strings.Cut(h, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return middl.Identity{}, ErrInvalidToken
+	}
+	return b.Verifier.Verify(strings.TrimSpace(token))
+}
+
+// Middleware кладёт в контекст личность, которую вернул a. Анонимные запросы
+// проходят дальше, запросы с неверными учётными данными получают 401.
+func Middleware(a Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, err := a.Authenticate(r)
+			switch {
+			case errors.Is(err, ErrNoCredentials):
+			case err != nil:
+				slog.WarnContext(r.Context(), "authentication failed", "err", err)
+				unauthorized(w, `Bearer error="invalid_token"`, "invalid token")
+				return
+			default:
+				r = middl.WithIdentity(r, id)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Require пропускает только аутентифицированных пользователей со всеми ролями roles.
+func Require(roles []string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := middl.GetIdentity(r.Context())
+		if !ok {
+			unauthorized(w, "Bearer", "authentication required")
+			return
+		}
+		for _, role := range roles {
+			if !id.HasRole(role) {
+				http.Error(w, "role "+role+" required", http.StatusForbidden)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+func unauthorized(w http.ResponseWriter, challenge, msg string) {
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, msg, http.StatusUnauthorized)
+}
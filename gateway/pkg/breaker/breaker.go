@@ -0,0 +1,278 @@
+// Package breaker реализует автоматический выключатель (circuit breaker) для вызовов upstream.
+package breaker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// State — состояние выключателя.
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Config — пороги срабатывания выключателя.
+type Config struct {
+	// Window — скользящее окно, за которое считается доля ошибок и медленных вызовов.
+	Window time.Duration
+	// MinRequests — минимум вызовов в окне, прежде чем пороги начинают проверяться.
+	MinRequests int
+	// FailureRate — доля ошибок (0..1), при которой выключатель размыкается. 0 отключает порог.
+	FailureRate float64
+	// SlowCall — вызов дольше этого считается медленным.
+	SlowCall time.Duration
+	// SlowCallRate — доля медленных вызовов (0..1), при которой выключатель размыкается. 0 отключает порог.
+	SlowCallRate float64
+	// Cooldown — сколько выключатель остаётся разомкнутым перед пробными вызовами.
+	Cooldown time.Duration
+	// HalfOpenProbes — сколько пробных вызовов должно пройти успешно, чтобы замкнуться.
+	HalfOpenProbes int
+}
+
+// Enabled сообщает, задан ли хотя бы один порог.
+func (c Config) Enabled() bool {
+	return c.FailureRate > 0 || (c.SlowCallRate > 0 && c.SlowCall > 0)
+}
+
+// OpenError возвращается, когда вызов отклонён разомкнутым выключателем.
+type OpenError struct {
+	Name       string
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("circuit breaker %s is open, retry after %s", e.Name, e.RetryAfter.Round(time.Second))
+}
+
+// numBuckets — на сколько интервалов делится окно статистики.
+const numBuckets = 10
+
+type bucket struct {
+	start    time.Time
+	requests int
+	failures int
+	slow     int
+}
+
+// Breaker — выключатель для одного upstream.
+type Breaker struct {
+	name string
+	cfg  Config
+	now  func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	openedAt time.Time
+	buckets  [numBuckets]bucket
+	// generation меняется при каждой смене состояния: результат вызова,
+	// разрешённого в прежнем состоянии, не учитывается.
+	generation uint64
+	// Пробные вызовы в полуоткрытом состоянии.
+	probesInFlight int
+	probesPassed   int
+}
+
+// New создаёт замкнутый выключатель.
+func New(name string, cfg Config) *Breaker {
+	if cfg.Window <= 0 {
+		cfg.Window = 30 * time.Second
+	}
+	if cfg.HalfOpenProbes <= 0 {
+		cfg.HalfOpenProbes = 1
+	}
+	return &Breaker{name: name, cfg: cfg, now: time.Now}
+}
+
+// Ticket — разрешение на вызов: состояние выключателя, в котором вызов был разрешён.
+type Ticket struct {
+	generation uint64
+	probe      bool
+}
+
+// Allow разрешает или отклоняет вызов. Каждый разрешённый вызов
+// должен завершиться вызовом Record или Cancel с полученным Ticket.
+func (b *Breaker) Allow() (Ticket, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if b.state == Open {
+		if wait := b.openedAt.Add(b.cfg.Cooldown).Sub(now); wait > 0 {
+			return Ticket{}, &OpenError{Name: b.name, RetryAfter: wait}
+		}
+		b.setState(HalfOpen)
+	}
+	if b.state == HalfOpen {
+		if b.probesInFlight+b.probesPassed >= b.cfg.HalfOpenProbes {
+			return Ticket{}, &OpenError{Name: b.name, RetryAfter: time.Second}
+		}
+		b.probesInFlight++
+		return Ticket{generation: b.generation, probe: true}, nil
+	}
+	return Ticket{generation: b.generation}, nil
+}
+
+// Record учитывает результат разрешённого вызова. Вызов, разрешённый до смены состояния
+// (например, начатый при замкнутом выключателе и завершившийся после размыкания),
+// не влияет ни на статистику, ни на пробные вызовы.
+func (b *Breaker) Record(t Ticket, success bool, latency time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if t.generation != b.generation {
+		return
+	}
+	slow := b.cfg.SlowCall > 0 && latency > b.cfg.SlowCall
+	switch b.state {
+	case HalfOpen:
+		b.probesInFlight--
+		if !success || (slow && b.cfg.SlowCallRate > 0) {
+			b.trip()
+			return
+		}
+		b.probesPassed++
+		if b.probesPassed >= b.cfg.HalfOpenProbes {
+			b.setState(Closed)
+			b.buckets = [numBuckets]bucket{}
+		}
+	case Closed:
+		bk := b.current()
+		bk.requests++
+		if !success {
+			bk.failures++
+		}
+		if slow {
+			bk.slow++
+		}
+		if b.shouldTrip() {
+			b.trip()
+		}
+	}
+}
+
+// Cancel освобождает разрешение, если вызов не состоялся по вине клиента
+// (например, запрос был отменён) и не должен влиять на статистику.
+func (b *Breaker) Cancel(t Ticket) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if t.probe && t.generation == b.generation && b.probesInFlight > 0 {
+		b.probesInFlight--
+	}
+}
+
+// State возвращает текущее состояние.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == Open && !b.now().Before(b.openedAt.Add(b.cfg.Cooldown)) {
+		return HalfOpen
+	}
+	return b.state
+}
+
+// Snapshot — состояние выключателя для мониторинга.
+type Snapshot struct {
+	State       State      `json:"state"`
+	Requests    int        `json:"requests"`
+	Failures    int        `json:"failures"`
+	SlowCalls   int        `json:"slow_calls"`
+	FailureRate float64    `json:"failure_rate"`
+	OpenedAt    *time.Time `json:"opened_at,omitempty"`
+}
+
+// Snapshot возвращает состояние и статистику текущего окна.
+func (b *Breaker) Snapshot() Snapshot {
+	b.mu.Lock()
+	requests, failures, slow := b.totals()
+	s := Snapshot{
+		State:     b.state,
+		Requests:  requests,
+		Failures:  failures,
+		SlowCalls: slow,
+	}
+	if b.state != Closed {
+		t := b.openedAt
+		s.OpenedAt = &t
+	}
+	if b.state == Open && !b.now().Before(b.openedAt.Add(b.cfg.Cooldown)) {
+		s.State = HalfOpen
+	}
+	b.mu.Unlock()
+
+	if requests > 0 {
+		s.FailureRate = float64(failures) / float64(requests)
+	}
+	return s
+}
+
+func (b *Breaker) trip() {
+	b.setState(Open)
+	b.openedAt = b.now()
+}
+
+// setState переходит в состояние s и начинает новое поколение разрешений.
+func (b *Breaker) setState(s State) {
+	b.state = s
+	b.generation++
+	b.probesInFlight, b.probesPassed = 0, 0
+}
+
+func (b *Breaker) shouldTrip() bool {
+	requests, failures, slow := b.totals()
+	if requests == 0 || requests < b.cfg.MinRequests {
+		return false
+	}
+	if b.cfg.FailureRate > 0 && float64(failures)/float64(requests) >= b.cfg.FailureRate {
+		return true
+	}
+	if b.cfg.SlowCallRate > 0 && b.cfg.SlowCall > 0 && float64(slow)/float64(requests) >= b.cfg.SlowCallRate {
+		return true
+	}
+	return false
+}
+
+// current возвращает интервал окна для текущего момента, сбрасывая устаревший.
+func (b *Breaker) current() *bucket {
+	width := b.cfg.Window / numBuckets
+	now := b.now()
+	start := now.Truncate(width)
+	bk := &b.buckets[(start.UnixNano()/int64(width))%numBuckets]
+	if !bk.start.Equal(start) {
+		*bk = bucket{start: start}
+	}
+	return bk
+}
+
+// totals суммирует интервалы, попадающие в окно.
+func (b *Breaker) totals() (requests, failures, slow int) {
+	cutoff := b.now().Add(-b.cfg.Window)
+	for _, bk := range b.buckets {
+		if bk.start.After(cutoff) {
+			requests += bk.requests
+			failures += bk.failures
+			slow += bk.slow
+		}
+	}
+	return requests, failures, slow
+}
@@ -0,0 +1,134 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestBreaker(cfg Config) (*Breaker, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	b := New("svc", cfg)
+	b.now = clock.now
+	return b, clock
+}
+
+func call(t *testing.T, b *Breaker, ok bool, latency time.Duration) {
+	t.Helper()
+	ticket, err := b.Allow()
+	if err != nil {
+		t.Fatalf("call unexpectedly rejected: %v", err)
+	}
+	b.Record(ticket, ok, latency)
+}
+
+func TestBreakerTripsOnFailureRate(t *testing.T) {
+	b, clock := newTestBreaker(Config{MinRequests: 4, FailureRate: 0.5, Cooldown: 10 * time.Second})
+
+	call(t, b, true, 0)
+	call(t, b, false, 0)
+	call(t, b, true, 0)
+	if b.State() != Closed {
+		t.Fatal("breaker must stay closed below MinRequests")
+	}
+	call(t, b, false, 0)
+	if b.State() != Open {
+		t.Fatalf("expected open at 50%% failures, got %s", b.State())
+	}
+
+	var open *OpenError
+	if _, err := b.Allow(); !errors.As(err, &open) || open.RetryAfter != 10*time.Second {
+		t.Fatalf("expected OpenError with 10s retry, got %v", err)
+	}
+
+	clock.advance(10 * time.Second)
+	if b.State() != HalfOpen {
+		t.Fatalf("expected half-open after cooldown, got %s", b.State())
+	}
+	call(t, b, true, 0)
+	if b.State() != Closed {
+		t.Fatalf("expected closed after successful probe, got %s", b.State())
+	}
+}
+
+func TestBreakerHalfOpenFailureReopens(t *testing.T) {
+	b, clock := newTestBreaker(Config{MinRequests: 1, FailureRate: 0.5, Cooldown: time.Second, HalfOpenProbes: 2})
+	call(t, b, false, 0)
+	clock.advance(time.Second)
+
+	first, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Allow(); err == nil {
+		t.Fatal("only HalfOpenProbes calls may pass in half-open state")
+	}
+	b.Record(first, true, 0)
+	b.Record(second, false, 0)
+	if b.State() != Open {
+		t.Fatalf("failed probe must reopen the breaker, got %s", b.State())
+	}
+}
+
+func TestBreakerIgnoresCallsFromPreviousState(t *testing.T) {
+	b, clock := newTestBreaker(Config{MinRequests: 1, FailureRate: 0.5, Cooldown: time.Second, HalfOpenProbes: 1})
+	late, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	call(t, b, false, 0)
+	clock.advance(time.Second)
+
+	probe, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Вызов, начатый до размыкания, не освобождает место пробного вызова.
+	b.Record(late, true, 0)
+	if _, err := b.Allow(); err == nil {
+		t.Fatal("call admitted while closed must not free a probe slot")
+	}
+	b.Cancel(late)
+	if _, err := b.Allow(); err == nil {
+		t.Fatal("cancelled call admitted while closed must not free a probe slot")
+	}
+	if b.State() != HalfOpen {
+		t.Fatalf("stale call must not close the breaker, got %s", b.State())
+	}
+	b.Record(probe, true, 0)
+	if b.State() != Closed {
+		t.Fatalf("expected closed after successful probe, got %s", b.State())
+	}
+}
+
+func TestBreakerTripsOnSlowCalls(t *testing.T) {
+	b, _ := newTestBreaker(Config{MinRequests: 2, SlowCall: time.Second, SlowCallRate: 1, Cooldown: time.Minute})
+	call(t, b, true, 2*time.Second)
+	call(t, b, true, 3*time.Second)
+	if b.State() != Open {
+		t.Fatalf("expected open after slow calls, got %s", b.State())
+	}
+}
+
+func TestBreakerWindowForgetsOldFailures(t *testing.T) {
+	b, clock := newTestBreaker(Config{Window: 10 * time.Second, MinRequests: 3, FailureRate: 0.5, Cooldown: time.Minute})
+	call(t, b, false, 0)
+	call(t, b, false, 0)
+	clock.advance(11 * time.Second)
+	call(t, b, false, 0)
+	if b.State() != Closed {
+		t.Fatal("failures outside the window must not count")
+	}
+	if s := b.Snapshot(); s.Requests != 1 || s.Failures != 1 {
+		t.Errorf("unexpected snapshot %+v", s)
+	}
+}
@@ -0,0 +1,254 @@
+// Package cache — HTTP-кеш ответов шлюза: LRU в памяти, учёт Cache-Control и ETag upstream,
+// объединение одновременных промахов в один запрос к upstream.
+package cache
+
+import (
+	"APIGateway/pkg/metrics"
+	"APIGateway/pkg/tracing"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Значения заголовка X-Cache.
+const (
+	Hit         = "HIT"
+	Miss        = "MISS"
+	Revalidated = "REVALIDATED"
+)
+
+var requestsTotal = metrics.NewCounter("gateway_cache_requests_total",
+	"Cached route requests by result: HIT, MISS or REVALIDATED.", "result")
+
+// perRequestHeaders — заголовки ответа, которые относятся к одному запросу или соединению
+// и не должны попадать в ответы другим клиентам.
+var perRequestHeaders = []string{
+	"X-Request-ID", tracing.TraceparentHeader, tracing.TracestateHeader, "Date",
+	"Connection", "Keep-Alive", "Proxy-Connection", "Proxy-Authenticate", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// Cache кеширует ответы на GET-запросы маршрутов.
+type Cache struct {
+	store *LRU
+	group singleflight.Group
+	now   func() time.Time
+}
+
+// New создаёт кеш, занимающий не больше maxBytes.
+func New(maxBytes int64) *Cache {
+	return &Cache{store: NewLRU(maxBytes), now: time.Now}
+}
+
+// Purge удаляет записи, путь которых начинается с prefix; пустой prefix очищает кеш.
+func (c *Cache) Purge(prefix string) int {
+	return c.store.Purge(prefix)
+}
+
+// Stats возвращает число записей и их суммарный размер.
+func (c *Cache) Stats() (entries int, bytes int64) {
+	return c.store.Stats()
+}
+
+// Key — ключ записи: путь, упорядоченные параметры запроса и Accept-Encoding,
+// от которого зависит тело ответа upstream.
+func Key(r *http.Request) string {
+	return r.URL.Path + "?" + r.URL.Query().Encode() + "|" + r.Header.Get("Accept-Encoding")
+}
+
+// result — ответ, полученный одним запросом для всех ожидающих.
+type result struct {
+	entry *Entry
+	state string
+}
+
+// Handler кеширует успешные ответы next на ttl, если upstream не задал срок в Cache-Control.
+// Свежая запись отдаётся без обращения к next; устаревшая с ETag перепроверяется условным запросом.
+// Одновременные промахи по одному ключу выполняются одним вызовом next.
+func (c *Cache) Handler(ttl time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next(w, r)
+			return
+		}
+		key := Key(r)
+		stale, ok := c.store.Get(key)
+		if ok && c.now().Before(stale.Expires) && !directives(r.Header.Get("Cache-Control")).has("no-cache") {
+			c.serve(w, r, stale, Hit)
+			return
+		}
+
+		v, _, _ := c.group.Do(key, func() (interface{}, error) {
+			return c.fetch(r, key, stale, ttl, next), nil
+		})
+		res := v.(*result)
+		c.serve(w, r, res.entry, res.state)
+	}
+}
+
+// fetch вызывает next и сохраняет ответ, если его можно кешировать. Запрос не зависит
+// от отмены запроса клиента: его результат получат и другие ожидающие.
+func (c *Cache) fetch(r *http.Request, key string, stale *Entry, ttl time.Duration, next http.HandlerFunc) *result {
+	req := r.Clone(context.WithoutCancel(r.Context()))
+	// Условные заголовки клиента относятся к его копии ответа, а не к общей записи кеша.
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+	if stale != nil && stale.ETag != "" {
+		req.Header.Set("If-None-Match", stale.ETag)
+	}
+
+	rec := &recorder{header: http.Header{}, status: http.StatusOK}
+	next(rec, req)
+	now := c.now()
+
+	if rec.status == http.StatusNotModified && stale != nil {
+		fresh := *stale
+		fresh.Stored = now
+		if d, ok := lifetime(rec.header, ttl); ok {
+			fresh.Expires = now.Add(d)
+			c.store.Set(key, &fresh)
+		}
+		return &result{entry: &fresh, state: Revalidated}
+	}
+
+	for _, k := range perRequestHeaders {
+		rec.header.Del(k)
+	}
+	e := &Entry{
+		Status:       rec.status,
+		Header:       rec.header,
+		Body:         rec.body.Bytes(),
+		ETag:         rec.header.Get("ETag"),
+		LastModified: rec.header.Get("Last-Modified"),
+		Stored:       now,
+	}
+	if d, ok := storable(e, ttl); ok {
+		if e.ETag == "" {
+			sum := sha256.Sum256(e.Body)
+			e.ETag = `"` + hex.EncodeToString(sum[:16]) + `"`
+		}
+		e.Expires = now.Add(d)
+		c.store.Set(key, e)
+	}
+	return &result{entry: e, state: Miss}
+}
+
+// serve отдаёт запись клиенту с учётом его условных заголовков.
+func (c *Cache) serve(w http.ResponseWriter, r *http.Request, e *Entry, state string) {
+	h := w.Header()
+	for k, vv := range e.Header {
+		h[k] = append([]string(nil), vv...)
+	}
+	if e.ETag != "" {
+		h.Set("ETag", e.ETag)
+	}
+	h.Set("X-Cache", state)
+	requestsTotal.Inc(state)
+	if !e.Expires.IsZero() {
+		h.Set("Age", strconv.Itoa(int(c.now().Sub(e.Stored).Seconds())))
+	}
+	if e.Status == http.StatusOK && notModified(r, e) {
+		h.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(e.Status)
+	w.Write(e.Body)
+}
+
+// storable решает, можно ли сохранить ответ, и на какой срок.
+func storable(e *Entry, ttl time.Duration) (time.Duration, bool) {
+	if e.Status != http.StatusOK || e.Header.Get("Set-Cookie") != "" {
+		return 0, false
+	}
+	for _, v := range strings.Split(e.Header.Get("Vary"), ",") {
+		if v = strings.TrimSpace(v); v != "" && !strings.EqualFold(v, "Accept-Encoding") {
+			return 0, false
+		}
+	}
+	return lifetime(e.Header, ttl)
+}
+
+// lifetime — срок хранения: s-maxage или max-age upstream, иначе ttl маршрута.
+// no-store, no-cache и private запрещают хранить ответ в общем кеше.
+func lifetime(h http.Header, ttl time.Duration) (time.Duration, bool) {
+	cc := directives(h.Get("Cache-Control"))
+	if cc.has("no-store") || cc.has("no-cache") || cc.has("private") {
+		return 0, false
+	}
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if v, ok := cc[name]; ok {
+			sec, err := strconv.Atoi(v)
+			if err != nil || sec <= 0 {
+				return 0, false
+			}
+			return time.Duration(sec) * time.Second, true
+		}
+	}
+	return ttl, ttl > 0
+}
+
+// notModified проверяет If-None-Match, а без него — If-Modified-Since.
+func notModified(r *http.Request, e *Entry) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(e.ETag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || e.LastModified == "" {
+		return false
+	}
+	lm, err := http.ParseTime(e.LastModified)
+	return err == nil && !lm.After(ims)
+}
+
+// cacheControl — директивы Cache-Control; у директив без значения значение пустое.
+type cacheControl map[string]string
+
+func directives(h string) cacheControl {
+	cc := cacheControl{}
+	for _, part := range strings.Split(h, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			cc[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// recorder запоминает ответ обработчика.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	wrote  bool
+}
+
+func (r *recorder) Header() http.Header { return r.header }
+
+func (r *recorder) WriteHeader(code int) {
+	if !r.wrote {
+		r.status, r.wrote = code, true
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wrote = true
+	return r.body.Write(b)
+}
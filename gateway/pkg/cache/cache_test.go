@@ -0,0 +1,223 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLRUEviction(t *testing.T) {
+	c := NewLRU(100)
+	c.Set("a", &Entry{Body: make([]byte, 40)})
+	c.Set("b", &Entry{Body: make([]byte, 40)})
+	c.Get("a")
+	c.Set("c", &Entry{Body: make([]byte, 40)})
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used entry must be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("recently used entry must be kept")
+	}
+	c.Set("big", &Entry{Body: make([]byte, 200)})
+	if n, size := c.Stats(); n != 2 || size > 100 {
+		t.Errorf("entry larger than the cache must not be stored: %d entries, %d bytes", n, size)
+	}
+}
+
+// origin — upstream, считающий вызовы.
+type origin struct {
+	calls atomic.Int32
+	h     http.HandlerFunc
+}
+
+func (o *origin) serve(w http.ResponseWriter, r *http.Request) {
+	o.calls.Add(1)
+	o.h(w, r)
+}
+
+func get(h http.HandlerFunc, target string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h(w, req)
+	return w
+}
+
+func TestHandlerHitAndExpiry(t *testing.T) {
+	c := New(1 << 20)
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+	o := &origin{h: func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("news")) }}
+	h := c.Handler(time.Minute, o.serve)
+
+	if w := get(h, "/news?b=2&a=1"); w.Header().Get("X-Cache") != Miss || w.Body.String() != "news" {
+		t.Fatalf("first request: X-Cache %q, body %q", w.Header().Get("X-Cache"), w.Body)
+	}
+	w := get(h, "/news?a=1&b=2")
+	if w.Header().Get("X-Cache") != Hit || w.Body.String() != "news" || o.calls.Load() != 1 {
+		t.Fatalf("same query in other order must hit: X-Cache %q, calls %d", w.Header().Get("X-Cache"), o.calls.Load())
+	}
+	if get(h, "/news?a=1&b=2", "Cache-Control", "no-cache"); o.calls.Load() != 2 {
+		t.Error("no-cache request must go upstream")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if get(h, "/news?a=1&b=2"); o.calls.Load() != 3 {
+		t.Error("expired entry must be fetched again")
+	}
+}
+
+func TestHandlerRevalidation(t *testing.T) {
+	c := New(1 << 20)
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+	o := &origin{h: func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "max-age=10")
+		w.Write([]byte("news"))
+	}}
+	h := c.Handler(time.Hour, o.serve)
+
+	get(h, "/news")
+	now = now.Add(11 * time.Second)
+	w := get(h, "/news")
+	if w.Header().Get("X-Cache") != Revalidated || w.Body.String() != "news" || o.calls.Load() != 2 {
+		t.Fatalf("stale entry: X-Cache %q, body %q, calls %d", w.Header().Get("X-Cache"), w.Body, o.calls.Load())
+	}
+	if w := get(h, "/news"); w.Header().Get("X-Cache") != Hit {
+		t.Errorf("revalidated entry must be fresh again, got X-Cache %q", w.Header().Get("X-Cache"))
+	}
+
+	w = get(h, "/news", "If-None-Match", `W/"v1"`)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("matching If-None-Match: got %d with %d bytes", w.Code, w.Body.Len())
+	}
+	if w := get(h, "/news", "If-None-Match", `"v0"`); w.Code != http.StatusOK {
+		t.Errorf("other ETag: got %d", w.Code)
+	}
+}
+
+func TestHandlerNotStored(t *testing.T) {
+	for name, f := range map[string]http.HandlerFunc{
+		"no-store": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "no-store")
+		},
+		"private": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		},
+		"set-cookie": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Set-Cookie", "session=1")
+		},
+		"vary": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Vary", "Authorization")
+		},
+		"error": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			o := &origin{h: f}
+			h := New(1<<20).Handler(time.Minute, o.serve)
+			get(h, "/news")
+			get(h, "/news")
+			if o.calls.Load() != 2 {
+				t.Errorf("response must not be cached, upstream calls: %d", o.calls.Load())
+			}
+		})
+	}
+}
+
+func TestHandlerCoalescesMisses(t *testing.T) {
+	release := make(chan struct{})
+	o := &origin{h: func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("news"))
+	}}
+	h := New(1<<20).Handler(time.Minute, o.serve)
+
+	const clients = 10
+	var started, wg sync.WaitGroup
+	bodies := make([]string, clients)
+	for i := 0; i < clients; i++ {
+		started.Add(1)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			started.Done()
+			bodies[i] = get(h, "/news").Body.String()
+		}(i)
+	}
+	started.Wait()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if o.calls.Load() != 1 {
+		t.Errorf("concurrent misses must make one upstream call, got %d", o.calls.Load())
+	}
+	for i, b := range bodies {
+		if b != "news" {
+			t.Errorf("client %d: body %q", i, b)
+		}
+	}
+}
+
+func TestHandlerDropsPerRequestHeaders(t *testing.T) {
+	o := &origin{h: func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", r.Header.Get("X-Request-ID"))
+		w.Header().Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("news"))
+	}}
+	h := New(1<<20).Handler(time.Minute, o.serve)
+
+	// Как и middl.Middle, выставляет X-Request-ID ответа до вызова обработчика.
+	serve := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/news", nil)
+		req.Header.Set("X-Request-ID", id)
+		w := httptest.NewRecorder()
+		w.Header().Set("X-Request-ID", id)
+		h(w, req)
+		return w
+	}
+	serve("first")
+	w := serve("second")
+	if w.Header().Get("X-Cache") != Hit {
+		t.Fatalf("expected a cache hit, got %q", w.Header().Get("X-Cache"))
+	}
+	if got := w.Header().Values("X-Request-ID"); len(got) != 1 || got[0] != "second" {
+		t.Errorf("X-Request-ID of the cached request must not be replayed: %q", got)
+	}
+	if got := w.Header().Get("traceparent"); got != "" {
+		t.Errorf("traceparent must not be stored: %q", got)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type must be kept: %q", got)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	c := New(1 << 20)
+	o := &origin{h: func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("x")) }}
+	h := c.Handler(time.Minute, o.serve)
+	for _, target := range []string{"/news/1", "/news/1?view=tree", "/news/12", "/news/latest"} {
+		get(h, target)
+	}
+
+	if n := c.Purge("/news/1?"); n != 2 {
+		t.Errorf("purge of one news: %d entries", n)
+	}
+	if n := c.Purge(""); n != 2 {
+		t.Errorf("purge of everything: %d entries", n)
+	}
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry — сохранённый ответ.
+type Entry struct {
+	Status       int
+	Header       http.Header
+	Body         []byte
+	ETag         string
+	LastModified string
+	Stored       time.Time
+	Expires      time.Time
+}
+
+// size — примерный объём записи в памяти.
+func (e *Entry) size() int64 {
+	n := int64(len(e.Body)) + int64(len(e.ETag)+len(e.LastModified))
+	for k, vv := range e.Header {
+		n += int64(len(k))
+		for _, v := range vv {
+			n += int64(len(v))
+		}
+	}
+	return n
+}
+
+type item struct {
+	key   string
+	entry *Entry
+	size  int64
+}
+
+// LRU — хранилище записей, ограниченное суммарным размером; при переполнении
+// вытесняются давно не использованные записи.
+type LRU struct {
+	maxBytes int64
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	bytes int64
+}
+
+func NewLRU(maxBytes int64) *LRU {
+	return &LRU{maxBytes: maxBytes, ll: list.New(), items: map[string]*list.Element{}}
+}
+
+func (c *LRU) Get(key string) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*item).entry, true
+}
+
+// Set сохраняет запись; запись больше всего хранилища не сохраняется.
+func (c *LRU) Set(key string, e *Entry) {
+	size := e.size() + int64(len(key))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	if size > c.maxBytes {
+		return
+	}
+	c.items[key] = c.ll.PushFront(&item{key: key, entry: e, size: size})
+	c.bytes += size
+	for c.bytes > c.maxBytes {
+		c.remove(c.ll.Back())
+	}
+}
+
+// Purge удаляет записи, ключ которых начинается с prefix, и возвращает их число.
+func (c *LRU) Purge(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for key, el := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(el)
+			n++
+		}
+	}
+	return n
+}
+
+// Stats возвращает число записей и их суммарный размер.
+func (c *LRU) Stats() (entries int, bytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items), c.bytes
+}
+
+func (c *LRU) remove(el *list.Element) {
+	it := c.ll.Remove(el).(*item)
+	delete(c.items, it.key)
+	c.bytes -= it.size
+}
@@ -0,0 +1,154 @@
+// Package coalesce объединяет одинаковые одновременные GET-запросы к upstream:
+// пока выполняется первый запрос, остальные ждут его ответ и не обращаются к upstream.
+// В отличие от кеша, ответ не хранится после завершения запроса.
+package coalesce
+
+import (
+	"APIGateway/pkg/metrics"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultVary — заголовки запроса, от которых может зависеть ответ upstream.
+// Запросы с разными значениями этих заголовков не объединяются: в частности, ответ 304
+// на условный запрос нельзя отдавать тому, кто запросил ответ без условий.
+var DefaultVary = []string{
+	"Accept", "Accept-Encoding", "Accept-Language", "Authorization", "Cookie", "Range",
+	"If-None-Match", "If-Modified-Since", "X-User-ID", "X-User-Roles",
+}
+
+var (
+	callsTotal = metrics.NewCounter("gateway_coalesce_calls_total",
+		"Upstream calls made on behalf of coalesced requests.", "upstream")
+	coalescedTotal = metrics.NewCounter("gateway_coalesced_requests_total",
+		"Requests served by another in-flight upstream call.", "upstream")
+)
+
+// Stats — счётчики группы: Calls — выполненные запросы к upstream,
+// Coalesced — запросы, получившие чужой ответ вместо своего вызова.
+type Stats struct {
+	Calls     int64 `json:"calls"`
+	Coalesced int64 `json:"coalesced"`
+}
+
+// Group объединяет запросы к одному upstream.
+type Group struct {
+	name string
+	vary []string
+
+	sf        singleflight.Group
+	calls     atomic.Int64
+	coalesced atomic.Int64
+}
+
+// New создаёт группу для upstream name; vary — заголовки, входящие в ключ запроса (nil — DefaultVary).
+func New(name string, vary []string) *Group {
+	if vary == nil {
+		vary = DefaultVary
+	}
+	return &Group{name: name, vary: vary}
+}
+
+// Stats возвращает значения счётчиков.
+func (g *Group) Stats() Stats {
+	return Stats{Calls: g.calls.Load(), Coalesced: g.coalesced.Load()}
+}
+
+// response — ответ upstream, прочитанный целиком, чтобы его можно было отдать всем ожидающим.
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// Do выполняет запрос через fn. GET и HEAD без тела, совпадающие по ключу с уже выполняющимся
+// запросом, ждут его результат. Запрос выполняется без отмены по контексту первого клиента
+// (но с его дедлайном), а каждый ожидающий перестаёт ждать при отмене своего контекста.
+func (g *Group) Do(req *http.Request, fn func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	if !coalescable(req) {
+		return fn(req)
+	}
+	leader := false
+	ch := g.sf.DoChan(g.key(req), func() (interface{}, error) {
+		leader = true
+		g.calls.Add(1)
+		callsTotal.Inc(g.name)
+		return g.call(req, fn)
+	})
+	select {
+	case res := <-ch:
+		if !leader {
+			g.coalesced.Add(1)
+			coalescedTotal.Inc(g.name)
+		}
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*response).toHTTP(req), nil
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+}
+
+func (g *Group) call(req *http.Request, fn func(*http.Request) (*http.Response, error)) (*response, error) {
+	ctx := context.WithoutCancel(req.Context())
+	if deadline, ok := req.Context().Deadline(); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	resp, err := fn(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &response{status: resp.StatusCode, header: resp.Header, body: body}, nil
+}
+
+// toHTTP создаёт для ожидающего собственную копию ответа.
+func (r *response) toHTTP(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(r.status) + " " + http.StatusText(r.status),
+		StatusCode:    r.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(r.body)),
+		ContentLength: int64(len(r.body)),
+		Request:       req,
+	}
+}
+
+func coalescable(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody
+}
+
+// key — метод, адрес и значения заголовков vary.
+func (g *Group) key(req *http.Request) string {
+	var b strings.Builder
+	b.WriteString(req.Method)
+	b.WriteByte(' ')
+	b.WriteString(req.URL.String())
+	for _, name := range g.vary {
+		b.WriteByte('\n')
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(req.Header.Values(name), ","))
+	}
+	return b.String()
+}
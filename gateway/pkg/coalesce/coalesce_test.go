@@ -0,0 +1,157 @@
+package coalesce
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowUpstream отвечает телом "ok", когда закрыт release, и считает вызовы.
+type slowUpstream struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (u *slowUpstream) do(req *http.Request) (*http.Response, error) {
+	u.calls.Add(1)
+	<-u.release
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader("ok")),
+	}, nil
+}
+
+// concurrently выполняет запросы одновременно и возвращает тела ответов.
+func concurrently(g *Group, u *slowUpstream, reqs []*http.Request) []string {
+	bodies := make([]string, len(reqs))
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		wg.Add(1)
+		go func(i int, req *http.Request) {
+			defer wg.Done()
+			resp, err := g.Do(req, u.do)
+			if err != nil {
+				bodies[i] = err.Error()
+				return
+			}
+			b, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			bodies[i] = string(b)
+		}(i, req)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(u.release)
+	wg.Wait()
+	return bodies
+}
+
+func TestCoalesceIdenticalRequests(t *testing.T) {
+	g := New("news", nil)
+	u := &slowUpstream{release: make(chan struct{})}
+	var reqs []*http.Request
+	for i := 0; i < 5; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "/news/1", nil)
+		reqs = append(reqs, req)
+	}
+
+	for i, body := range concurrently(g, u, reqs) {
+		if body != "ok" {
+			t.Errorf("waiter %d: body %q", i, body)
+		}
+	}
+	if u.calls.Load() != 1 {
+		t.Errorf("expected one upstream call, got %d", u.calls.Load())
+	}
+	if st := g.Stats(); st.Calls != 1 || st.Coalesced != 4 {
+		t.Errorf("stats: %+v", st)
+	}
+}
+
+func TestCoalesceKey(t *testing.T) {
+	g := New("news", nil)
+	u := &slowUpstream{release: make(chan struct{})}
+	get := func(target, user string) *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-User-ID", user)
+		req.Header.Set("X-Request-ID", target+user)
+		return req
+	}
+	post, _ := http.NewRequest(http.MethodPost, "/news/1", strings.NewReader("{}"))
+	reqs := []*http.Request{
+		get("/news/1", "alice"), get("/news/1", "alice"),
+		get("/news/1", "bob"), get("/news/1?page=2", "alice"), post,
+	}
+
+	concurrently(g, u, reqs)
+	// Одинаковые запросы alice объединяются, несмотря на разные X-Request-ID;
+	// другой пользователь, другой адрес и POST выполняются отдельно.
+	if u.calls.Load() != 4 {
+		t.Errorf("expected 4 upstream calls, got %d", u.calls.Load())
+	}
+}
+
+func TestCoalesceConditionalLeader(t *testing.T) {
+	g := New("news", nil)
+	release := make(chan struct{})
+	var calls atomic.Int32
+	do := func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		<-release
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			return &http.Response{StatusCode: http.StatusNotModified, Header: http.Header{}, Body: http.NoBody}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+	}
+
+	conditional, _ := http.NewRequest(http.MethodGet, "/news/1", nil)
+	conditional.Header.Set("If-None-Match", `"v1"`)
+	plain, _ := http.NewRequest(http.MethodGet, "/news/1", nil)
+
+	codes := make([]int, 2)
+	var wg sync.WaitGroup
+	for i, req := range []*http.Request{conditional, plain} {
+		wg.Add(1)
+		go func(i int, req *http.Request) {
+			defer wg.Done()
+			if resp, err := g.Do(req, do); err == nil {
+				codes[i] = resp.StatusCode
+				resp.Body.Close()
+			}
+		}(i, req)
+		// Условный запрос становится ведущим, простой приходит, пока он выполняется.
+		time.Sleep(20 * time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if codes[0] != http.StatusNotModified || codes[1] != http.StatusOK {
+		t.Errorf("conditional and plain requests must not share a response, got %v", codes)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 upstream calls, got %d", calls.Load())
+	}
+}
+
+func TestCoalesceWaiterCancel(t *testing.T) {
+	g := New("news", nil)
+	u := &slowUpstream{release: make(chan struct{})}
+	defer close(u.release)
+
+	leader, _ := http.NewRequest(http.MethodGet, "/news/1", nil)
+	go g.Do(leader, u.do)
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	waiter, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/news/1", nil)
+	if _, err := g.Do(waiter, u.do); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("cancelled waiter must stop waiting, got %v", err)
+	}
+}
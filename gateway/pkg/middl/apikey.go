@@ -0,0 +1,314 @@
+package middl
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// APIKeyHeader — заголовок, в котором клиент передаёт API-ключ.
+const APIKeyHeader = "X-API-Key"
+
+// RoleAdmin — роль, которой доступны административные эндпоинты шлюза.
+const RoleAdmin = "admin"
+
+// APIKeyIdentityPrefix отделяет личности клиентов API-ключей от пользователей JWT,
+// чтобы клиент не совпал по ID с пользователем и не получил его права на комментарии.
+const APIKeyIdentityPrefix = "apikey:"
+
+const clientKey = contextKey("client")
+
+// ErrUnknownClient возвращается при ротации ключа клиента, у которого нет действующих ключей.
+var ErrUnknownClient = errors.New("unknown client")
+
+// APIKey — ключ клиента шлюза. Хранится только SHA-256 ключа, сам ключ показывается один раз при выдаче.
+type APIKey struct {
+	ID     string   `json:"id"`
+	Client string   `json:"client"`
+	Hash   string   `json:"hash"`
+	Roles  []string `json:"roles,omitempty"`
+	// PerMinute и PerDay — квоты запросов клиента; 0 — без ограничения.
+	PerMinute int       `json:"per_minute,omitempty"`
+	PerDay    int       `json:"per_day,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt — конец действия ключа; при ротации старый ключ действует ещё период перекрытия.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Active сообщает, действует ли ключ в момент now.
+func (k APIKey) Active(now time.Time) bool {
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// KeyStore — хранилище API-ключей.
+type KeyStore interface {
+	// Keys возвращает все ключи, включая истёкшие.
+	Keys(ctx context.Context) ([]APIKey, error)
+	// Save добавляет ключ.
+	Save(ctx context.Context, k APIKey) error
+	// Expire ограничивает срок действия ключей клиента моментом at, кроме ключа keepID.
+	Expire(ctx context.Context, client string, at time.Time, keepID string) error
+}
+
+// HashKey возвращает хеш ключа в том виде, в котором он хранится.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateKey создаёт ключ вида gw_<id>_<секрет> и его ID.
+func GenerateKey() (id, key string, err error) {
+	b := make([]byte, 38)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	id = hex.EncodeToString(b[:6])
+	return id, "gw_" + id + "_" + base64.RawURLEncoding.EncodeToString(b[6:]), nil
+}
+
+// ClientUsage — счётчики запросов клиента.
+type ClientUsage struct {
+	Client    string    `json:"client"`
+	Minute    int       `json:"minute"`
+	PerMinute int       `json:"per_minute,omitempty"`
+	Day       int       `json:"day"`
+	PerDay    int       `json:"per_day,omitempty"`
+	Total     int64     `json:"total"`
+	Rejected  int64     `json:"rejected"`
+	LastUsed  time.Time `json:"last_used"`
+}
+
+// usage — счётчики клиента в текущих минутном и суточном окнах.
+type usage struct {
+	minuteStart, dayStart time.Time
+	minute, day           int
+	total, rejected       int64
+	lastUsed              time.Time
+}
+
+// APIKeys аутентифицирует клиентов по API-ключам и следит за их квотами.
+// Счётчики квот хранятся в памяти каждого экземпляра шлюза.
+type APIKeys struct {
+	store    KeyStore
+	required bool
+	now      func() time.Time
+
+	mu     sync.RWMutex
+	byHash map[string]APIKey
+
+	usageMu sync.Mutex
+	usage   map[string]*usage
+}
+
+// NewAPIKeys создаёт проверку ключей. required=true отклоняет запросы без ключа.
+func NewAPIKeys(store KeyStore, required bool) *APIKeys {
+	return &APIKeys{
+		store:    store,
+		required: required,
+		now:      time.Now,
+		byHash:   map[string]APIKey{},
+		usage:    map[string]*usage{},
+	}
+}
+
+// Reload перечитывает ключи из хранилища.
+func (k *APIKeys) Reload(ctx context.Context) error {
+	keys, err := k.store.Keys(ctx)
+	if err != nil {
+		return err
+	}
+	byHash := make(map[string]APIKey, len(keys))
+	for _, key := range keys {
+		byHash[key.Hash] = key
+	}
+	k.mu.Lock()
+	k.byHash = byHash
+	k.mu.Unlock()
+	return nil
+}
+
+// Run перечитывает ключи каждые interval, чтобы ключи, выданные другим экземпляром, начали действовать.
+func (k *APIKeys) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.Reload(ctx); err != nil {
+				slog.ErrorContext(ctx, "failed to reload API keys", "err", err)
+			}
+		}
+	}
+}
+
+// Create выдаёт клиенту новый ключ с ролями и квотами из spec и возвращает его единственный раз.
+func (k *APIKeys) Create(ctx context.Context, spec APIKey) (string, APIKey, error) {
+	id, plain, err := GenerateKey()
+	if err != nil {
+		return "", APIKey{}, err
+	}
+	key := APIKey{
+		ID: id, Client: spec.Client, Hash: HashKey(plain), Roles: spec.Roles,
+		PerMinute: spec.PerMinute, PerDay: spec.PerDay, CreatedAt: k.now().UTC(),
+	}
+	if err := k.store.Save(ctx, key); err != nil {
+		return "", APIKey{}, err
+	}
+	return plain, key, k.Reload(ctx)
+}
+
+// Rotate выдаёт клиенту новый ключ с настройками его последнего действующего ключа.
+// Прежние ключи продолжают действовать ещё overlap, чтобы клиент успел перейти на новый.
+func (k *APIKeys) Rotate(ctx context.Context, client string, overlap time.Duration) (string, APIKey, error) {
+	now := k.now()
+	var latest *APIKey
+	k.mu.RLock()
+	for _, key := range k.byHash {
+		if key.Client == client && key.Active(now) && (latest == nil || key.CreatedAt.After(latest.CreatedAt)) {
+			key := key
+			latest = &key
+		}
+	}
+	k.mu.RUnlock()
+	if latest == nil {
+		return "", APIKey{}, ErrUnknownClient
+	}
+
+	plain, key, err := k.Create(ctx, *latest)
+	if err != nil {
+		return "", APIKey{}, err
+	}
+	if err := k.store.Expire(ctx, client, now.Add(overlap).UTC(), key.ID); err != nil {
+		return "", APIKey{}, err
+	}
+	return plain, key, k.Reload(ctx)
+}
+
+// Usage возвращает счётчики запросов клиентов в порядке имён.
+func (k *APIKeys) Usage() []ClientUsage {
+	limits := map[string]APIKey{}
+	now := k.now()
+	k.mu.RLock()
+	for _, key := range k.byHash {
+		if prev, ok := limits[key.Client]; key.Active(now) && (!ok || key.CreatedAt.After(prev.CreatedAt)) {
+			limits[key.Client] = key
+		}
+	}
+	k.mu.RUnlock()
+
+	k.usageMu.Lock()
+	defer k.usageMu.Unlock()
+	out := make([]ClientUsage, 0, len(k.usage))
+	for client, u := range k.usage {
+		u.roll(now)
+		out = append(out, ClientUsage{
+			Client: client, Minute: u.minute, PerMinute: limits[client].PerMinute,
+			Day: u.day, PerDay: limits[client].PerDay,
+			Total: u.total, Rejected: u.rejected, LastUsed: u.lastUsed,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Client < out[j].Client })
+	return out
+}
+
+// lookup находит действующий ключ.
+func (k *APIKeys) lookup(plain string) (APIKey, bool) {
+	k.mu.RLock()
+	key, ok := k.byHash[HashKey(plain)]
+	k.mu.RUnlock()
+	if !ok || !key.Active(k.now()) {
+		return APIKey{}, false
+	}
+	return key, true
+}
+
+// roll начинает новые окна счётчиков, если прежние закончились.
+func (u *usage) roll(now time.Time) {
+	if m := now.Truncate(time.Minute); !m.Equal(u.minuteStart) {
+		u.minuteStart, u.minute = m, 0
+	}
+	if d := now.UTC().Truncate(24 * time.Hour); !d.Equal(u.dayStart) {
+		u.dayStart, u.day = d, 0
+	}
+}
+
+// allow учитывает запрос клиента и проверяет квоты ключа. При превышении возвращает,
+// через сколько откроется окно.
+func (k *APIKeys) allow(key APIKey) (time.Duration, bool) {
+	now := k.now()
+	k.usageMu.Lock()
+	defer k.usageMu.Unlock()
+	u := k.usage[key.Client]
+	if u == nil {
+		u = &usage{}
+		k.usage[key.Client] = u
+	}
+	u.roll(now)
+	u.lastUsed = now
+	switch {
+	case key.PerDay > 0 && u.day >= key.PerDay:
+		u.rejected++
+		return u.dayStart.Add(24 * time.Hour).Sub(now), false
+	case key.PerMinute > 0 && u.minute >= key.PerMinute:
+		u.rejected++
+		return u.minuteStart.Add(time.Minute).Sub(now), false
+	}
+	u.minute++
+	u.day++
+	u.total++
+	return 0, true
+}
+
+// Middleware аутентифицирует клиента по заголовку X-API-Key и применяет его квоты.
+// Клиент попадает в контекст запроса; если пользователь ещё не определён (например, по JWT),
+// личностью запроса становится клиент с ролями ключа. Сам ключ upstream не передаётся.
+func (k *APIKeys) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		plain := r.Header.Get(APIKeyHeader)
+		r.Header.Del(APIKeyHeader)
+		if plain == "" {
+			if k.required {
+				http.Error(w, APIKeyHeader+" header is required", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, ok := k.lookup(plain)
+		if !ok {
+			slog.WarnContext(r.Context(), "invalid API key")
+			http.Error(w, "invalid API key", http.StatusUnauthorized)
+			return
+		}
+		if wait, ok := k.allow(key); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), clientKey, key.Client))
+		if _, ok := GetIdentity(r.Context()); !ok {
+			r = WithIdentity(r, Identity{ID: APIKeyIdentityPrefix + key.Client, Name: key.Client, Roles: key.Roles})
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GetClient возвращает клиента, чей API-ключ предъявлен в запросе; пустая строка — ключа не было.
+func GetClient(ctx context.Context) string {
+	client, _ := ctx.Value(clientKey).(string)
+	return client
+}
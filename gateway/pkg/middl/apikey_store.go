@@ -0,0 +1,161 @@
+package middl
+
+import (
+	"APIGateway/pkg/tracing"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// FileKeyStore хранит API-ключи в JSON-файле вида {"keys": [...]}.
+type FileKeyStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileKeyStore(path string) *FileKeyStore {
+	return &FileKeyStore{path: path}
+}
+
+type keysFile struct {
+	Keys []APIKey `json:"keys"`
+}
+
+// Keys читает ключи из файла; отсутствующий файл означает, что ключей нет.
+func (s *FileKeyStore) Keys(ctx context.Context) ([]APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read()
+}
+
+func (s *FileKeyStore) Save(ctx context.Context, k APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys, err := s.read()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if key.ID == k.ID || key.Hash == k.Hash {
+			return fmt.Errorf("api key %s already exists", k.ID)
+		}
+	}
+	return s.write(append(keys, k))
+}
+
+func (s *FileKeyStore) Expire(ctx context.Context, client string, at time.Time, keepID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys, err := s.read()
+	if err != nil {
+		return err
+	}
+	for i, key := range keys {
+		if key.Client == client && key.ID != keepID && (key.ExpiresAt == nil || key.ExpiresAt.After(at)) {
+			at := at
+			keys[i].ExpiresAt = &at
+		}
+	}
+	return s.write(keys)
+}
+
+func (s *FileKeyStore) read() ([]APIKey, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read api keys: %w", err)
+	}
+	var f keysFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse api keys file %s: %w", s.path, err)
+	}
+	return f.Keys, nil
+}
+
+// write заменяет файл целиком через временный файл, чтобы его не прочитали наполовину записанным.
+func (s *FileKeyStore) write(keys []APIKey) error {
+	data, err := json.MarshalIndent(keysFile{Keys: keys}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".apikeys-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// PostgresKeyStore хранит API-ключи в таблице api_keys.
+type PostgresKeyStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresKeyStore(ctx context.Context, constr string) (*PostgresKeyStore, error) {
+	db, err := tracing.NewPgxPool(ctx, constr)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresKeyStore{db: db}, nil
+}
+
+// Stat возвращает статистику пула соединений.
+func (s *PostgresKeyStore) Stat() *pgxpool.Stat {
+	return s.db.Stat()
+}
+
+func (s *PostgresKeyStore) Keys(ctx context.Context) ([]APIKey, error) {
+	rows, err := s.db.Query(ctx, `
+    SELECT id, client, hash, roles, per_minute, per_day, created_at, expires_at
+    FROM api_keys ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		var k APIKey
+		if err := rows.Scan(&k.ID, &k.Client, &k.Hash, &k.Roles, &k.PerMinute, &k.PerDay, &k.CreatedAt, &k.ExpiresAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (s *PostgresKeyStore) Save(ctx context.Context, k APIKey) error {
+	roles := k.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	_, err := s.db.Exec(ctx, `
+    INSERT INTO api_keys (id, client, hash, roles, per_minute, per_day, created_at, expires_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		k.ID, k.Client, k.Hash, roles, k.PerMinute, k.PerDay, k.CreatedAt, k.ExpiresAt)
+	return err
+}
+
+func (s *PostgresKeyStore) Expire(ctx context.Context, client string, at time.Time, keepID string) error {
+	_, err := s.db.Exec(ctx, `
+    UPDATE api_keys SET expires_at = $2
+    WHERE client = $1 AND id <> $3 AND (expires_at IS NULL OR expires_at > $2)`, client, at, keepID)
+	return err
+}
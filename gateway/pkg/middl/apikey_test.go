@@ -0,0 +1,105 @@
+package middl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAPIKeys(t *testing.T) {
+	store := NewFileKeyStore(filepath.Join(t.TempDir(), "apikeys.json"))
+	keys := NewAPIKeys(store, true)
+	now := time.Date(2024, 5, 1, 12, 0, 30, 0, time.UTC)
+	keys.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	plain, key, err := keys.Create(ctx, APIKey{Client: "partner", Roles: []string{"reader"}, PerMinute: 2, PerDay: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Hash != HashKey(plain) || key.Hash == plain {
+		t.Fatal("only the key hash must be stored")
+	}
+
+	var gotKey, gotClient string
+	var gotID Identity
+	h := keys.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey, gotClient = r.Header.Get(APIKeyHeader), GetClient(r.Context())
+		gotID, _ = GetIdentity(r.Context())
+	}))
+	serve := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/news", nil)
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve(""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("missing key: expected 401, got %d", rr.Code)
+	}
+	if rr := serve("gw_bogus"); rr.Code != http.StatusUnauthorized {
+		t.Errorf("unknown key: expected 401, got %d", rr.Code)
+	}
+	if rr := serve(plain); rr.Code != http.StatusOK {
+		t.Fatalf("valid key: expected 200, got %d", rr.Code)
+	}
+	if gotKey != "" || gotClient != "partner" || gotID.ID != "apikey:partner" || !gotID.HasRole("reader") {
+		t.Errorf("unexpected request state: key=%q client=%q identity=%+v", gotKey, gotClient, gotID)
+	}
+
+	serve(plain)
+	rr := serve(plain)
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "30" {
+		t.Fatalf("per-minute quota: got %d, Retry-After %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	now = now.Add(time.Minute)
+	serve(plain)
+	if rr := serve(plain); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("per-day quota: expected 429, got %d", rr.Code)
+	}
+	if u := keys.Usage(); len(u) != 1 || u[0].Total != 3 || u[0].Day != 3 || u[0].Rejected != 2 || u[0].PerDay != 3 {
+		t.Errorf("unexpected usage: %+v", u)
+	}
+
+	// После ротации старый ключ действует до конца перекрытия.
+	now = now.Add(24 * time.Hour)
+	newPlain, newKey, err := keys.Rotate(ctx, "partner", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newKey.PerMinute != 2 || newKey.Client != "partner" {
+		t.Errorf("rotated key must keep client settings: %+v", newKey)
+	}
+	if rr := serve(plain); rr.Code != http.StatusOK {
+		t.Errorf("old key during overlap: expected 200, got %d", rr.Code)
+	}
+	now = now.Add(2 * time.Hour)
+	if rr := serve(plain); rr.Code != http.StatusUnauthorized {
+		t.Errorf("old key after overlap: expected 401, got %d", rr.Code)
+	}
+	if rr := serve(newPlain); rr.Code != http.StatusOK {
+		t.Errorf("new key: expected 200, got %d", rr.Code)
+	}
+
+	// Ключи переживают перезапуск: новый экземпляр читает их из файла.
+	reloaded := NewAPIKeys(store, true)
+	reloaded.now = keys.now
+	if err := reloaded.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reloaded.lookup(newPlain); !ok {
+		t.Error("rotated key is not persisted")
+	}
+	if _, ok := reloaded.lookup(plain); ok {
+		t.Error("expiry of the old key is not persisted")
+	}
+	if _, _, err := keys.Rotate(ctx, "nobody", time.Hour); err != ErrUnknownClient {
+		t.Errorf("rotate unknown client: got %v", err)
+	}
+}
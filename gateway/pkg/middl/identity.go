@@ -0,0 +1,133 @@
+package middl
+
+import (
+	"APIGateway/pkg/logging"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Заголовки с личностью пользователя, которые шлюз передаёт внутренним сервисам.
+const (
+	UserIDHeader    = "X-User-ID"
+	UserNameHeader  = "X-User-Name"
+	UserRolesHeader = "X-User-Roles"
+	// UserSignatureHeader — подпись заголовков личности: "<unix-время>.<HMAC-SHA256 в base64url>".
+	// По ней внутренние сервисы отличают заголовки шлюза от подделанных.
+	UserSignatureHeader = "X-User-Signature"
+)
+
+// RoleModerator — роль модератора комментариев.
+const RoleModerator = "moderator"
+
+const identityKey = contextKey("identity")
+
+// Identity — аутентифицированный пользователь запроса.
+type Identity struct {
+	ID    string
+	Name  string
+	Roles []string
+}
+
+// HasRole сообщает, есть ли у пользователя роль role.
+func (id Identity) HasRole(role string) bool {
+	for _, r := range id.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// SetHeader записывает личность в заголовки запроса к внутреннему сервису
+// и подписывает их секретом secret; пустой secret оставляет заголовки без подписи.
+func (id Identity) SetHeader(h http.Header, secret []byte, now time.Time) {
+	roles := strings.Join(id.Roles, ",")
+	h.Set(UserIDHeader, id.ID)
+	h.Set(UserNameHeader, id.Name)
+	h.Set(UserRolesHeader, roles)
+	if len(secret) > 0 {
+		ts := strconv.FormatInt(now.Unix(), 10)
+		h.Set(UserSignatureHeader, ts+"."+signIdentity(secret, ts, id.ID, id.Name, roles))
+	}
+}
+
+// signIdentity вычисляет HMAC заголовков личности. Сервис комментариев проверяет подпись так же.
+func signIdentity(secret []byte, ts, id, name, roles string) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", ts, id, name, roles)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// identityFromHeader читает личность из заголовков; ok=false — заголовков нет.
+func identityFromHeader(h http.Header) (id Identity, ok bool) {
+	id.ID = strings.TrimSpace(h.Get(UserIDHeader))
+	if id.ID == "" {
+		return Identity{}, false
+	}
+	id.Name = strings.TrimSpace(h.Get(UserNameHeader))
+	for _, role := range strings.Split(h.Get(UserRolesHeader), ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			id.Roles = append(id.Roles, role)
+		}
+	}
+	return id, true
+}
+
+// stripIdentity удаляет заголовки личности из запроса.
+func stripIdentity(h http.Header) {
+	h.Del(UserIDHeader)
+	h.Del(UserNameHeader)
+	h.Del(UserRolesHeader)
+	h.Del(UserSignatureHeader)
+}
+
+// WithIdentity кладёт личность пользователя в контекст запроса; записи лога запроса получают user_id.
+func WithIdentity(r *http.Request, id Identity) *http.Request {
+	ctx := logging.With(r.Context(), slog.String(logging.UserKey, id.ID))
+	return r.WithContext(context.WithValue(ctx, identityKey, id))
+}
+
+// GetIdentity возвращает личность пользователя запроса; ok=false — запрос анонимный.
+func GetIdentity(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey).(Identity)
+	return id, ok
+}
+
+// IdentityHeaders передаёт upstream личность из контекста подписанными заголовками.
+// Ставится последним, после всех способов аутентификации; заголовки личности
+// из запроса клиента при этом всегда удаляются.
+func IdentityHeaders(secret []byte) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			stripIdentity(r.Header)
+			if id, ok := GetIdentity(r.Context()); ok {
+				id.SetHeader(r.Header, secret, time.Now())
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// TrustedIdentity не даёт клиенту представиться чужим именем: заголовки личности
+// из входящего запроса удаляются. Если trust=true, шлюз стоит за доверенным
+// аутентифицирующим прокси, и его заголовки принимаются как есть.
+func TrustedIdentity(trust bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, ok := identityFromHeader(r.Header)
+			stripIdentity(r.Header)
+			if trust && ok {
+				r = WithIdentity(r, id)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
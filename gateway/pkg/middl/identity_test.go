@@ -0,0 +1,64 @@
+package middl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrustedIdentity(t *testing.T) {
+	for _, trust := range []bool{false, true} {
+		var id Identity
+		var ok bool
+		var hdr string
+		h := TrustedIdentity(trust)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, ok = GetIdentity(r.Context())
+			hdr = r.Header.Get(UserIDHeader)
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(UserIDHeader, "u1")
+		req.Header.Set(UserRolesHeader, "moderator, editor")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		if hdr != "" {
+			t.Errorf("trust=%v: client identity header must be stripped", trust)
+		}
+		if ok != trust || (trust && (id.ID != "u1" || len(id.Roles) != 2 || !id.HasRole(RoleModerator))) {
+			t.Errorf("trust=%v: unexpected identity %+v (ok=%v)", trust, id, ok)
+		}
+	}
+}
+
+func TestIdentityHeaders(t *testing.T) {
+	secret := []byte("secret")
+	var got http.Header
+	h := IdentityHeaders(secret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+
+	// Подделанные клиентом заголовки не доходят до upstream.
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(UserIDHeader, "admin")
+	req.Header.Set(UserSignatureHeader, "1.forged")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got.Get(UserIDHeader) != "" || got.Get(UserSignatureHeader) != "" {
+		t.Errorf("forged identity is forwarded: %v", got)
+	}
+
+	req = WithIdentity(httptest.NewRequest(http.MethodGet, "/", nil), Identity{ID: "u1", Name: "Алиса", Roles: []string{"a", "b"}})
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	ts, sig, ok := strings.Cut(got.Get(UserSignatureHeader), ".")
+	if got.Get(UserIDHeader) != "u1" || got.Get(UserRolesHeader) != "a,b" || !ok {
+		t.Fatalf("unexpected identity headers: %v", got)
+	}
+	if sig != signIdentity(secret, ts, "u1", "Алиса", "a,b") {
+		t.Error("signature does not match identity headers")
+	}
+	if sec, err := strconv.ParseInt(ts, 10, 64); err != nil || time.Since(time.Unix(sec, 0)) > time.Minute {
+		t.Errorf("unexpected signature timestamp %q", ts)
+	}
+}
@@ -1,10 +1,11 @@
 package middl
 
 import (
+	"APIGateway/pkg/logging"
 	"context"
 	"github.com/gofrs/uuid"
-	"log"
 	"net/http"
+	"time"
 )
 
 type contextKey string
@@ -27,6 +28,7 @@ func (lrw *LoggingResponseWriter) WriteHeader(code int) {
 
 func Middle(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
 		reqID := req.Header.Get("X-Request-ID")
 		if reqID == "" {
 			rID, _ := uuid.NewV4()
@@ -37,13 +39,14 @@ func Middle(next http.Handler) http.Handler {
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Request-ID")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Request-ID, Authorization, X-API-Key")
+
+		req = req.WithContext(context.WithValue(logging.WithRequest(req, reqID), RequestIDKey, reqID))
 
 		lrw := NewLoggingResponseWriter(w)
 		next.ServeHTTP(lrw, req)
 
-		log.Printf("[Gateway] IP: %s, %s %s, status %d, request_id: %s",
-			req.RemoteAddr, req.Method, req.URL.Path, lrw.statusCode, reqID)
+		logging.LogRequest(req, lrw.statusCode, start)
 	})
 }
 
@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"APIGateway/gateway/pkg/middl"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Признаки, по которым запросы делятся между вёдрами.
+const (
+	ByIP     = "ip"
+	ByAPIKey = "api_key"
+	ByUser   = "user"
+)
+
+// ValidKey сообщает, известен ли признак by.
+func ValidKey(by string) bool {
+	switch by {
+	case ByIP, ByAPIKey, ByUser:
+		return true
+	}
+	return false
+}
+
+// clientKey возвращает ключ ведра запроса. Если клиента нельзя определить по API-ключу
+// или пользователю (анонимный запрос), запрос считается по IP.
+func clientKey(r *http.Request, by string) string {
+	switch by {
+	case ByAPIKey:
+		if c := middl.GetClient(r.Context()); c != "" {
+			return "key:" + c
+		}
+	case ByUser:
+		if id, ok := middl.GetIdentity(r.Context()); ok {
+			return "user:" + id.ID
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// Handler ограничивает частоту запросов к маршруту scope. Превысившие лимит получают 429
+// с Retry-After; все ответы получают заголовки RateLimit-*. Если хранилище недоступно,
+// запрос пропускается: отказ лимитера не должен останавливать шлюз.
+func Handler(store Store, scope string, l Limit, by string, next http.HandlerFunc) http.HandlerFunc {
+	policy := fmt.Sprintf("%d;w=%d", int(l.burst()), int(math.Ceil(l.Per.Seconds())))
+	return func(w http.ResponseWriter, r *http.Request) {
+		res, err := store.Take(r.Context(), scope+"|"+clientKey(r, by), l)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "rate limiter unavailable", "scope", scope, "err", err)
+			next(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("RateLimit-Policy", policy)
+		h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+		if !res.Allowed {
+			h.Set("Retry-After", ceilSeconds(res.RetryAfter))
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"APIGateway/pkg/tracing"
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Postgres хранит вёдра в таблице rate_limits, общей для всех экземпляров шлюза.
+// Время берётся из часов базы, чтобы расхождение часов экземпляров не влияло на пополнение.
+type Postgres struct {
+	db    *pgxpool.Pool
+	takes atomic.Int64
+}
+
+func NewPostgres(ctx context.Context, constr string) (*Postgres, error) {
+	db, err := tracing.NewPgxPool(ctx, constr)
+	if err != nil {
+		return nil, err
+	}
+	return &Postgres{db: db}, nil
+}
+
+func (p *Postgres) Take(ctx context.Context, key string, l Limit) (Result, error) {
+	if p.takes.Add(1)%sweepEvery == 0 {
+		if _, err := p.Sweep(ctx); err != nil {
+			slog.ErrorContext(ctx, "failed to sweep rate limits", "err", err)
+		}
+	}
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+    INSERT INTO rate_limits (key, tokens, updated_at) VALUES ($1, $2, now())
+    ON CONFLICT (key) DO NOTHING`, key, l.burst())
+	if err != nil {
+		return Result{}, err
+	}
+
+	var tokens float64
+	var updated, now time.Time
+	err = tx.QueryRow(ctx, `
+    SELECT tokens, updated_at, now() FROM rate_limits WHERE key = $1 FOR UPDATE`, key).
+		Scan(&tokens, &updated, &now)
+	if err != nil {
+		return Result{}, err
+	}
+
+	tokens, res := take(tokens, updated, now, l)
+	_, err = tx.Exec(ctx, `
+    UPDATE rate_limits SET tokens = $2, updated_at = $3, expires_at = $4 WHERE key = $1`,
+		key, tokens, now, now.Add(res.Reset))
+	if err != nil {
+		return Result{}, err
+	}
+	return res, tx.Commit(ctx)
+}
+
+// Stat возвращает статистику пула соединений.
+func (p *Postgres) Stat() *pgxpool.Stat {
+	return p.db.Stat()
+}
+
+// Sweep удаляет наполнившиеся вёдра и возвращает их число.
+func (p *Postgres) Sweep(ctx context.Context) (int64, error) {
+	tag, err := p.db.Exec(ctx, `DELETE FROM rate_limits WHERE expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
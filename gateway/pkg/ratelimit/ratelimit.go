@@ -0,0 +1,122 @@
+// Package ratelimit ограничивает частоту запросов к шлюзу алгоритмом token bucket.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit — параметры ведра: Burst токенов, которые пополняются со скоростью Requests за Per.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+	// Burst — ёмкость ведра; 0 — равна Requests.
+	Burst int
+}
+
+// Enabled сообщает, ограничивает ли лимит что-нибудь.
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Per > 0
+}
+
+func (l Limit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.Requests)
+}
+
+// rate — токенов в секунду.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// Result — решение по запросу.
+type Result struct {
+	Allowed bool
+	// Limit — ёмкость ведра, Remaining — целых токенов после запроса.
+	Limit     int
+	Remaining int
+	// RetryAfter — через сколько появится токен, если запрос отклонён.
+	RetryAfter time.Duration
+	// Reset — через сколько ведро наполнится целиком.
+	Reset time.Duration
+}
+
+// Store хранит вёдра. Take снимает токен из ведра key, если он есть.
+type Store interface {
+	Take(ctx context.Context, key string, l Limit) (Result, error)
+}
+
+// take пополняет ведро с tokens токенами, обновлённое в момент updated, к моменту now
+// и снимает токен, если он есть. Возвращает новое число токенов и решение.
+func take(tokens float64, updated, now time.Time, l Limit) (float64, Result) {
+	burst := l.burst()
+	if elapsed := now.Sub(updated).Seconds(); elapsed > 0 {
+		tokens = math.Min(burst, tokens+elapsed*l.rate())
+	}
+	res := Result{Limit: int(burst)}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - tokens) / l.rate())
+	}
+	res.Remaining = int(tokens)
+	res.Reset = seconds((burst - tokens) / l.rate())
+	return tokens, res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// bucket — ведро в памяти.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // когда ведро наполнится и его можно забыть
+}
+
+// Memory хранит вёдра в памяти одного экземпляра шлюза.
+type Memory struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	takes   int
+}
+
+func NewMemory() *Memory {
+	return &Memory{now: time.Now, buckets: map[string]*bucket{}}
+}
+
+// sweepEvery — как часто (в вызовах Take) удалять наполнившиеся вёдра.
+const sweepEvery = 1024
+
+func (m *Memory) Take(ctx context.Context, key string, l Limit) (Result, error) {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.takes++; m.takes%sweepEvery == 0 {
+		for k, b := range m.buckets {
+			if !now.Before(b.full) {
+				delete(m.buckets, k)
+			}
+		}
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst(), updated: now}
+		m.buckets[key] = b
+	}
+	var res Result
+	b.tokens, res = take(b.tokens, b.updated, now, l)
+	b.updated = now
+	b.full = now.Add(res.Reset)
+	return res, nil
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"APIGateway/gateway/pkg/middl"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryTake(t *testing.T) {
+	m := NewMemory()
+	now := time.Unix(1000, 0)
+	m.now = func() time.Time { return now }
+	l := Limit{Requests: 60, Per: time.Minute, Burst: 2}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if res, _ := m.Take(ctx, "a", l); !res.Allowed || res.Remaining != 1-i {
+			t.Fatalf("take %d: %+v", i, res)
+		}
+	}
+	res, _ := m.Take(ctx, "a", l)
+	if res.Allowed || res.RetryAfter != time.Second || res.Reset != 2*time.Second {
+		t.Fatalf("empty bucket: %+v", res)
+	}
+	if res, _ := m.Take(ctx, "b", l); !res.Allowed {
+		t.Error("buckets of different keys must be independent")
+	}
+
+	now = now.Add(1500 * time.Millisecond)
+	if res, _ := m.Take(ctx, "a", l); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("refilled bucket: %+v", res)
+	}
+	now = now.Add(time.Hour)
+	if res, _ := m.Take(ctx, "a", l); !res.Allowed || res.Remaining != 1 {
+		t.Errorf("bucket must not exceed burst: %+v", res)
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, l Limit) (Result, error) {
+	return Result{}, errors.New("db is down")
+}
+
+func TestHandler(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	l := Limit{Requests: 1, Per: time.Minute}
+	h := Handler(NewMemory(), "POST /comments", l, ByUser, ok)
+
+	serve := func(user, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/comments", nil)
+		req.RemoteAddr = ip + ":1234"
+		if user != "" {
+			req = middl.WithIdentity(req, middl.Identity{ID: user})
+		}
+		rr := httptest.NewRecorder()
+		h(rr, req)
+		return rr
+	}
+
+	rr := serve("alice", "10.0.0.1")
+	if rr.Code != http.StatusOK || rr.Header().Get("RateLimit-Limit") != "1" || rr.Header().Get("RateLimit-Remaining") != "0" ||
+		rr.Header().Get("RateLimit-Policy") != "1;w=60" {
+		t.Fatalf("first request: %d %v", rr.Code, rr.Header())
+	}
+	rr = serve("alice", "10.0.0.2")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "60" {
+		t.Errorf("same user from another IP: %d, Retry-After %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	if rr := serve("bob", "10.0.0.1"); rr.Code != http.StatusOK {
+		t.Errorf("another user: expected 200, got %d", rr.Code)
+	}
+	// Анонимные запросы считаются по IP.
+	serve("", "10.0.0.3")
+	if rr := serve("", "10.0.0.3"); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("anonymous from the same IP: expected 429, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	Handler(failingStore{}, "GET /news", l, ByIP, ok)(rr, httptest.NewRequest(http.MethodGet, "/news", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("unavailable store must not block requests, got %d", rr.Code)
+	}
+}
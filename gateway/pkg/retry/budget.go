@@ -0,0 +1,60 @@
+package retry
+
+import (
+	"sync"
+	"time"
+)
+
+// Budget ограничивает долю повторов относительно исходных запросов маршрута,
+// чтобы при отказе upstream повторы не умножали нагрузку на него.
+// Каждый запрос пополняет бюджет на Ratio, каждый повтор тратит единицу;
+// кроме того, бюджет пополняется на MinPerSecond в секунду независимо от трафика.
+type Budget struct {
+	ratio        float64
+	minPerSecond float64
+	max          float64
+	now          func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewBudget создаёт бюджет. ratio — допустимая доля повторов (0.2 — не более 20%),
+// minPerSecond — повторы, разрешённые даже при малом трафике.
+func NewBudget(ratio, minPerSecond float64) *Budget {
+	max := 10 + minPerSecond
+	b := &Budget{ratio: ratio, minPerSecond: minPerSecond, max: max, now: time.Now}
+	b.last = b.now()
+	b.tokens = minPerSecond
+	return b
+}
+
+// Deposit учитывает исходный запрос.
+func (b *Budget) Deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	b.tokens = min(b.max, b.tokens+b.ratio)
+}
+
+// Withdraw списывает один повтор, если бюджет позволяет.
+func (b *Budget) Withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (b *Budget) refill() {
+	now := b.now()
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+	if elapsed > 0 {
+		b.tokens = min(b.max, b.tokens+elapsed*b.minPerSecond)
+	}
+}
@@ -0,0 +1,151 @@
+// Package retry повторяет неудавшиеся вызовы upstream с экспоненциальной задержкой,
+// учитывая идемпотентность запроса и бюджет повторов маршрута.
+package retry
+
+import (
+	"APIGateway/gateway/pkg/breaker"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// IdempotencyKeyHeader — заголовок, которым клиент разрешает повтор неидемпотентного запроса.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Policy — параметры повторов.
+type Policy struct {
+	// MaxAttempts — максимум попыток, включая первую. 1 и меньше отключает повторы.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// RetryOn — коды ответа upstream, при которых запрос повторяется.
+	// Ошибки соединения повторяются всегда.
+	RetryOn []int
+}
+
+// Send выполняет одну попытку запроса.
+type Send func(*http.Request) (*http.Response, error)
+
+// Retrier применяет политику повторов в пределах бюджета одного маршрута.
+type Retrier struct {
+	policy Policy
+	budget *Budget
+	// OnRetry вызывается перед каждым повтором, например для журналирования.
+	OnRetry func(req *http.Request, attempt int, delay time.Duration, reason string)
+}
+
+// New создаёт Retrier. budget может быть nil — тогда число повторов ограничено только MaxAttempts.
+func New(policy Policy, budget *Budget) *Retrier {
+	return &Retrier{policy: policy, budget: budget}
+}
+
+// Retryable сообщает, можно ли повторять запрос: безопасные методы повторяются всегда,
+// остальные — только если клиент передал Idempotency-Key. Ключ передаётся upstream,
+// и повтор не должен создавать копию: сервис комментариев хранит ключ вместе с комментарием.
+func Retryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return req.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+// Do выполняет запрос через send, повторяя его по политике.
+func (r *Retrier) Do(req *http.Request, send Send) (*http.Response, error) {
+	if r == nil || r.policy.MaxAttempts <= 1 || !Retryable(req) {
+		return send(req)
+	}
+	if r.budget != nil {
+		r.budget.Deposit()
+	}
+	if err := bufferBody(req); err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		resp, err := send(req)
+
+		reason := r.reason(req.Context(), resp, err)
+		if reason == "" || attempt >= r.policy.MaxAttempts {
+			return resp, err
+		}
+		if r.budget != nil && !r.budget.Withdraw() {
+			return resp, err
+		}
+
+		delay := r.backoff(attempt)
+		if r.OnRetry != nil {
+			r.OnRetry(req, attempt+1, delay, reason)
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reason возвращает причину повтора или пустую строку, если повторять не нужно.
+func (r *Retrier) reason(ctx context.Context, resp *http.Response, err error) string {
+	if err != nil {
+		var open *breaker.OpenError
+		if ctx.Err() != nil || errors.As(err, &open) {
+			return ""
+		}
+		return err.Error()
+	}
+	if slices.Contains(r.policy.RetryOn, resp.StatusCode) {
+		return fmt.Sprintf("upstream returned %d", resp.StatusCode)
+	}
+	return ""
+}
+
+// backoff — экспоненциальная задержка с полным джиттером: случайная в [0, min(MaxDelay, BaseDelay*2^(attempt-1))].
+func (r *Retrier) backoff(attempt int) time.Duration {
+	d := r.policy.BaseDelay << (attempt - 1)
+	if d <= 0 || (r.policy.MaxDelay > 0 && d > r.policy.MaxDelay) {
+		d = r.policy.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// bufferBody читает тело запроса в память, чтобы его можно было отправить повторно.
+func bufferBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return nil
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	req.Body, _ = req.GetBody()
+	req.ContentLength = int64(len(data))
+	return nil
+}
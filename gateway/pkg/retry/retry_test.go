@@ -0,0 +1,91 @@
+package retry
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// flaky возвращает send, который отвечает кодами codes по очереди и запоминает тела запросов.
+func flaky(codes ...int) (Send, *[]string) {
+	var bodies []string
+	return func(req *http.Request) (*http.Response, error) {
+		body := ""
+		if req.Body != nil {
+			b, _ := io.ReadAll(req.Body)
+			body = string(b)
+		}
+		bodies = append(bodies, body)
+		code := codes[min(len(bodies), len(codes))-1]
+		rec := httptest.NewRecorder()
+		rec.WriteHeader(code)
+		return rec.Result(), nil
+	}, &bodies
+}
+
+var testPolicy = Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, RetryOn: []int{503}}
+
+func TestRetryGet(t *testing.T) {
+	send, calls := flaky(503, 503, 200)
+	req := httptest.NewRequest(http.MethodGet, "/news", nil)
+
+	var retries []int
+	r := New(testPolicy, nil)
+	r.OnRetry = func(_ *http.Request, attempt int, _ time.Duration, _ string) { retries = append(retries, attempt) }
+
+	resp, err := r.Do(req, send)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("expected eventual 200, got %v %v", resp, err)
+	}
+	if len(*calls) != 3 || len(retries) != 2 || retries[1] != 3 {
+		t.Errorf("expected 3 attempts, got calls=%d retries=%v", len(*calls), retries)
+	}
+}
+
+func TestRetryStopsAtMaxAttempts(t *testing.T) {
+	send, calls := flaky(503)
+	resp, _ := New(testPolicy, nil).Do(httptest.NewRequest(http.MethodGet, "/", nil), send)
+	if resp.StatusCode != 503 || len(*calls) != 3 {
+		t.Errorf("expected last 503 after 3 attempts, got %d after %d", resp.StatusCode, len(*calls))
+	}
+}
+
+func TestRetryPostNeedsIdempotencyKey(t *testing.T) {
+	send, calls := flaky(503, 201)
+	req := httptest.NewRequest(http.MethodPost, "/news/1/comments", bytes.NewBufferString(`{"text":"hi"}`))
+	resp, _ := New(testPolicy, nil).Do(req, send)
+	if resp.StatusCode != 503 || len(*calls) != 1 {
+		t.Fatalf("POST without Idempotency-Key must not be retried, got %d calls", len(*calls))
+	}
+
+	send, calls = flaky(503, 201)
+	req = httptest.NewRequest(http.MethodPost, "/news/1/comments", bytes.NewBufferString(`{"text":"hi"}`))
+	req.Header.Set(IdempotencyKeyHeader, "abc")
+	resp, _ = New(testPolicy, nil).Do(req, send)
+	if resp.StatusCode != 201 || len(*calls) != 2 {
+		t.Fatalf("POST with Idempotency-Key must be retried, got %d calls", len(*calls))
+	}
+	if (*calls)[1] != `{"text":"hi"}` {
+		t.Errorf("body was not replayed on retry: %q", (*calls)[1])
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	budget := NewBudget(0, 0)
+	send, calls := flaky(503)
+	New(testPolicy, budget).Do(httptest.NewRequest(http.MethodGet, "/", nil), send)
+	if len(*calls) != 1 {
+		t.Errorf("empty budget must prevent retries, got %d calls", len(*calls))
+	}
+
+	budget = NewBudget(0.5, 0)
+	for i := 0; i < 2; i++ {
+		budget.Deposit()
+	}
+	if !budget.Withdraw() || budget.Withdraw() {
+		t.Error("two requests at 50% ratio must allow exactly one retry")
+	}
+}
@@ -0,0 +1,77 @@
+package upstream
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// Balancer выбирает экземпляр из списка доступных. Список никогда не пуст.
+type Balancer interface {
+	Pick(candidates []*Instance) *Instance
+}
+
+// NewBalancer возвращает стратегию балансировки по имени.
+// Пустое имя означает round_robin.
+func NewBalancer(name string) (Balancer, error) {
+	switch name {
+	case "", "round_robin":
+		return &RoundRobin{}, nil
+	case "least_in_flight":
+		return LeastInFlight{}, nil
+	case "p2c", "random_two_choices":
+		return NewPowerOfTwo(), nil
+	}
+	return nil, fmt.Errorf("unknown balancer %q", name)
+}
+
+// RoundRobin перебирает экземпляры по кругу.
+type RoundRobin struct {
+	next atomic.Uint64
+}
+
+func (b *RoundRobin) Pick(candidates []*Instance) *Instance {
+	n := b.next.Add(1) - 1
+	return candidates[n%uint64(len(candidates))]
+}
+
+// LeastInFlight выбирает экземпляр с наименьшим числом выполняющихся запросов.
+type LeastInFlight struct{}
+
+func (LeastInFlight) Pick(candidates []*Instance) *Instance {
+	best := candidates[0]
+	for _, inst := range candidates[1:] {
+		if inst.InFlight() < best.InFlight() {
+			best = inst
+		}
+	}
+	return best
+}
+
+// PowerOfTwo выбирает два случайных экземпляра и берёт менее загруженный.
+type PowerOfTwo struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func NewPowerOfTwo() *PowerOfTwo {
+	return &PowerOfTwo{rnd: rand.New(rand.NewSource(rand.Int63()))}
+}
+
+func (b *PowerOfTwo) Pick(candidates []*Instance) *Instance {
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+	b.mu.Lock()
+	i := b.rnd.Intn(len(candidates))
+	j := b.rnd.Intn(len(candidates) - 1)
+	b.mu.Unlock()
+	if j >= i {
+		j++
+	}
+	if candidates[j].InFlight() < candidates[i].InFlight() {
+		return candidates[j]
+	}
+	return candidates[i]
+}
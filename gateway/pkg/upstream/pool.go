@@ -0,0 +1,214 @@
+package upstream
+
+import (
+	"APIGateway/gateway/pkg/breaker"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoInstances возвращается, если у пула нет ни одного экземпляра.
+var ErrNoInstances = errors.New("upstream has no instances")
+
+// Options — параметры пассивного исключения экземпляров из балансировки.
+type Options struct {
+	// EjectAfter — число подряд идущих ошибок соединения или ответов 5xx,
+	// после которого экземпляр исключается. 0 отключает исключение.
+	EjectAfter int
+	// EjectFor — на сколько экземпляр исключается из балансировки.
+	EjectFor time.Duration
+	// Breaker — пороги выключателя для всего upstream. Нулевое значение отключает выключатель.
+	Breaker breaker.Config
+}
+
+// Instance — один экземпляр upstream-сервиса.
+type Instance struct {
+	URL *url.URL
+
+	inFlight atomic.Int64
+
+	mu           sync.Mutex
+	failures     int
+	ejectedUntil time.Time
+	// Результат последней активной проверки; до первой проверки экземпляр считается здоровым.
+	unhealthy bool
+	lastCheck time.Time
+	lastError string
+}
+
+// InFlight возвращает число выполняющихся на экземпляре запросов.
+func (i *Instance) InFlight() int64 {
+	return i.inFlight.Load()
+}
+
+// Ejected сообщает, исключён ли экземпляр из балансировки на момент now.
+func (i *Instance) Ejected(now time.Time) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return now.Before(i.ejectedUntil)
+}
+
+// Available сообщает, участвует ли экземпляр в балансировке:
+// он не исключён пассивно и прошёл последнюю активную проверку.
+func (i *Instance) Available(now time.Time) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return !i.unhealthy && !now.Before(i.ejectedUntil)
+}
+
+// setHealth записывает результат активной проверки.
+func (i *Instance) setHealth(err error, at time.Time) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.unhealthy = err != nil
+	i.lastCheck = at
+	i.lastError = ""
+	if err != nil {
+		i.lastError = err.Error()
+	}
+}
+
+func (i *Instance) report(ok bool, opts Options) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if ok {
+		i.failures = 0
+		return
+	}
+	i.failures++
+	if opts.EjectAfter > 0 && i.failures >= opts.EjectAfter {
+		i.failures = 0
+		i.ejectedUntil = time.Now().Add(opts.EjectFor)
+	}
+}
+
+// Pool — набор экземпляров одного сервиса с балансировкой между ними.
+type Pool struct {
+	name      string
+	instances []*Instance
+	balancer  Balancer
+	client    *http.Client
+	opts      Options
+	breaker   *breaker.Breaker
+}
+
+// NewPool создаёт пул из базовых адресов экземпляров.
+func NewPool(name string, urls []*url.URL, balancer Balancer, client *http.Client, opts Options) *Pool {
+	p := &Pool{
+		name:     name,
+		balancer: balancer,
+		client:   client,
+		opts:     opts,
+	}
+	for _, u := range urls {
+		p.instances = append(p.instances, &Instance{URL: u})
+	}
+	if opts.Breaker.Enabled() {
+		p.breaker = breaker.New(name, opts.Breaker)
+	}
+	return p
+}
+
+// Name возвращает имя сервиса.
+func (p *Pool) Name() string {
+	return p.name
+}
+
+// Instances возвращает экземпляры пула.
+func (p *Pool) Instances() []*Instance {
+	return p.instances
+}
+
+// Do выполняет запрос на одном из экземпляров. У req должны быть заданы только путь и query:
+// схема, хост и базовый путь берутся из выбранного экземпляра.
+// Если выключатель upstream разомкнут, Do сразу возвращает *breaker.OpenError.
+func (p *Pool) Do(req *http.Request) (*http.Response, error) {
+	inst := p.pick()
+	if inst == nil {
+		return nil, fmt.Errorf("%s: %w", p.name, ErrNoInstances)
+	}
+	var ticket breaker.Ticket
+	if p.breaker != nil {
+		var err error
+		if ticket, err = p.breaker.Allow(); err != nil {
+			return nil, err
+		}
+	}
+
+	out := req.Clone(req.Context())
+	out.URL = resolve(inst.URL, req.URL)
+	out.Host = ""
+	out.RequestURI = ""
+
+	start := time.Now()
+	inst.inFlight.Add(1)
+	resp, err := p.client.Do(out)
+	latency := time.Since(start)
+	// Экземпляр занят запросом, пока не прочитано тело ответа.
+	if err != nil {
+		inst.inFlight.Add(-1)
+	} else {
+		resp.Body = &trackedBody{ReadCloser: resp.Body, inst: inst}
+	}
+
+	// Отмена запроса клиентом — не вина экземпляра и не учитывается.
+	if err != nil && req.Context().Err() != nil {
+		if p.breaker != nil {
+			p.breaker.Cancel(ticket)
+		}
+		return resp, err
+	}
+	ok := err == nil && resp.StatusCode < http.StatusInternalServerError
+	inst.report(ok, p.opts)
+	if p.breaker != nil {
+		p.breaker.Record(ticket, ok, latency)
+	}
+	return resp, err
+}
+
+// trackedBody уменьшает число запросов экземпляра в работе при закрытии тела ответа.
+type trackedBody struct {
+	io.ReadCloser
+	inst *Instance
+	once sync.Once
+}
+
+func (b *trackedBody) Close() error {
+	b.once.Do(func() { b.inst.inFlight.Add(-1) })
+	return b.ReadCloser.Close()
+}
+
+// pick выбирает экземпляр среди доступных. Если недоступны все,
+// балансировка идёт по всем экземплярам, чтобы не отказывать полностью.
+func (p *Pool) pick() *Instance {
+	if len(p.instances) == 0 {
+		return nil
+	}
+	now := time.Now()
+	candidates := make([]*Instance, 0, len(p.instances))
+	for _, inst := range p.instances {
+		if inst.Available(now) {
+			candidates = append(candidates, inst)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = p.instances
+	}
+	return p.balancer.Pick(candidates)
+}
+
+// resolve склеивает базовый адрес экземпляра с путём и query запроса.
+func resolve(base, ref *url.URL) *url.URL {
+	u := *base
+	u.Path = base.Path + ref.Path
+	if ref.RawPath != "" {
+		u.RawPath = base.EscapedPath() + ref.RawPath
+	}
+	u.RawQuery = ref.RawQuery
+	return &u
+}
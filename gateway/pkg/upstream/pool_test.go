@@ -0,0 +1,175 @@
+package upstream
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func mustURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestRoundRobin(t *testing.T) {
+	instances := []*Instance{{}, {}, {}}
+	b := &RoundRobin{}
+	for i := 0; i < 6; i++ {
+		if got := b.Pick(instances); got != instances[i%3] {
+			t.Fatalf("pick %d: unexpected instance", i)
+		}
+	}
+}
+
+func TestLeastInFlight(t *testing.T) {
+	instances := []*Instance{{}, {}, {}}
+	instances[0].inFlight.Store(5)
+	instances[1].inFlight.Store(1)
+	instances[2].inFlight.Store(3)
+	if got := (LeastInFlight{}).Pick(instances); got != instances[1] {
+		t.Fatal("expected the least loaded instance")
+	}
+}
+
+func TestPowerOfTwoNeverPicksMostLoaded(t *testing.T) {
+	instances := []*Instance{{}, {}}
+	instances[0].inFlight.Store(10)
+	b := NewPowerOfTwo()
+	for i := 0; i < 100; i++ {
+		if b.Pick(instances) != instances[1] {
+			t.Fatal("with two instances p2c must always pick the less loaded one")
+		}
+	}
+}
+
+func TestNewBalancerUnknown(t *testing.T) {
+	if _, err := NewBalancer("fastest"); err == nil {
+		t.Fatal("expected error for unknown balancer")
+	}
+}
+
+func TestPoolSpreadsAndEjects(t *testing.T) {
+	var okHits, badHits atomic.Int32
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		okHits.Add(1)
+		if r.URL.Path != "/base/ping" || r.URL.RawQuery != "x=1" {
+			t.Errorf("unexpected upstream url %s", r.URL)
+		}
+	}))
+	defer good.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		badHits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer bad.Close()
+
+	pool := NewPool("svc",
+		[]*url.URL{mustURL(t, good.URL+"/base"), mustURL(t, bad.URL+"/base")},
+		&RoundRobin{}, http.DefaultClient,
+		Options{EjectAfter: 2, EjectFor: time.Minute})
+
+	for i := 0; i < 10; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "/ping?x=1", nil)
+		resp, err := pool.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if badHits.Load() != 2 {
+		t.Errorf("failing instance should be ejected after 2 errors, got %d hits", badHits.Load())
+	}
+	if okHits.Load() != 8 {
+		t.Errorf("expected the rest of traffic on the healthy instance, got %d", okHits.Load())
+	}
+	if !pool.Instances()[1].Ejected(time.Now()) {
+		t.Error("failing instance is not marked as ejected")
+	}
+}
+
+func TestPoolInFlightUntilBodyClosed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("body"))
+	}))
+	defer srv.Close()
+
+	pool := NewPool("svc", []*url.URL{mustURL(t, srv.URL)}, &RoundRobin{}, http.DefaultClient, Options{})
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	resp, err := pool.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst := pool.Instances()[0]
+	if n := inst.InFlight(); n != 1 {
+		t.Errorf("request with unread body must stay in flight, got %d", n)
+	}
+	resp.Body.Close()
+	resp.Body.Close()
+	if n := inst.InFlight(); n != 0 {
+		t.Errorf("closed body must release the instance once, got %d in flight", n)
+	}
+}
+
+func TestPoolConnectionErrorEjects(t *testing.T) {
+	dead := httptest.NewServer(http.NotFoundHandler())
+	deadURL := mustURL(t, dead.URL)
+	dead.Close()
+
+	pool := NewPool("svc", []*url.URL{deadURL}, &RoundRobin{}, http.DefaultClient,
+		Options{EjectAfter: 1, EjectFor: time.Minute})
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if _, err := pool.Do(req); err == nil {
+		t.Fatal("expected connection error")
+	}
+	if !pool.Instances()[0].Ejected(time.Now()) {
+		t.Error("instance with connection error is not ejected")
+	}
+
+	// Если исключены все экземпляры, пул всё равно пробует их, а не отказывает сразу.
+	req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	if _, err := pool.Do(req); err == ErrNoInstances {
+		t.Error("pool with all instances ejected must still try them")
+	}
+}
+
+func TestPoolEmpty(t *testing.T) {
+	pool := NewPool("svc", nil, &RoundRobin{}, http.DefaultClient, Options{})
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if _, err := pool.Do(req); err == nil {
+		t.Fatal("expected ErrNoInstances")
+	}
+}
+
+func TestProberMarksInstances(t *testing.T) {
+	ready := true
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/readyz" || !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer up.Close()
+
+	pool := NewPool("svc", []*url.URL{mustURL(t, up.URL)}, &RoundRobin{}, http.DefaultClient, Options{})
+	prober := NewProber([]*Pool{pool}, "/readyz", time.Hour, time.Second)
+
+	prober.CheckAll(context.Background())
+	if st := pool.Status(); st.Available != 1 || st.Instances[0].LastCheck == nil {
+		t.Fatalf("expected healthy checked instance, got %+v", st)
+	}
+
+	ready = false
+	prober.CheckAll(context.Background())
+	st := pool.Status()
+	if st.Available != 0 || st.Instances[0].Healthy || st.Instances[0].LastError == "" {
+		t.Fatalf("expected unhealthy instance with error, got %+v", st)
+	}
+}
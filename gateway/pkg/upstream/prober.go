@@ -0,0 +1,93 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Prober периодически опрашивает endpoint готовности каждого экземпляра
+// и помечает экземпляры здоровыми или недоступными.
+type Prober struct {
+	pools    []*Pool
+	path     string
+	interval time.Duration
+	timeout  time.Duration
+	client   *http.Client
+}
+
+// NewProber создаёт проверяльщик для пулов. path — путь проверки относительно
+// базового адреса экземпляра, обычно /readyz.
+func NewProber(pools []*Pool, path string, interval, timeout time.Duration) *Prober {
+	return &Prober{
+		pools:    pools,
+		path:     path,
+		interval: interval,
+		timeout:  timeout,
+		client:   &http.Client{},
+	}
+}
+
+// Run проверяет экземпляры сразу и затем каждые interval, пока не отменён ctx.
+func (p *Prober) Run(ctx context.Context) {
+	p.CheckAll(ctx)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.CheckAll(ctx)
+		}
+	}
+}
+
+// CheckAll параллельно проверяет все экземпляры всех пулов и дожидается результатов.
+func (p *Prober) CheckAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, pool := range p.pools {
+		for _, inst := range pool.instances {
+			wg.Add(1)
+			go func(pool *Pool, inst *Instance) {
+				defer wg.Done()
+				err := p.check(ctx, inst)
+				wasHealthy := inst.Healthy()
+				inst.setHealth(err, time.Now())
+				switch {
+				case err != nil && wasHealthy:
+					slog.Warn("upstream instance is down", "upstream", pool.name, "instance", inst.URL, "err", err)
+				case err == nil && !wasHealthy:
+					slog.Info("upstream instance is up", "upstream", pool.name, "instance", inst.URL)
+				}
+			}(pool, inst)
+		}
+	}
+	wg.Wait()
+}
+
+func (p *Prober) check(ctx context.Context, inst *Instance) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, inst.URL.String()+p.path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check returned %d", resp.StatusCode)
+	}
+	return nil
+}
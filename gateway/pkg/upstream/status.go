@@ -0,0 +1,68 @@
+package upstream
+
+import (
+	"APIGateway/gateway/pkg/breaker"
+	"time"
+)
+
+// InstanceStatus — состояние экземпляра для отчёта /gateway/status.
+type InstanceStatus struct {
+	URL       string     `json:"url"`
+	Healthy   bool       `json:"healthy"`
+	Ejected   bool       `json:"ejected"`
+	InFlight  int64      `json:"in_flight"`
+	LastCheck *time.Time `json:"last_check,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// PoolStatus — состояние пула: сколько экземпляров сейчас принимают трафик.
+type PoolStatus struct {
+	Name      string            `json:"name"`
+	Available int               `json:"available"`
+	Instances []InstanceStatus  `json:"instances"`
+	Breaker   *breaker.Snapshot `json:"breaker,omitempty"`
+}
+
+// Healthy сообщает результат последней активной проверки экземпляра.
+func (i *Instance) Healthy() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return !i.unhealthy
+}
+
+// Status возвращает снимок состояния экземпляра.
+func (i *Instance) Status(now time.Time) InstanceStatus {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	st := InstanceStatus{
+		URL:       i.URL.String(),
+		Healthy:   !i.unhealthy,
+		Ejected:   now.Before(i.ejectedUntil),
+		InFlight:  i.inFlight.Load(),
+		LastError: i.lastError,
+	}
+	if !i.lastCheck.IsZero() {
+		t := i.lastCheck
+		st.LastCheck = &t
+	}
+	return st
+}
+
+// Status возвращает снимок состояния пула.
+func (p *Pool) Status() PoolStatus {
+	now := time.Now()
+	st := PoolStatus{Name: p.name, Instances: []InstanceStatus{}}
+	for _, inst := range p.instances {
+		is := inst.Status(now)
+		if is.Healthy && !is.Ejected {
+			st.Available++
+		}
+		st.Instances = append(st.Instances, is)
+	}
+	if p.breaker != nil {
+		snap := p.breaker.Snapshot()
+		st.Breaker = &snap
+	}
+	return st
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+)
+
+// RequestIDHeader — заголовок, в котором шлюз передаёт сервисам идентификатор запроса.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID возвращает идентификатор запроса для сервисов за шлюзом: из заголовка
+// X-Request-ID, а при прямом вызове сервиса в обход шлюза — из параметра request_id.
+// Если идентификатора нет ни там, ни там, создаётся новый UUID.
+func RequestID(r *http.Request) (string, error) {
+	if id := r.Header.Get(RequestIDHeader); id != "" {
+		return id, nil
+	}
+	if id := r.URL.Query().Get(RequestIDKey); id != "" {
+		return id, nil
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+// WithRequest добавляет в контекст запроса идентификатор requestID и шаблон маршрута mux.
+func WithRequest(r *http.Request, requestID string) context.Context {
+	attrs := []slog.Attr{slog.String(RequestIDKey, requestID)}
+	if cur := mux.CurrentRoute(r); cur != nil {
+		if tmpl, err := cur.GetPathTemplate(); err == nil {
+			attrs = append(attrs, slog.String(RouteKey, tmpl))
+		}
+	}
+	return With(r.Context(), attrs...)
+}
+
+// LogRequest пишет запись о выполненном запросе: ответы 5xx — с уровнем ERROR, 4xx — WARN.
+func LogRequest(r *http.Request, status int, start time.Time) {
+	level := slog.LevelInfo
+	switch {
+	case status >= http.StatusInternalServerError:
+		level = slog.LevelError
+	case status >= http.StatusBadRequest:
+		level = slog.LevelWarn
+	}
+	slog.LogAttrs(r.Context(), level, "request",
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", status),
+		slog.Duration("duration", time.Since(start)),
+		slog.String("remote_addr", r.RemoteAddr),
+	)
+}
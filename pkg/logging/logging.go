@@ -0,0 +1,171 @@
+// Package logging — структурные логи сервисов на log/slog: JSON, уровень из конфигурации,
+// поля запроса (request_id, route, user_id, trace_id) из context.Context и файл с ротацией.
+package logging
+
+import (
+	"APIGateway/pkg/tracing"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Ключи полей запроса.
+const (
+	RequestIDKey = "request_id"
+	RouteKey     = "route"
+	UserKey      = "user_id"
+	TraceIDKey   = "trace_id"
+	SpanIDKey    = "span_id"
+)
+
+// Форматы вывода.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Config — настройки логгера сервиса.
+type Config struct {
+	Level  slog.Level
+	Format string // json (по умолчанию) или text
+	// File — файл, в который записи пишутся вместе со stdout; пусто — только stdout.
+	File string
+	// MaxSizeMB — размер файла, после которого он переименовывается в File.1; 0 — без ротации.
+	MaxSizeMB int
+	// MaxBackups — сколько старых файлов хранить.
+	MaxBackups int
+}
+
+// ConfigFromEnv читает настройки из переменных <prefix>_LOG_LEVEL, _LOG_FORMAT, _LOG_FILE,
+// _LOG_MAX_SIZE_MB и _LOG_MAX_BACKUPS; незаданные берутся из тех же переменных без префикса.
+func ConfigFromEnv(prefix string) (Config, error) {
+	get := func(name string) string {
+		if v := os.Getenv(prefix + "_" + name); v != "" {
+			return v
+		}
+		return os.Getenv(name)
+	}
+	cfg := Config{Format: FormatJSON, File: get("LOG_FILE"), MaxSizeMB: 100, MaxBackups: 5}
+	if v := get("LOG_LEVEL"); v != "" {
+		if err := cfg.Level.UnmarshalText([]byte(v)); err != nil {
+			return cfg, fmt.Errorf("%s_LOG_LEVEL: %w", prefix, err)
+		}
+	}
+	if v := get("LOG_FORMAT"); v != "" {
+		cfg.Format = strings.ToLower(v)
+		if cfg.Format != FormatJSON && cfg.Format != FormatText {
+			return cfg, fmt.Errorf("%s_LOG_FORMAT: unknown format %q", prefix, v)
+		}
+	}
+	for name, dst := range map[string]*int{"LOG_MAX_SIZE_MB": &cfg.MaxSizeMB, "LOG_MAX_BACKUPS": &cfg.MaxBackups} {
+		if v := get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return cfg, fmt.Errorf("%s_%s: invalid value %q", prefix, name, v)
+			}
+			*dst = n
+		}
+	}
+	return cfg, nil
+}
+
+// New создаёт логгер сервиса service. Возвращённый io.Closer закрывает файл логов.
+func New(service string, cfg Config) (*slog.Logger, io.Closer, error) {
+	var w io.Writer = os.Stdout
+	var closer io.Closer = nopCloser{}
+	if cfg.File != "" {
+		f, err := NewRotatingFile(cfg.File, int64(cfg.MaxSizeMB)<<20, cfg.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		w, closer = io.MultiWriter(os.Stdout, f), f
+	}
+	return slog.New(NewHandler(w, cfg)).With("service", service), closer, nil
+}
+
+// FromEnv создаёт логгер по ConfigFromEnv(prefix) и делает его логгером по умолчанию:
+// через него пишут функции slog и пакет log.
+func FromEnv(service, prefix string) (io.Closer, error) {
+	cfg, err := ConfigFromEnv(prefix)
+	if err != nil {
+		return nil, err
+	}
+	logger, closer, err := New(service, cfg)
+	if err != nil {
+		return nil, err
+	}
+	slog.SetDefault(logger)
+	return closer, nil
+}
+
+// NewHandler создаёт обработчик, который пишет записи в w и добавляет к ним поля запроса из контекста.
+func NewHandler(w io.Writer, cfg Config) slog.Handler {
+	opts := &slog.HandlerOptions{Level: cfg.Level}
+	if cfg.Format == FormatText {
+		return contextHandler{slog.NewTextHandler(w, opts)}
+	}
+	return contextHandler{slog.NewJSONHandler(w, opts)}
+}
+
+type fieldsKey struct{}
+
+// With возвращает контекст, записи из которого получат поля attrs.
+// Поле с уже заданным ключом заменяется.
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	prev := Fields(ctx)
+	fields := make([]slog.Attr, 0, len(prev)+len(attrs))
+	for _, a := range prev {
+		if !hasKey(attrs, a.Key) {
+			fields = append(fields, a)
+		}
+	}
+	fields = append(fields, attrs...)
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// Fields возвращает поля, добавленные в контекст через With.
+func Fields(ctx context.Context) []slog.Attr {
+	fields, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	return fields
+}
+
+func hasKey(attrs []slog.Attr, key string) bool {
+	for _, a := range attrs {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// contextHandler дополняет записи полями из контекста и идентификаторами текущего спана.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		r.AddAttrs(Fields(ctx)...)
+		if span := tracing.SpanFromContext(ctx); span != nil {
+			sc := span.SpanContext()
+			r.AddAttrs(slog.String(TraceIDKey, sc.TraceID.String()), slog.String(SpanIDKey, sc.SpanID.String()))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
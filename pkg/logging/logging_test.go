@@ -0,0 +1,206 @@
+package logging
+
+import (
+	"APIGateway/pkg/tracing"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("LOG_FILE", "shared.log")
+	t.Setenv("COMMENTS_LOG_LEVEL", "debug")
+	t.Setenv("COMMENTS_LOG_MAX_BACKUPS", "2")
+
+	cfg, err := ConfigFromEnv("COMMENTS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Level != slog.LevelDebug || cfg.File != "shared.log" || cfg.MaxBackups != 2 || cfg.MaxSizeMB != 100 || cfg.Format != FormatJSON {
+		t.Errorf("comments config: %+v", cfg)
+	}
+	if cfg, _ := ConfigFromEnv("CENSOR"); cfg.Level != slog.LevelWarn {
+		t.Errorf("censor must fall back to LOG_LEVEL, got %v", cfg.Level)
+	}
+
+	for name, value := range map[string]string{
+		"CENSOR_LOG_LEVEL":       "verbose",
+		"CENSOR_LOG_FORMAT":      "xml",
+		"CENSOR_LOG_MAX_SIZE_MB": "-1",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := ConfigFromEnv("CENSOR"); err == nil || !strings.Contains(err.Error(), "CENSOR_") {
+				t.Errorf("%s=%s: expected error naming the variable, got %v", name, value, err)
+			}
+		})
+	}
+}
+
+// decode разбирает записи JSON-обработчика.
+func decode(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid JSON record %q: %v", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestRequestFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(&buf, Config{Level: slog.LevelInfo})).With("service", "comments")
+
+	router := mux.NewRouter()
+	router.HandleFunc("/comments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		ctx := With(r.Context(), slog.String(UserKey, "alice"))
+		ctx, span := tracing.Start(ctx, "handler", tracing.KindInternal)
+		defer span.End()
+		logger.InfoContext(ctx, "comment edited", "comment_id", 7)
+		logger.DebugContext(ctx, "hidden")
+		w.WriteHeader(http.StatusNotFound)
+	})
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(WithRequest(r, "req-1")))
+		})
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPatch, "/comments/7", nil))
+
+	records := decode(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("expected one record above debug level, got %d: %s", len(records), buf.String())
+	}
+	rec := records[0]
+	for key, want := range map[string]any{
+		"level":      "INFO",
+		"msg":        "comment edited",
+		"service":    "comments",
+		"comment_id": float64(7),
+		RequestIDKey: "req-1",
+		RouteKey:     "/comments/{id}",
+		UserKey:      "alice",
+	} {
+		if rec[key] != want {
+			t.Errorf("%s = %v, want %v", key, rec[key], want)
+		}
+	}
+	if id, _ := rec[TraceIDKey].(string); len(id) != 32 {
+		t.Errorf("trace_id = %v", rec[TraceIDKey])
+	}
+}
+
+func TestWithReplacesField(t *testing.T) {
+	ctx := With(context.Background(), slog.String(UserKey, "anonymous"), slog.String(RequestIDKey, "r"))
+	ctx = With(ctx, slog.String(UserKey, "bob"))
+	fields := Fields(ctx)
+	if len(fields) != 2 || fields[0].Value.String() != "r" || fields[1].Value.String() != "bob" {
+		t.Errorf("fields: %v", fields)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/comments?request_id=q-1", nil)
+	req.Header.Set(RequestIDHeader, "h-1")
+	if id, _ := RequestID(req); id != "h-1" {
+		t.Errorf("header: got %q", id)
+	}
+	req.Header.Del(RequestIDHeader)
+	if id, _ := RequestID(req); id != "q-1" {
+		t.Errorf("query: got %q", id)
+	}
+	a, _ := RequestID(httptest.NewRequest(http.MethodGet, "/comments", nil))
+	b, _ := RequestID(httptest.NewRequest(http.MethodGet, "/comments", nil))
+	if a == "" || a == b {
+		t.Errorf("generated ids: %q, %q", a, b)
+	}
+}
+
+func TestLogRequest(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(NewHandler(&buf, Config{})))
+	defer slog.SetDefault(prev)
+
+	for _, status := range []int{http.StatusOK, http.StatusNotFound, http.StatusBadGateway} {
+		req := httptest.NewRequest(http.MethodGet, "/news/1", nil)
+		req = req.WithContext(WithRequest(req, "req-2"))
+		LogRequest(req, status, time.Now())
+	}
+
+	records := decode(t, &buf)
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	for i, level := range []string{"INFO", "WARN", "ERROR"} {
+		if records[i]["level"] != level || records[i][RequestIDKey] != "req-2" || records[i]["path"] != "/news/1" {
+			t.Errorf("record %d: %v", i, records[i])
+		}
+	}
+	if records[2]["status"] != float64(http.StatusBadGateway) {
+		t.Errorf("status = %v", records[2]["status"])
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "service.log")
+	f, err := NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	} {
+		got, err := os.ReadFile(name)
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v; want %q", filepath.Base(name), got, err, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("only 2 backups must be kept, stat .3: %v", err)
+	}
+}
+
+func TestRotatingFileAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "service.log")
+	os.WriteFile(path, []byte("12345678\n"), 0644)
+	f, err := NewRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// Размер существующего файла учитывается: новая запись уже не помещается.
+	f.Write([]byte("next\n"))
+	if got, _ := os.ReadFile(path + ".1"); string(got) != "12345678\n" {
+		t.Errorf("existing content must be rotated, backup = %q", got)
+	}
+}
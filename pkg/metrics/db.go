@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PgxPool — пул соединений pgx, например хранилище сервиса, отдающее статистику своего пула.
+type PgxPool interface {
+	Stat() *pgxpool.Stat
+}
+
+// RegisterPgxPool регистрирует показатели пула соединений pgx; pool — метка с именем пула.
+func (r *Registry) RegisterPgxPool(name string, p PgxPool) {
+	r.NewGaugeFunc("db_pool_connections", "Database pool connections by state.",
+		[]string{"pool", "state"}, func(set func(float64, ...string)) {
+			st := p.Stat()
+			set(float64(st.AcquiredConns()), name, "acquired")
+			set(float64(st.IdleConns()), name, "idle")
+			set(float64(st.ConstructingConns()), name, "constructing")
+		})
+	r.NewGaugeFunc("db_pool_max_connections", "Maximum size of the database pool.",
+		[]string{"pool"}, func(set func(float64, ...string)) {
+			set(float64(p.Stat().MaxConns()), name)
+		})
+	r.NewCounterFunc("db_pool_acquires_total", "Connections acquired from the database pool.",
+		[]string{"pool"}, func(set func(float64, ...string)) {
+			set(float64(p.Stat().AcquireCount()), name)
+		})
+	r.NewCounterFunc("db_pool_acquire_wait_seconds_total", "Time spent waiting for a database connection.",
+		[]string{"pool"}, func(set func(float64, ...string)) {
+			set(p.Stat().AcquireDuration().Seconds(), name)
+		})
+}
+
+// SQLDB — пул database/sql.
+type SQLDB interface {
+	Stats() sql.DBStats
+}
+
+// RegisterSQLDB регистрирует показатели пула database/sql под теми же именами, что и RegisterPgxPool.
+func (r *Registry) RegisterSQLDB(name string, db SQLDB) {
+	r.NewGaugeFunc("db_pool_connections", "Database pool connections by state.",
+		[]string{"pool", "state"}, func(set func(float64, ...string)) {
+			st := db.Stats()
+			set(float64(st.InUse), name, "acquired")
+			set(float64(st.Idle), name, "idle")
+		})
+	r.NewGaugeFunc("db_pool_max_connections", "Maximum size of the database pool.",
+		[]string{"pool"}, func(set func(float64, ...string)) {
+			set(float64(db.Stats().MaxOpenConnections), name)
+		})
+	r.NewCounterFunc("db_pool_acquire_wait_seconds_total", "Time spent waiting for a database connection.",
+		[]string{"pool"}, func(set func(float64, ...string)) {
+			set(db.Stats().WaitDuration.Seconds(), name)
+		})
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// HTTP — метрики входящих запросов сервиса.
+type HTTP struct {
+	requests *Counter
+	duration *Histogram
+}
+
+// NewHTTP регистрирует в реестре метрики запросов по маршруту, методу и коду ответа.
+func (r *Registry) NewHTTP() *HTTP {
+	return &HTTP{
+		requests: r.NewCounter("http_requests_total",
+			"Number of HTTP requests by route, method and status code.", "route", "method", "code"),
+		duration: r.NewHistogram("http_request_duration_seconds",
+			"HTTP request latency by route, method and status code.", nil, "route", "method", "code"),
+	}
+}
+
+var defaultHTTP = Default.NewHTTP()
+
+// Middleware учитывает запросы в реестре Default. Подключается через Router.Use,
+// чтобы маршрут был известен: метки содержат шаблон пути, а не сам путь.
+func Middleware(next http.Handler) http.Handler {
+	return defaultHTTP.Middleware(next)
+}
+
+// Middleware учитывает запросы, прошедшие через next.
+func (m *HTTP) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		route := "unmatched"
+		if cur := mux.CurrentRoute(r); cur != nil {
+			if tmpl, err := cur.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+		code := strconv.Itoa(sw.status)
+		m.requests.Inc(route, r.Method, code)
+		m.duration.Since(start, route, r.Method, code)
+	})
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wrote {
+		w.status, w.wrote = code, true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap даёт http.ResponseController доступ к исходному ResponseWriter.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,369 @@
+// Package metrics — метрики сервисов в текстовом формате Prometheus: счётчики, гистограммы
+// и метрики, значения которых снимаются при каждом чтении /metrics.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefBuckets — границы гистограмм длительности в секундах по умолчанию.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Типы метрик в формате Prometheus.
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// metric — метрика реестра, которая умеет записать свои значения.
+type metric interface {
+	describe() *desc
+	write(w *bufio.Writer)
+}
+
+// desc — имя, описание, тип и имена меток метрики.
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d *desc) describe() *desc { return d }
+
+// key проверяет число значений меток и склеивает их в ключ серии.
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// Registry — набор метрик одного процесса.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]metric{}}
+}
+
+// Default — реестр, в котором пакеты сервисов регистрируют свои метрики.
+var Default = NewRegistry()
+
+// register добавляет метрику; повторная регистрация имени — ошибка программы.
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name := m.describe().name
+	if _, ok := r.names[name]; ok {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.names[name] = m
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteTo записывает все метрики в текстовом формате Prometheus.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, m := range metrics {
+		d := m.describe()
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, d.typ)
+		m.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// Handler отдаёт метрики реестра.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	})
+}
+
+// Handler отдаёт метрики реестра Default.
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// Counter — счётчик с метками.
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labels []string
+	value  float64
+}
+
+// NewCounter регистрирует счётчик в реестре.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name, help, typeCounter, labels}, values: map[string]*counterSeries{}}
+	r.register(c)
+	return c
+}
+
+// NewCounter регистрирует счётчик в реестре Default.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return Default.NewCounter(name, help, labels...)
+}
+
+// Add увеличивает серию с метками values на v.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic("metrics: counter " + c.name + " cannot decrease")
+	}
+	key := c.key(values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.values[key]
+	if !ok {
+		s = &counterSeries{labels: append([]string(nil), values...)}
+		c.values[key] = s
+	}
+	s.value += v
+}
+
+// Inc увеличивает серию с метками values на единицу.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Value возвращает значение серии с метками values.
+func (c *Counter) Value(values ...string) float64 {
+	key := c.key(values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.values[key]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		s := c.values[key]
+		writeSample(w, c.name, c.labels, s.labels, "", "", s.value)
+	}
+}
+
+// Histogram — гистограмма с метками.
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labels []string
+	counts []uint64 // по бакетам, без накопления
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram регистрирует гистограмму с верхними границами бакетов buckets (nil — DefBuckets).
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &Histogram{
+		desc:    desc{name, help, typeHistogram, labels},
+		buckets: buckets,
+		values:  map[string]*histogramSeries{},
+	}
+	r.register(h)
+	return h
+}
+
+// NewHistogram регистрирует гистограмму в реестре Default.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return Default.NewHistogram(name, help, buckets, labels...)
+}
+
+// Observe добавляет наблюдение v в серию с метками values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	key := h.key(values)
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.values[key]
+	if !ok {
+		s = &histogramSeries{labels: append([]string(nil), values...), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = s
+	}
+	if i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+// Since добавляет в серию время, прошедшее с start, в секундах.
+func (h *Histogram) Since(start time.Time, values ...string) {
+	h.Observe(time.Since(start).Seconds(), values...)
+}
+
+// Count возвращает число наблюдений в серии с метками values.
+func (h *Histogram) Count(values ...string) uint64 {
+	key := h.key(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.values[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.values) {
+		s := h.values[key]
+		var cum uint64
+		for i, le := range h.buckets {
+			cum += s.counts[i]
+			writeSample(w, h.name+"_bucket", h.labels, s.labels, "le", formatFloat(le), float64(cum))
+		}
+		writeSample(w, h.name+"_bucket", h.labels, s.labels, "le", "+Inf", float64(s.count))
+		writeSample(w, h.name+"_sum", h.labels, s.labels, "", "", s.sum)
+		writeSample(w, h.name+"_count", h.labels, s.labels, "", "", float64(s.count))
+	}
+}
+
+// Collect передаёт значения серий функции set.
+type Collect func(set func(v float64, values ...string))
+
+// funcMetric — метрика, значения которой снимаются функциями collect при каждом чтении.
+type funcMetric struct {
+	desc
+	mu      sync.Mutex
+	collect []Collect
+}
+
+// NewGaugeFunc регистрирует показатель, значения которого снимает collect. Функции, зарегистрированные
+// под одним именем с теми же метками, дополняют друг друга: например, пулы нескольких баз.
+func (r *Registry) NewGaugeFunc(name, help string, labels []string, collect Collect) {
+	r.registerFunc(desc{name, help, typeGauge, labels}, collect)
+}
+
+// NewCounterFunc — как NewGaugeFunc, но для счётчиков, которые ведутся вне реестра.
+func (r *Registry) NewCounterFunc(name, help string, labels []string, collect Collect) {
+	r.registerFunc(desc{name, help, typeCounter, labels}, collect)
+}
+
+func (r *Registry) registerFunc(d desc, collect Collect) {
+	r.mu.Lock()
+	if f, ok := r.names[d.name].(*funcMetric); ok && f.typ == d.typ && slices.Equal(f.labels, d.labels) {
+		f.mu.Lock()
+		f.collect = append(f.collect, collect)
+		f.mu.Unlock()
+		r.mu.Unlock()
+		return
+	}
+	r.mu.Unlock()
+	r.register(&funcMetric{desc: d, collect: []Collect{collect}})
+}
+
+func (f *funcMetric) write(w *bufio.Writer) {
+	f.mu.Lock()
+	collects := f.collect
+	f.mu.Unlock()
+	for _, collect := range collects {
+		collect(func(v float64, values ...string) {
+			f.key(values)
+			writeSample(w, f.name, f.labels, values, "", "", v)
+		})
+	}
+}
+
+// writeSample записывает строку name{labels} value; extra — дополнительная метка, например le.
+func writeSample(w *bufio.Writer, name string, labels, values []string, extra, extraValue string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extra != "" {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", l, escapeLabel(values[i]))
+		}
+		if extra != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extra, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
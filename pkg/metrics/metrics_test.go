@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func scrape(t *testing.T, r *Registry) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	return w.Body.String()
+}
+
+func assertLines(t *testing.T, out string, lines ...string) {
+	t.Helper()
+	for _, l := range lines {
+		if !strings.Contains(out, l+"\n") {
+			t.Errorf("missing line %q in:\n%s", l, out)
+		}
+	}
+}
+
+func TestExposition(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("polls_total", "Polls by feed.\nSecond line.", "feed", "outcome")
+	c.Inc(`http://a/"rss"`, "ok")
+	c.Add(2, "b", "error")
+	h := r.NewHistogram("latency_seconds", "Latency.", []float64{0.5, 0.1}, "route")
+	h.Observe(0.05, "/news")
+	h.Observe(0.3, "/news")
+	h.Observe(7, "/news")
+	for _, pool := range []string{"a", "b"} {
+		name := pool
+		r.NewGaugeFunc("pool_connections", "Connections.", []string{"pool"}, func(set func(float64, ...string)) {
+			set(3, name)
+		})
+	}
+
+	assertLines(t, scrape(t, r),
+		`# HELP polls_total Polls by feed.\nSecond line.`,
+		`# TYPE polls_total counter`,
+		`polls_total{feed="http://a/\"rss\"",outcome="ok"} 1`,
+		`polls_total{feed="b",outcome="error"} 2`,
+		`# TYPE latency_seconds histogram`,
+		`latency_seconds_bucket{route="/news",le="0.1"} 1`,
+		`latency_seconds_bucket{route="/news",le="0.5"} 2`,
+		`latency_seconds_bucket{route="/news",le="+Inf"} 3`,
+		`latency_seconds_sum{route="/news"} 7.35`,
+		`latency_seconds_count{route="/news"} 3`,
+		`# TYPE pool_connections gauge`,
+		`pool_connections{pool="a"} 3`,
+		`pool_connections{pool="b"} 3`,
+	)
+}
+
+func TestRegistryMisuse(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("x_total", "X.", "a")
+	for name, f := range map[string]func(){
+		"duplicate":    func() { r.NewCounter("x_total", "X.") },
+		"label values": func() { c.Inc("1", "2") },
+		"decrease":     func() { c.Add(-1, "1") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	r := NewRegistry()
+	m := r.NewHTTP()
+	router := mux.NewRouter()
+	router.HandleFunc("/news/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+	router.Use(m.Middleware)
+
+	for _, path := range []string{"/news/1", "/news/2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if v := m.requests.Value("/news/{id:[0-9]+}", http.MethodGet, "404"); v != 2 {
+		t.Errorf("requests by route template: %v", v)
+	}
+	if n := m.duration.Count("/news/{id:[0-9]+}", http.MethodGet, "404"); n != 2 {
+		t.Errorf("latency observations: %d", n)
+	}
+}
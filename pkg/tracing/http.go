@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Middleware начинает серверный спан на каждый запрос, продолжая трассу вызывающего
+// из заголовка traceparent. Подключается через Router.Use, чтобы в имени спана был шаблон маршрута.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, route := r.Method, ""
+		if cur := mux.CurrentRoute(r); cur != nil {
+			if tmpl, err := cur.GetPathTemplate(); err == nil {
+				name, route = r.Method+" "+tmpl, tmpl
+			}
+		}
+
+		parent, _ := Extract(r.Header)
+		ctx, span := Default().StartRemote(r.Context(), name, KindServer, parent)
+		defer span.End()
+		span.SetAttr("http.request.method", r.Method)
+		span.SetAttr("url.path", r.URL.Path)
+		if route != "" {
+			span.SetAttr("http.route", route)
+		}
+		if id := r.Header.Get("X-Request-ID"); id != "" {
+			span.SetAttr("request_id", id)
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+		span.SetAttr("http.response.status_code", sw.status)
+		if sw.status >= http.StatusInternalServerError {
+			span.SetError(http.StatusText(sw.status))
+		}
+	})
+}
+
+// StartClient начинает клиентский спан вызова другого сервиса и возвращает копию req
+// с контекстом спана и заголовками traceparent/tracestate.
+func StartClient(req *http.Request, name string) (*http.Request, *Span) {
+	ctx, span := Start(req.Context(), name, KindClient)
+	out := req.Clone(ctx)
+	Inject(ctx, out.Header)
+	span.SetAttr("http.request.method", req.Method)
+	span.SetAttr("url.path", req.URL.Path)
+	return out, span
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wrote {
+		w.status, w.wrote = code, true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap даёт http.ResponseController доступ к исходному ResponseWriter.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// pgxTracer создаёт спан на каждый запрос к базе. Запросы с контекстом без спана
+// (фоновые задачи) становятся корнями собственных трасс.
+type pgxTracer struct{}
+
+func (pgxTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	statement := strings.Join(strings.Fields(data.SQL), " ")
+	op, _, _ := strings.Cut(statement, " ")
+	ctx, span := Start(ctx, "db "+strings.ToUpper(op), KindClient)
+	span.SetAttr("db.system", "postgresql")
+	span.SetAttr("db.statement", statement)
+	return ctx
+}
+
+func (pgxTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	if data.Err != nil {
+		span.SetError(data.Err.Error())
+	}
+	span.End()
+}
+
+// NewPgxPool подключается к базе как pgxpool.New, добавляя спаны запросов.
+func NewPgxPool(ctx context.Context, constr string) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig(constr)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ConnConfig.Tracer = pgxTracer{}
+	return pgxpool.NewWithConfig(ctx, cfg)
+}
@@ -0,0 +1,261 @@
+// Package tracing — распределённая трассировка сервисов: спаны, передача контекста
+// в заголовках W3C traceparent/tracestate и экспорт в OTLP/HTTP (JSON) или в файл.
+package tracing
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SpanKind — вид спана, значения совпадают с OTLP.
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+// Attr — атрибут спана; значение — строка, целое, число с плавающей точкой или bool.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+// SpanData — завершённый спан в виде для экспорта.
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	Parent        SpanID
+	Start, End    time.Time
+	Attrs         []Attr
+	Error         bool
+	StatusMessage string
+}
+
+// Span — выполняющаяся операция.
+type Span struct {
+	tracer *Tracer
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// SpanContext возвращает контекст спана для передачи другим сервисам.
+func (s *Span) SpanContext() SpanContext {
+	return s.data.SpanContext
+}
+
+// SetAttr добавляет атрибут.
+func (s *Span) SetAttr(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attrs = append(s.data.Attrs, Attr{key, value})
+}
+
+// SetError помечает операцию как неуспешную.
+func (s *Span) SetError(msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Error = true
+	s.data.StatusMessage = msg
+}
+
+// End завершает спан и передаёт его на экспорт; повторные вызовы ничего не делают.
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+	s.tracer.export(data)
+}
+
+// Exporter отправляет завершённые спаны сервиса service.
+type Exporter interface {
+	Export(ctx context.Context, service string, spans []SpanData) error
+}
+
+// Параметры отправки спанов пачками.
+const (
+	queueSize     = 2048
+	batchSize     = 256
+	flushInterval = 2 * time.Second
+)
+
+// Tracer создаёт спаны сервиса и отправляет их экспортёру пачками в фоне.
+// Без экспортёра спаны только передают контекст трассы дальше.
+type Tracer struct {
+	service  string
+	exporter Exporter
+
+	queue   chan SpanData
+	done    chan struct{}
+	dropped atomic.Int64
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewTracer создаёт трассировщик; exporter может быть nil.
+func NewTracer(service string, exporter Exporter) *Tracer {
+	t := &Tracer{service: service, exporter: exporter}
+	if exporter != nil {
+		t.queue = make(chan SpanData, queueSize)
+		t.done = make(chan struct{})
+		go t.run()
+	}
+	return t
+}
+
+// Start начинает спан, дочерний к текущему спану ctx, а без него — корень новой трассы.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	var parent SpanContext
+	if p := SpanFromContext(ctx); p != nil {
+		parent = p.SpanContext()
+	}
+	return t.start(ctx, name, kind, parent)
+}
+
+// StartRemote начинает спан, дочерний к спану другого сервиса; без parent — корень новой трассы.
+func (t *Tracer) StartRemote(ctx context.Context, name string, kind SpanKind, parent SpanContext) (context.Context, *Span) {
+	return t.start(ctx, name, kind, parent)
+}
+
+func (t *Tracer) start(ctx context.Context, name string, kind SpanKind, parent SpanContext) (context.Context, *Span) {
+	sc := SpanContext{TraceID: parent.TraceID, SpanID: newSpanID(), Sampled: parent.Sampled, TraceState: parent.TraceState}
+	if !parent.IsValid() {
+		sc = SpanContext{TraceID: newTraceID(), SpanID: sc.SpanID, Sampled: true}
+	}
+	span := &Span{tracer: t, data: SpanData{
+		Name:        name,
+		Kind:        kind,
+		SpanContext: sc,
+		Parent:      parent.SpanID,
+		Start:       time.Now(),
+	}}
+	return ContextWithSpan(ctx, span), span
+}
+
+func (t *Tracer) export(data SpanData) {
+	if t.queue == nil || !data.SpanContext.Sampled {
+		return
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.queue <- data:
+	default:
+		// Экспортёр не успевает: спан теряется, а не задерживает запрос.
+		t.dropped.Add(1)
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	var batch []SpanData
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := t.exporter.Export(ctx, t.service, batch); err != nil {
+			slog.Error("failed to export spans", "spans", len(batch), "err", err)
+		}
+		cancel()
+		batch = nil
+	}
+	for {
+		select {
+		case data, ok := <-t.queue:
+			if !ok {
+				flush()
+				return
+			}
+			if batch = append(batch, data); len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// Shutdown отправляет накопленные спаны. После него спаны не экспортируются.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t.queue == nil {
+		return nil
+	}
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.queue)
+	}
+	t.mu.Unlock()
+	select {
+	case <-t.done:
+		if n := t.dropped.Load(); n > 0 {
+			slog.Warn("spans dropped", "spans", n)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+func init() {
+	defaultTracer.Store(NewTracer("", nil))
+}
+
+// SetDefault делает t трассировщиком, которым пользуются Start, Middleware и NewPgxPool.
+func SetDefault(t *Tracer) {
+	defaultTracer.Store(t)
+}
+
+// Default возвращает трассировщик по умолчанию.
+func Default() *Tracer {
+	return defaultTracer.Load()
+}
+
+// Start начинает спан трассировщиком по умолчанию.
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	return Default().Start(ctx, name, kind)
+}
+
+// FromEnv настраивает трассировщик по умолчанию для service по переменным окружения:
+// OTEL_EXPORTER_OTLP_ENDPOINT — адрес коллектора OTLP/HTTP (например, http://localhost:4318),
+// OTEL_TRACES_FILE — файл, в который спаны пишутся построчно в формате OTLP JSON,
+// OTEL_SERVICE_NAME — имя сервиса вместо service. Без экспортёра спаны не отправляются,
+// но контекст трассы передаётся дальше.
+func FromEnv(service string) *Tracer {
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		service = name
+	}
+	var exporter Exporter
+	switch {
+	case os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "":
+		exporter = NewOTLPExporter(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"))
+	case os.Getenv("OTEL_TRACES_FILE") != "":
+		exporter = NewFileExporter(os.Getenv("OTEL_TRACES_FILE"))
+	}
+	t := NewTracer(service, exporter)
+	SetDefault(t)
+	return t
+}
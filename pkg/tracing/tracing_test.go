@@ -0,0 +1,136 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	const valid = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := ParseTraceparent(valid)
+	if !ok || !sc.Sampled || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" {
+		t.Fatalf("valid header: %+v %v", sc, ok)
+	}
+	if sc.Traceparent() != valid {
+		t.Errorf("round trip: %s", sc.Traceparent())
+	}
+	if _, ok := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future"); !ok {
+		t.Error("future version with extra fields must be accepted")
+	}
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+	} {
+		if _, ok := ParseTraceparent(bad); ok {
+			t.Errorf("%q must be rejected", bad)
+		}
+	}
+}
+
+// readSpans читает спаны из файла FileExporter.
+func readSpans(t *testing.T, path string) []otlpSpan {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var spans []otlpSpan
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var req otlpRequest
+		if err := json.Unmarshal(sc.Bytes(), &req); err != nil {
+			t.Fatal(err)
+		}
+		for _, rs := range req.ResourceSpans {
+			if v := rs.Resource.Attributes[0].Value.StringValue; v == nil || *v != "comments" {
+				t.Errorf("unexpected resource: %+v", rs.Resource)
+			}
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}
+	return spans
+}
+
+func TestPropagationAndFileExport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	tracer := NewTracer("comments", NewFileExporter(path))
+	SetDefault(tracer)
+	defer SetDefault(NewTracer("", nil))
+
+	var downstream http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstream = r.Header.Clone()
+	}))
+	defer backend.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/comments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, backend.URL+"/censor", nil)
+		req, span := StartClient(req, "censor")
+		defer span.End()
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	router.Use(Middleware)
+
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodGet, "/comments/7", nil)
+	req.Header.Set(TraceparentHeader, parent)
+	req.Header.Set(TracestateHeader, "vendor=1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	sc, ok := Extract(downstream)
+	if !ok || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.TraceState != "vendor=1" {
+		t.Fatalf("downstream call must continue the trace: %v", downstream)
+	}
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := readSpans(t, path)
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	client, server := spans[0], spans[1]
+	if server.Name != "GET /comments/{id}" || server.Kind != KindServer || server.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("server span: %+v", server)
+	}
+	if server.Status.Code != otlpStatusError {
+		t.Errorf("502 must mark the server span as failed: %+v", server.Status)
+	}
+	if client.Kind != KindClient || client.ParentSpanID != server.SpanID || client.SpanID != sc.SpanID.String() {
+		t.Errorf("client span must be the parent of the downstream call: %+v", client)
+	}
+}
+
+func TestUnsampledNotExported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	tracer := NewTracer("comments", NewFileExporter(path))
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	_, span := tracer.StartRemote(context.Background(), "GET /", KindServer, parent)
+	span.End()
+	tracer.Shutdown(context.Background())
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("unsampled span must not be exported, stat: %v", err)
+	}
+}
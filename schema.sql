@@ -1,6 +1,10 @@
+DROP TABLE IF EXISTS comment_revisions;
 DROP TABLE IF EXISTS posts;
 DROP TABLE IF EXISTS comments;
 DROP TABLE IF EXISTS stop;
+DROP TABLE IF EXISTS moderation_log;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS rate_limits;
 CREATE TABLE posts (
     id SERIAL PRIMARY KEY,
     title TEXT,
@@ -13,12 +17,63 @@ CREATE TABLE comments (
     news_id INT REFERENCES posts(id) ON DELETE CASCADE,
     parent_id INT REFERENCES comments(id) ON DELETE CASCADE,
     content TEXT NOT NULL DEFAULT 'empty',
-    PubTime BIGINT NOT NULL DEFAULT extract (epoch from now())
+    PubTime BIGINT NOT NULL DEFAULT extract (epoch from now()),
+    status TEXT NOT NULL DEFAULT 'approved',
+    edited_at BIGINT,
+    deleted_at BIGINT,
+    author_id TEXT NOT NULL DEFAULT '',
+    author_name TEXT NOT NULL DEFAULT '',
+    -- idempotency_key — Idempotency-Key запроса на создание: повтор того же запроса не создаёт копию.
+    idempotency_key TEXT
 );
+CREATE UNIQUE INDEX comments_idempotency_idx ON comments (author_id, idempotency_key);
+CREATE INDEX comments_status_idx ON comments (status, id);
+CREATE INDEX comments_author_idx ON comments (author_id, pubtime, id) WHERE author_id <> '';
+CREATE INDEX comments_deleted_idx ON comments (deleted_at) WHERE deleted_at IS NOT NULL;
+-- Журнал модерации переживает удаление комментария, поэтому без внешнего ключа.
+CREATE TABLE moderation_log (
+    id SERIAL PRIMARY KEY,
+    comment_id INT NOT NULL,
+    actor TEXT NOT NULL,
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at BIGINT NOT NULL DEFAULT extract (epoch from now())
+);
+CREATE INDEX moderation_log_comment_idx ON moderation_log (comment_id, id);
+CREATE TABLE comment_revisions (
+    id SERIAL PRIMARY KEY,
+    comment_id INT NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    created_at BIGINT NOT NULL
+);
+CREATE INDEX comment_revisions_comment_idx ON comment_revisions (comment_id, id);
 CREATE TABLE IF NOT EXISTS stop (
     id SERIAL PRIMARY KEY,
-    stop_list TEXT
+    stop_list TEXT NOT NULL UNIQUE,
+    category TEXT NOT NULL DEFAULT 'general',
+    severity INT NOT NULL DEFAULT 1 CHECK (severity BETWEEN 1 AND 5)
+);
+-- API-ключи клиентов шлюза; хранится только SHA-256 ключа.
+CREATE TABLE api_keys (
+    id TEXT PRIMARY KEY,
+    client TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE,
+    roles TEXT[] NOT NULL DEFAULT '{}',
+    per_minute INT NOT NULL DEFAULT 0 CHECK (per_minute >= 0),
+    per_day INT NOT NULL DEFAULT 0 CHECK (per_day >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ
+);
+CREATE INDEX api_keys_client_idx ON api_keys (client, created_at);
+-- Вёдра ограничения частоты, общие для экземпляров шлюза.
+CREATE TABLE rate_limits (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ
 );
+CREATE INDEX rate_limits_expires_idx ON rate_limits (expires_at);
 INSERT INTO comments(news_id,content)  VALUES (1,'комментарий');
 INSERT INTO comments(news_id,content)  VALUES (2,'ups  проверка');
 INSERT INTO stop (stop_list) VALUES ('qwerty');